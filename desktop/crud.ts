@@ -8,6 +8,7 @@ import {
   PanelInfo,
   ProjectPage,
   ServerInfo,
+  Variable,
 } from '../shared/state';
 
 export type EntityType =
@@ -147,4 +148,18 @@ export const metadataCrud = {
       stmt.run(kv);
     }
   },
+
+  // Project variables are the only non-scalar metadata, they're
+  // stored as a JSON array that the runner reads too.
+  getVariables(metadata: Record<string, string>): Array<Variable> {
+    if (!metadata.variables) {
+      return [];
+    }
+
+    return JSON.parse(metadata.variables);
+  },
+
+  insertVariables(db: sqlite3.Database, variables: Array<Variable>) {
+    metadataCrud.insert(db, { variables: JSON.stringify(variables) });
+  },
 };
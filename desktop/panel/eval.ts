@@ -147,33 +147,33 @@ export async function evalInSubprocess(
     });
 
     const resultMeta = JSON.parse(fs.readFileSync(tmp.path).toString());
-    let parsePartial = typeof resultMeta.preview === 'undefined';
-    if (!parsePartial) {
-      const rm: Partial<PanelResult> = resultMeta;
-      // Case of existing Node.js runner
-      return [rm, stderr];
-    }
-
-    // Case of new Go runner
     const projectResultsFile = getProjectResultsFile(projectName);
-    let rm: Partial<PanelResult>;
-    try {
-      rm = parsePartialJSONFile(projectResultsFile + panel.id);
-    } catch (e) {
-      if (!e.stdout) {
-        e.stdout = resultMeta.stdout;
-      }
 
-      if (e instanceof NoResultError && resultMeta.exception) {
-        // do nothing. The underlying exception is more interesting
-      } else {
-        throw e;
+    // The runner fills in the shape, size, count and preview when
+    // evaluating succeeds. Otherwise the results file is read for
+    // them.
+    let rm: Partial<PanelResult> = {};
+    if (typeof resultMeta.preview === 'undefined') {
+      try {
+        rm = parsePartialJSONFile(projectResultsFile + panel.id);
+      } catch (e) {
+        if (!e.stdout) {
+          e.stdout = resultMeta.stdout;
+        }
+
+        if (e instanceof NoResultError && resultMeta.exception) {
+          // do nothing. The underlying exception is more interesting
+        } else {
+          throw e;
+        }
       }
     }
 
     // Table and graph panels get their results passed back to me displayed in the UI
-    if (['table', 'graph'].includes(panel.type)) {
-      const projectResultsFile = getProjectResultsFile(projectName);
+    if (
+      ['table', 'graph'].includes(panel.type) &&
+      typeof resultMeta.value === 'undefined'
+    ) {
       const bytes = fs.readFileSync(projectResultsFile + panel.id);
       rm.value = JSON.parse(bytes.toString());
     }
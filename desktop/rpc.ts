@@ -40,6 +40,8 @@ import {
   UpdatePageResponse,
   UpdatePanelRequest,
   UpdatePanelResponse,
+  UpdateProjectVariablesRequest,
+  UpdateProjectVariablesResponse,
   UpdateServerRequest,
   UpdateServerResponse,
 } from '../shared/rpc';
@@ -117,6 +119,11 @@ export type UpdatePageHandler = RPCHandler<
 
 export type GetPageHandler = RPCHandler<GetPageRequest, GetPageResponse>;
 
+export type UpdateProjectVariablesHandler = RPCHandler<
+  UpdateProjectVariablesRequest,
+  UpdateProjectVariablesResponse
+>;
+
 export type UpdatePanelHandler = RPCHandler<
   UpdatePanelRequest,
   UpdatePanelResponse
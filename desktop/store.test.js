@@ -12,6 +12,7 @@ const {
   Encrypt,
   DatabaseConnectorInfo,
   ServerInfo,
+  Variable,
 } = require('../shared/state');
 const { ensureSigningKey } = require('./secret');
 
@@ -37,6 +38,9 @@ const updatePanelResults = storeHandlers.filter(
 )[0];
 const updatePage = storeHandlers.filter((r) => r.resource === 'updatePage')[0];
 const getProject = storeHandlers.filter((r) => r.resource === 'getProject')[0];
+const updateProjectVariables = storeHandlers.filter(
+  (r) => r.resource === 'updateProjectVariables'
+)[0];
 
 test('write project with encrypted secrets, read with nulled secrets', async () => {
   // Shouldn't be harmful even though it is potentially creating a new
@@ -173,6 +177,51 @@ test('newly created project is saved correctly', async () => {
   }
 });
 
+test('project variables are saved correctly', async () => {
+  const testProject = new ProjectState();
+  testProject.projectName = ensureProjectFile(testProject.id);
+
+  // Delete and recreate it to be safe
+  try {
+    fs.unlinkSync(testProject.projectName);
+  } catch (e) {
+    /* nothing */
+  }
+  ensureProjectFile(testProject.projectName);
+
+  const variables = [
+    new Variable({ name: 'customer', default: 'acme' }),
+    new Variable({ name: 'since', type: 'date', default: '2022-01-01' }),
+  ];
+
+  try {
+    await makeProject.handler(null, { projectId: testProject.projectName });
+    await updateProjectVariables.handler(testProject.projectName, {
+      variables,
+    });
+    const read = await getProject.handler(null, {
+      projectId: testProject.projectName,
+    });
+    expect(read.variables).toStrictEqual(variables);
+
+    // Saving again replaces them
+    await updateProjectVariables.handler(testProject.projectName, {
+      variables: variables.slice(1),
+    });
+    const reread = await getProject.handler(null, {
+      projectId: testProject.projectName,
+    });
+    expect(reread.variables).toStrictEqual(variables.slice(1));
+  } finally {
+    const projectPath = ensureProjectFile(testProject.projectName);
+    try {
+      fs.unlinkSync(projectPath);
+    } catch (e) {
+      console.error(e);
+    }
+  }
+});
+
 test('updates works correctly', async () => {
   const testProject = new ProjectState();
   testProject.projectName = ensureProjectFile(testProject.id);
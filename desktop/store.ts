@@ -25,6 +25,7 @@ import {
   ProjectState,
   ServerInfo,
   TablePanelInfo,
+  Variable,
 } from '../shared/state';
 import { CODE_ROOT, DISK_ROOT, PROJECT_EXTENSION } from './constants';
 import {
@@ -52,6 +53,7 @@ import {
   UpdateConnectorHandler,
   UpdatePageHandler,
   UpdatePanelHandler,
+  UpdateProjectVariablesHandler,
   UpdateServerHandler,
 } from './rpc';
 import { encrypt } from './secret';
@@ -288,6 +290,13 @@ export class Store {
         false
       );
     }
+
+    await this.updateProjectVariablesHandler.handler(
+      project.projectName,
+      { variables: project.variables || [] },
+      null,
+      false
+    );
   }
 
   getPageHandler: GetPageHandler = {
@@ -341,6 +350,7 @@ export class Store {
         connectorCrud.get(db),
       ];
       const rawProject: any = metadata;
+      rawProject.variables = metadataCrud.getVariables(metadata);
       rawProject.connectors = connectors;
       rawProject.servers = servers;
 
@@ -480,6 +490,18 @@ GROUP BY panel_id
         }
       }
       metadataCrud.insert(db, metadata);
+      metadataCrud.insertVariables(db, newProject.variables);
+    },
+  };
+
+  updateProjectVariablesHandler: UpdateProjectVariablesHandler = {
+    resource: 'updateProjectVariables',
+    handler: async (
+      projectId: string,
+      { variables }: { variables: Array<Variable> }
+    ) => {
+      const db = this.getConnection(projectId);
+      metadataCrud.insertVariables(db, variables);
     },
   };
 
@@ -750,6 +772,7 @@ GROUP BY panel_id
       this.updateConnectorHandler,
       this.updatePageHandler,
       this.updateServerHandler,
+      this.updateProjectVariablesHandler,
       this.deletePanelHandler,
       this.deleteConnectorHandler,
       this.deletePageHandler,
@@ -0,0 +1,206 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Picks the fastest way to load DM_getPanel tables that the
+// connection supports, nil means plain inserts.
+func getBulkLoader(ctx context.Context, dialect DatabaseConnectorInfoType, conn *sqlx.Conn) bulkLoader {
+	switch dialect {
+	case PostgresDatabase:
+		return postgresBulkLoader(ctx, conn)
+	case MySQLDatabase:
+		// LOAD DATA LOCAL is off by default in MySQL 8
+		var enabled bool
+		err := conn.QueryRowContext(ctx, "SELECT @@GLOBAL.local_infile").Scan(&enabled)
+		if err != nil || !enabled {
+			Logln("Server doesn't allow LOAD DATA LOCAL, falling back to inserts")
+			return nil
+		}
+
+		return mysqlBulkLoader(ctx, conn)
+	case SQLiteDatabase:
+		return sqliteBulkLoader(ctx, conn)
+	}
+
+	return nil
+}
+
+func withTx(ctx context.Context, conn *sqlx.Conn, cb func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = cb(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// COPY FROM STDIN
+func postgresBulkLoader(ctx context.Context, conn *sqlx.Conn) bulkLoader {
+	return func(table string, columns []string, next func([]any) (bool, error)) error {
+		return withTx(ctx, conn, func(tx *sql.Tx) error {
+			stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+			if err != nil {
+				return err
+			}
+			defer stmt.Close()
+
+			values := make([]any, len(columns))
+			for {
+				ok, err := next(values)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					break
+				}
+
+				_, err = stmt.ExecContext(ctx, values...)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Flushes the copy
+			_, err = stmt.ExecContext(ctx)
+			return err
+		})
+	}
+}
+
+// Escapes values for LOAD DATA's default tab-separated format.
+var mysqlLoadDataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\t", `\t`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x00", `\0`,
+)
+
+func mysqlLoadDataValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return `\N`
+	case bool:
+		if t {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case time.Time:
+		return t.Format("2006-01-02 15:04:05.999999")
+	case string:
+		return mysqlLoadDataEscaper.Replace(t)
+	case []byte:
+		return mysqlLoadDataEscaper.Replace(string(t))
+	}
+
+	s, _ := jsonMarshal(v)
+	return mysqlLoadDataEscaper.Replace(string(s))
+}
+
+// Streams rows as tab-separated lines.
+func mysqlLoadDataReader(nColumns int, next func([]any) (bool, error)) *io.PipeReader {
+	r, w := io.Pipe()
+	go func() {
+		values := make([]any, nColumns)
+		var line strings.Builder
+		for {
+			ok, err := next(values)
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+			if !ok {
+				break
+			}
+
+			line.Reset()
+			for i, v := range values {
+				if i > 0 {
+					line.WriteByte('\t')
+				}
+				line.WriteString(mysqlLoadDataValue(v))
+			}
+			line.WriteByte('\n')
+
+			_, err = io.WriteString(w, line.String())
+			if err != nil {
+				return
+			}
+		}
+
+		w.Close()
+	}()
+
+	return r
+}
+
+// LOAD DATA LOCAL INFILE from a registered reader
+func mysqlBulkLoader(ctx context.Context, conn *sqlx.Conn) bulkLoader {
+	return func(table string, columns []string, next func([]any) (bool, error)) error {
+		var quoted []string
+		for _, c := range columns {
+			quoted = append(quoted, quote(c, mysqlQuote.identifier))
+		}
+
+		r := mysqlLoadDataReader(len(columns), next)
+		// The reader must be drained or closed so next isn't
+		// left running.
+		defer r.Close()
+
+		name := "datastation-" + newId()
+		mysql.RegisterReaderHandler(name, func() io.Reader { return r })
+		defer mysql.DeregisterReaderHandler(name)
+
+		_, err := conn.ExecContext(ctx,
+			"LOAD DATA LOCAL INFILE 'Reader::"+name+"' INTO TABLE "+
+				quote(table, mysqlQuote.identifier)+
+				" CHARACTER SET utf8mb4 ("+strings.Join(quoted, ", ")+")")
+		return err
+	}
+}
+
+// Inserts in one transaction with large statements
+func sqliteBulkLoader(ctx context.Context, conn *sqlx.Conn) bulkLoader {
+	return func(table string, columns []string, next func([]any) (bool, error)) error {
+		return withTx(ctx, conn, func(tx *sql.Tx) error {
+			prepare := func(q string) (func([]any) error, func(), error) {
+				stmt, err := tx.PrepareContext(ctx, q)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				return func(values []any) error {
+						_, err := stmt.ExecContext(ctx, values...)
+						return err
+					}, func() {
+						stmt.Close()
+					}, nil
+			}
+
+			chunkSize := insertChunkSize(SQLiteDatabase, len(columns))
+			tname := quote(table, ansiSQLQuote.identifier)
+			return insertChunks(prepare, SQLiteDatabase, tname, len(columns), chunkSize, next)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func sliceNext(rows [][]any, err error) func([]any) (bool, error) {
+	i := 0
+	return func(values []any) (bool, error) {
+		if i == len(rows) {
+			return false, err
+		}
+
+		copy(values, rows[i])
+		i++
+		return true, nil
+	}
+}
+
+func Test_mysqlLoadDataReader(t *testing.T) {
+	rows := [][]any{
+		{nil, true, 1.5, int64(3)},
+		{"a\tb\nc\\d", false, time.Date(2022, 1, 5, 10, 0, 0, 500000000, time.UTC), "[1]"},
+	}
+
+	r := mysqlLoadDataReader(4, sliceNext(rows, nil))
+	bs, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "\\N\t1\t1.5\t3\na\\tb\\nc\\\\d\t0\t2022-01-05 10:00:00.5\t[1]\n", string(bs))
+
+	r = mysqlLoadDataReader(4, sliceNext(rows, fmt.Errorf("bad row")))
+	_, err = io.ReadAll(r)
+	assert.Equal(t, "bad row", err.Error())
+}
+
+func Test_sqliteBulkLoader(t *testing.T) {
+	db, err := sqlx.Open("sqlite3_extended", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `CREATE TEMPORARY TABLE "t_0" ("a" INTEGER, "b" TEXT)`)
+	assert.Nil(t, err)
+
+	// Spans several statements with leftovers
+	var rows [][]any
+	for i := 0; i < 1234; i++ {
+		rows = append(rows, []any{int64(i), fmt.Sprint(i)})
+	}
+
+	load := sqliteBulkLoader(ctx, conn)
+	err = load("t_0", []string{"a", "b"}, sliceNext(rows, nil))
+	assert.Nil(t, err)
+
+	var count, sum int
+	err = conn.QueryRowContext(ctx, `SELECT COUNT(1), SUM(a) FROM "t_0"`).Scan(&count, &sum)
+	assert.Nil(t, err)
+	assert.Equal(t, 1234, count)
+	assert.Equal(t, 1233*1234/2, sum)
+
+	// Nothing is kept when a row fails
+	err = load("t_0", []string{"a", "b"}, sliceNext(rows, fmt.Errorf("bad row")))
+	assert.Equal(t, "bad row", err.Error())
+	err = conn.QueryRowContext(ctx, `SELECT COUNT(1) FROM "t_0"`).Scan(&count)
+	assert.Nil(t, err)
+	assert.Equal(t, 1234, count)
+}
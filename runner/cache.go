@@ -0,0 +1,171 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"os"
+	"time"
+)
+
+// A panel's results are reused when nothing that went into them has
+// changed and they aren't older than the panel's TTL.
+type resultCacheEntry struct {
+	Fingerprint string    `json:"fingerprint"`
+	Created     time.Time `json:"created"`
+	// To notice the results file changing underneath the cache
+	ResultsSize    int64       `json:"resultsSize"`
+	ResultsModTime time.Time   `json:"resultsModTime"`
+	Result         PanelResult `json:"result"`
+}
+
+func (ec EvalContext) getResultCacheFile(projectId, panelId string) string {
+	return ec.GetPanelResultsFile(projectId, panelId) + ".cache"
+}
+
+// The panel's TTL wins over the settings default. Negative disables
+// caching for the panel.
+func (ec EvalContext) panelCacheTTL(panel PanelInfo) time.Duration {
+	ttl := panel.CacheTTL
+	if ttl == 0 {
+		ttl = ec.settings.ResultCacheTTL
+	}
+
+	if ttl <= 0 {
+		return 0
+	}
+
+	return time.Duration(ttl) * time.Second
+}
+
+func writeFingerprintPart(h hash.Hash, v any) error {
+	bs, err := jsonMarshal(v)
+	if err != nil {
+		return err
+	}
+
+	h.Write(bs)
+	h.Write([]byte{0})
+	return nil
+}
+
+// Hashes everything that goes into a panel's results: the panel
+// after macro expansion, its connector and server, variables and the
+// results of upstream panels. Upstream results are identified by
+// their size and modification time so a cached upstream panel
+// doesn't invalidate its dependents.
+func (ec EvalContext) panelFingerprint(project *ProjectState, pageIndex int, panel PanelInfo) (string, error) {
+	h := sha256.New()
+
+	// Not an input
+	panel.ResultMeta = PanelResult{}
+	// Settings can change the limits
+	panel.ResultLimits = ec.panelResultLimits(panel)
+	if err := writeFingerprintPart(h, panel); err != nil {
+		return "", err
+	}
+
+	serverId := panel.ServerId
+	if panel.DatabasePanelInfo != nil {
+		for _, c := range project.Connectors {
+			if c.Id == panel.Database.ConnectorId {
+				if err := writeFingerprintPart(h, c); err != nil {
+					return "", err
+				}
+
+				if serverId == "" {
+					serverId = c.ServerId
+				}
+			}
+		}
+	}
+
+	if server, err := getServer(project, serverId); err == nil && server != nil {
+		if err := writeFingerprintPart(h, server); err != nil {
+			return "", err
+		}
+	}
+
+	vars, err := resolveVariables(project, pageIndex, ec.vars)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFingerprintPart(h, vars); err != nil {
+		return "", err
+	}
+
+	// Bound values don't show up in the panel's query
+	if err := writeFingerprintPart(h, ec.params); err != nil {
+		return "", err
+	}
+
+	type fileVersion struct {
+		Name    string
+		Size    int64
+		ModTime int64
+	}
+	version := func(name, file string) fileVersion {
+		v := fileVersion{Name: name}
+		if fi, err := os.Stat(file); err == nil {
+			v.Size = fi.Size()
+			v.ModTime = fi.ModTime().UnixNano()
+		}
+		return v
+	}
+
+	var versions []fileVersion
+	for _, id := range getPanelDependencies(project, pageIndex, panel) {
+		versions = append(versions, version(id, ec.GetPanelResultsFile(project.Id, id)))
+	}
+
+	// Local files can change without the panel changing
+	if panel.FilePanelInfo != nil && panel.ServerId == "" {
+		versions = append(versions, version(panel.File.Name, resolvePath(panel.File.Name)))
+	}
+
+	if err := writeFingerprintPart(h, versions); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (ec EvalContext) getCachedResult(projectId, panelId, fingerprint string, ttl time.Duration) (*PanelResult, bool) {
+	if ec.force {
+		return nil, false
+	}
+
+	var entry resultCacheEntry
+	err := readJSONFileInto(ec.getResultCacheFile(projectId, panelId), &entry)
+	if err != nil {
+		return nil, false
+	}
+
+	if entry.Fingerprint != fingerprint || time.Since(entry.Created) > ttl {
+		return nil, false
+	}
+
+	fi, err := os.Stat(ec.GetPanelResultsFile(projectId, panelId))
+	if err != nil || fi.Size() != entry.ResultsSize || !fi.ModTime().Equal(entry.ResultsModTime) {
+		return nil, false
+	}
+
+	return &entry.Result, true
+}
+
+func (ec EvalContext) storeCachedResult(projectId, panelId, fingerprint string, result PanelResult) error {
+	fi, err := os.Stat(ec.GetPanelResultsFile(projectId, panelId))
+	if err != nil {
+		return err
+	}
+
+	// Logs are for the run that produced them
+	result.Logs = nil
+	return WriteJSONFile(ec.getResultCacheFile(projectId, panelId), &resultCacheEntry{
+		Fingerprint:    fingerprint,
+		Created:        time.Now(),
+		ResultsSize:    fi.Size(),
+		ResultsModTime: fi.ModTime(),
+		Result:         result,
+	})
+}
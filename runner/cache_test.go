@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_panelCacheTTL(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	tests := []struct {
+		panelTTL    int
+		settingsTTL int
+		exp         time.Duration
+	}{
+		{0, 0, 0},
+		{30, 0, 30 * time.Second},
+		{0, 60, time.Minute},
+		{30, 60, 30 * time.Second},
+		{-1, 60, 0},
+	}
+
+	for _, test := range tests {
+		ec.settings.ResultCacheTTL = test.settingsTTL
+		assert.Equal(t, test.exp, ec.panelCacheTTL(PanelInfo{CacheTTL: test.panelTTL}))
+	}
+}
+
+func Test_evalPanel_cache(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	literal := PanelInfo{
+		Id:       newId(),
+		Name:     "numbers",
+		Type:     LiteralPanel,
+		Content:  "a\n1\n2",
+		CacheTTL: 60,
+		LiteralPanelInfo: &LiteralPanelInfo{
+			Literal: LiteralPanelInfoLiteral{
+				ContentTypeInfo: ContentTypeInfo{Type: "text/csv"},
+			},
+		},
+	}
+	project := &ProjectState{
+		Id:    "cache-test",
+		Pages: []ProjectPage{{Panels: []PanelInfo{literal}}},
+	}
+	defer os.Remove(ec.getResultCacheFile(project.Id, literal.Id))
+
+	eval := func(ec EvalContext, panel PanelInfo) PanelResult {
+		err, _ := ec.evalPanel(context.Background(), project, 0, &panel)
+		assert.Nil(t, err)
+		return panel.ResultMeta
+	}
+
+	assert.False(t, eval(ec, literal).Cached)
+
+	resultsFile := ec.GetPanelResultsFile(project.Id, literal.Id)
+	before, err := os.Stat(resultsFile)
+	assert.Nil(t, err)
+
+	result := eval(ec, literal)
+	assert.True(t, result.Cached)
+	assert.Equal(t, float64(2), *result.ArrayCount)
+	after, err := os.Stat(resultsFile)
+	assert.Nil(t, err)
+	assert.Equal(t, before.ModTime(), after.ModTime())
+
+	// Forced evals refresh the cache
+	assert.False(t, eval(ec.WithForce(true), literal).Cached)
+	assert.True(t, eval(ec, literal).Cached)
+
+	// So do variables
+	assert.False(t, eval(ec.WithVariables(map[string]string{"x": "1"}), literal).Cached)
+
+	changed := literal
+	changed.Content = "a\n3"
+	assert.False(t, eval(ec, changed).Cached)
+
+	// The results file changing means the entry is stale
+	assert.True(t, eval(ec, changed).Cached)
+	err = os.WriteFile(resultsFile, []byte(`[{"a": 4}]`), os.ModePerm)
+	assert.Nil(t, err)
+	assert.False(t, eval(ec, changed).Cached)
+
+	// Expired
+	ec.settings.ResultCacheTTL = 1
+	changed.CacheTTL = 0
+	eval(ec, changed)
+	assert.True(t, eval(ec, changed).Cached)
+	time.Sleep(1100 * time.Millisecond)
+	assert.False(t, eval(ec, changed).Cached)
+}
+
+func Test_panelFingerprint_upstream(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	upstream := PanelInfo{Id: newId(), Name: "upstream", Type: LiteralPanel, LiteralPanelInfo: &LiteralPanelInfo{}}
+	downstream := PanelInfo{
+		Id:      newId(),
+		Name:    "downstream",
+		Type:    LiteralPanel,
+		Content: `{{ DM_getPanel("upstream") }}`,
+	}
+	project := &ProjectState{
+		Id:    "fingerprint-test",
+		Pages: []ProjectPage{{Panels: []PanelInfo{upstream, downstream}}},
+	}
+
+	upstreamFile := ec.GetPanelResultsFile(project.Id, upstream.Id)
+	defer os.Remove(upstreamFile)
+	err := os.WriteFile(upstreamFile, []byte(`[1]`), os.ModePerm)
+	assert.Nil(t, err)
+
+	first, err := ec.panelFingerprint(project, 0, downstream)
+	assert.Nil(t, err)
+	again, err := ec.panelFingerprint(project, 0, downstream)
+	assert.Nil(t, err)
+	assert.Equal(t, first, again)
+
+	err = os.WriteFile(upstreamFile, []byte(`[1, 2]`), os.ModePerm)
+	assert.Nil(t, err)
+	changed, err := ec.panelFingerprint(project, 0, downstream)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, changed)
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"math/rand"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/multiprocessio/datastation/runner"
@@ -17,18 +21,34 @@ var (
 type args struct {
 	projectId    string
 	panelId      string
+	pageId       string
 	panelMetaOut string
+	progressFile string
 	settingsFile string
 	action       string
 	fsBase       string
+	listen       string
+	tokenFile    string
+	vars         map[string]string
+	force        bool
 }
 
 func getArgs() args {
 	a := args{}
 	a.action = "eval"
 	a.fsBase = runner.DEFAULT_FS_BASE
+	a.listen = runner.DEFAULT_SERVE_ADDRESS
+	a.tokenFile = runner.DEFAULT_SERVE_TOKEN_FILE
+	a.vars = map[string]string{}
 
 	args := os.Args
+	for _, arg := range args {
+		// Re-evaluate even when cached results are fresh
+		if arg == "--force" {
+			a.force = true
+		}
+	}
+
 	for i := 0; i < len(args)-1; i++ {
 		if args[i] == "--action" {
 			a.action = args[i+1]
@@ -54,12 +74,69 @@ func getArgs() args {
 			continue
 		}
 
+		if args[i] == "--evalPage" {
+			a.pageId = args[i+1]
+			i++
+			continue
+		}
+
 		if args[i] == "--metaFile" {
 			a.panelMetaOut = args[i+1]
 			i++
 			continue
 		}
 
+		if args[i] == "--progressFile" {
+			a.progressFile = args[i+1]
+			i++
+			continue
+		}
+
+		if args[i] == "--logLevel" {
+			level, err := runner.ParseLogLevel(args[i+1])
+			if err != nil {
+				runner.Fatalln("Bad --logLevel: %s", err)
+			}
+			runner.MinLogLevel = level
+			i++
+			continue
+		}
+
+		if args[i] == "--logFormat" {
+			switch args[i+1] {
+			case "json":
+				runner.LogJSON = true
+			case "text":
+				runner.LogJSON = false
+			default:
+				runner.Fatalln("Bad --logFormat, expected json or text: %s", args[i+1])
+			}
+			i++
+			continue
+		}
+
+		if args[i] == "--listen" {
+			a.listen = args[i+1]
+			i++
+			continue
+		}
+
+		if args[i] == "--tokenFile" {
+			a.tokenFile = args[i+1]
+			i++
+			continue
+		}
+
+		if args[i] == "--var" {
+			name, value, err := runner.ParseVariableOverride(args[i+1])
+			if err != nil {
+				runner.Fatalln("Bad --var: %s", err)
+			}
+			a.vars[name] = value
+			i++
+			continue
+		}
+
 		if args[i] == "--settingsFile" {
 			a.settingsFile = args[i+1]
 			i++
@@ -67,55 +144,158 @@ func getArgs() args {
 		}
 	}
 
+	if a.settingsFile == "" {
+		a.settingsFile = runner.SettingsFileDefaultLocation
+	}
+
+	// Projects and panels are passed per request to the server
+	if a.action == "serve" {
+		return a
+	}
+
 	if a.projectId == "" {
 		runner.Fatalln("No project id given.")
 	}
 
-	if a.panelId == "" {
+	if a.panelId == "" && a.action == "eval" {
 		runner.Fatalln("No panel id given.")
 	}
 
-	if a.panelMetaOut == "" {
+	if a.pageId == "" && a.action == "evalPage" {
+		runner.Fatalln("No page id given.")
+	}
+
+	// Scheduled runs are recorded next to the project instead
+	if a.panelMetaOut == "" && a.action != "schedule" {
 		runner.Fatalln("No panel meta out given.")
 	}
 
-	if a.settingsFile == "" {
-		a.settingsFile = runner.SettingsFileDefaultLocation
+	if a.progressFile == "" && a.panelMetaOut != "" {
+		a.progressFile = a.panelMetaOut + ".progress"
 	}
 
 	return a
 }
 
-func eval(ec runner.EvalContext, projectId, panelId, panelMetaOut string) {
-	errToWrite, output := ec.Eval(projectId, panelId)
-	if errToWrite != nil {
-		runner.Logln("Failed to eval: %s", errToWrite)
+// Explicitly don't fail on eval errors so that the parent can read
+// the exception from disk.
+func toDSError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	runner.Logln("Failed to eval: %s", err)
 
-		if _, ok := errToWrite.(*runner.DSError); !ok {
-			errToWrite = runner.Edse(errToWrite)
-			errToWrite.(*runner.DSError).Stack = "Unknown"
+	if _, ok := err.(*runner.DSError); !ok {
+		err = runner.Edse(err)
+		err.(*runner.DSError).Stack = "Unknown"
+	}
+
+	return err
+}
+
+// Successful evals include everything known about the results so
+// the caller doesn't need to reread them.
+func panelMeta(err error, result runner.PanelResult) any {
+	if err != nil || result.Elapsed == nil {
+		return map[string]any{
+			"exception": toDSError(err),
+			"stdout":    result.Stdout,
+			"logs":      result.Logs,
 		}
+	}
+
+	return &result
+}
+
+func eval(ctx context.Context, ec runner.EvalContext, projectId, panelId, panelMetaOut string) {
+	errToWrite, result := ec.Eval(ctx, projectId, panelId)
+
+	err := runner.WriteJSONFile(panelMetaOut, panelMeta(errToWrite, result))
+	if err != nil {
+		runner.Fatalln("Could not write panel meta out: %s", err)
+	}
 
-		// Explicitly don't fail here so that the parent can read the exception from disk
+}
+
+func writePanelsMeta(panelMetaOut string, errToWrite error, outputs map[string]runner.PanelEvalOutput) {
+	panels := map[string]any{}
+	for panelId, output := range outputs {
+		result := output.Result
+		result.Stdout = output.Stdout
+		panels[panelId] = panelMeta(output.Err, result)
 	}
 
 	err := runner.WriteJSONFile(panelMetaOut, map[string]any{
-		"exception": errToWrite,
-		"stdout":    output,
+		"exception": toDSError(errToWrite),
+		"panels":    panels,
 	})
 	if err != nil {
-		runner.Fatalln("Could not write panel meta out: %s", errToWrite)
+		runner.Fatalln("Could not write panel meta out: %s", err)
 	}
+}
 
+func evalPage(ctx context.Context, ec runner.EvalContext, projectId, pageId, panelMetaOut string) {
+	outputs, err := ec.EvalPage(ctx, projectId, pageId)
+	writePanelsMeta(panelMetaOut, err, outputs)
+}
+
+func evalProject(ctx context.Context, ec runner.EvalContext, projectId, panelMetaOut string) {
+	outputs, err := ec.EvalProject(ctx, projectId)
+	writePanelsMeta(panelMetaOut, err, outputs)
+}
+
+func serve(ctx context.Context, settings runner.Settings, fsBase, listen, tokenFile string) {
+	token, err := runner.LoadServeToken(tokenFile)
+	if err != nil {
+		runner.Fatalln("Could not load token from %s: %s", tokenFile, err)
+	}
+
+	l, err := runner.ListenRPC(listen)
+	if err != nil {
+		runner.Fatalln("Could not listen on %s: %s", listen, err)
+	}
+
+	es := runner.NewEvalServer(settings, fsBase, token)
+	srv := &http.Server{Handler: es}
+
+	go func() {
+		<-ctx.Done()
+		runner.Logln("Shutting down")
+		srv.Close()
+	}()
+
+	runner.Logln("Listening on %s, token is in %s", l.Addr(), tokenFile)
+	err = srv.Serve(l)
+	if err != nil && err != http.ErrServerClosed {
+		runner.Logln("Server stopped: %s", err)
+	}
+
+	err = es.Close()
+	if err != nil {
+		runner.Logln("Could not close connections: %s", err)
+	}
+}
+
+func schedule(ctx context.Context, ec runner.EvalContext, projectId string) {
+	s, err := runner.NewScheduler(ec, projectId)
+	if err != nil {
+		runner.Fatalln("Could not load schedule history: %s", err)
+	}
+
+	runner.Logln("Running schedules for %s", projectId)
+	err = s.Run(ctx)
+	if err != nil {
+		runner.Fatalln("Could not run schedules: %s", err)
+	}
 }
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
 	runner.Verbose = true
-	runner.Logln(APP_NAME + " " + VERSION)
-
 	args := getArgs()
+	runner.Logln(APP_NAME + " " + VERSION)
 
 	settings, err := runner.LoadSettings(args.settingsFile)
 	if err != nil {
@@ -123,11 +303,41 @@ func main() {
 		settings = runner.DefaultSettings
 	}
 
-	ec := runner.NewEvalContext(*settings, args.fsBase)
+	ec := runner.NewEvalContext(*settings, args.fsBase).WithVariables(args.vars).WithForce(args.force)
+
+	// Stop evaluating on Ctrl-C or when the desktop app kills the
+	// runner. The meta file is still written so the parent sees the
+	// panel was cancelled.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Progress is only interesting while evaluating so the file is
+	// gone once the meta file is written.
+	if args.progressFile != "" && args.action != "serve" && args.action != "schedule" {
+		ec = ec.WithProgress()
+		progressCtx, stopProgress := context.WithCancel(ctx)
+		progressDone := make(chan struct{})
+		go func() {
+			ec.WriteProgressFile(progressCtx, args.progressFile, time.Second)
+			close(progressDone)
+		}()
+		defer func() {
+			stopProgress()
+			<-progressDone
+		}()
+	}
 
 	switch args.action {
 	case "eval":
-		eval(ec, args.projectId, args.panelId, args.panelMetaOut)
+		eval(ctx, ec, args.projectId, args.panelId, args.panelMetaOut)
+	case "evalPage":
+		evalPage(ctx, ec, args.projectId, args.pageId, args.panelMetaOut)
+	case "evalProject":
+		evalProject(ctx, ec, args.projectId, args.panelMetaOut)
+	case "schedule":
+		schedule(ctx, ec, args.projectId)
+	case "serve":
+		serve(ctx, *settings, args.fsBase, args.listen, args.tokenFile)
 	default:
 		runner.Fatalln("Unknown runner action: " + args.action)
 	}
@@ -0,0 +1,290 @@
+package runner
+
+import (
+	"container/heap"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Columns after this many don't get statistics so wide results
+// don't use unbounded memory.
+var columnStatsMaxColumns = 100
+
+const (
+	// Values reported in TopValues
+	columnStatsTopK = 10
+	// Values counted to find the top ones (the space-saving
+	// algorithm). More is more accurate.
+	columnStatsTracked = 100
+	// Longer strings aren't counted as top values
+	columnStatsMaxTopValueLength = 256
+	// 16384 registers, about 0.8% error
+	hllPrecision = 14
+)
+
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+func (h *hyperLogLog) add(x uint64) {
+	i := x >> (64 - hllPrecision)
+	// The guard bit keeps rho in range when the rest of x is zero
+	w := x<<hllPrecision | 1<<(hllPrecision-1)
+	rho := uint8(bits.LeadingZeros64(w)) + 1
+	if rho > h.registers[i] {
+		h.registers[i] = rho
+	}
+}
+
+func (h *hyperLogLog) count() int64 {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// Linear counting is better for small counts
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return int64(math.Round(estimate))
+}
+
+type valueCounter struct {
+	key   string
+	value any
+	count int64
+	index int
+}
+
+// Min-heap of counters so the least common is replaced first.
+type topValues []*valueCounter
+
+func (t topValues) Len() int           { return len(t) }
+func (t topValues) Less(i, j int) bool { return t[i].count < t[j].count }
+func (t topValues) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+	t[i].index = i
+	t[j].index = j
+}
+func (t *topValues) Push(x any) {
+	c := x.(*valueCounter)
+	c.index = len(*t)
+	*t = append(*t, c)
+}
+func (t *topValues) Pop() any {
+	old := *t
+	c := old[len(old)-1]
+	*t = old[:len(old)-1]
+	return c
+}
+
+type columnStatsAccumulator struct {
+	nonNull  int64
+	distinct hyperLogLog
+	top      topValues
+	topIndex map[string]*valueCounter
+
+	numbers            bool
+	minNumber          float64
+	maxNumber          float64
+	dates              bool
+	minDate, maxDate   time.Time
+	minDateS, maxDateS string
+	strings            bool
+	minLength          int64
+	maxLength          int64
+}
+
+func (a *columnStatsAccumulator) addTop(key string, value any) {
+	if c, ok := a.topIndex[key]; ok {
+		c.count++
+		heap.Fix(&a.top, c.index)
+		return
+	}
+
+	if len(a.top) < columnStatsTracked {
+		c := &valueCounter{key: key, value: value, count: 1}
+		heap.Push(&a.top, c)
+		a.topIndex[key] = c
+		return
+	}
+
+	// The new value takes over the least common one's count
+	c := a.top[0]
+	delete(a.topIndex, c.key)
+	c.key = key
+	c.value = value
+	c.count++
+	a.topIndex[key] = c
+	heap.Fix(&a.top, 0)
+}
+
+func (a *columnStatsAccumulator) addString(s string) {
+	n := int64(utf8.RuneCountInString(s))
+	if !a.strings || n < a.minLength {
+		a.minLength = n
+	}
+	if !a.strings || n > a.maxLength {
+		a.maxLength = n
+	}
+	a.strings = true
+
+	t, ok := parseDatetime(s)
+	if !ok && len(s) == 10 {
+		var err error
+		t, err = time.Parse("2006-01-02", s)
+		ok = err == nil
+	}
+	if !ok {
+		return
+	}
+
+	if !a.dates || t.Before(a.minDate) {
+		a.minDate, a.minDateS = t, s
+	}
+	if !a.dates || t.After(a.maxDate) {
+		a.maxDate, a.maxDateS = t, s
+	}
+	a.dates = true
+}
+
+func (a *columnStatsAccumulator) addNumber(f float64) {
+	if !a.numbers || f < a.minNumber {
+		a.minNumber = f
+	}
+	if !a.numbers || f > a.maxNumber {
+		a.maxNumber = f
+	}
+	a.numbers = true
+}
+
+func (a *columnStatsAccumulator) add(seed maphash.Seed, v any) {
+	value, _, ok := columnarValue(v)
+	if !ok {
+		// Nested objects and arrays only count as there
+		a.nonNull++
+		return
+	}
+	if value == nil {
+		return
+	}
+	a.nonNull++
+
+	// Keys are prefixed by type so 1 and "1" are different values
+	var key string
+	switch t := value.(type) {
+	case bool:
+		key = "b" + strconv.FormatBool(t)
+	case float64:
+		key = "n" + strconv.FormatFloat(t, 'g', -1, 64)
+		a.addNumber(t)
+	case int64:
+		key = "n" + strconv.FormatInt(t, 10)
+		a.addNumber(float64(t))
+	case string:
+		key = "s" + t
+		a.addString(t)
+	}
+
+	a.distinct.add(maphash.String(seed, key))
+	if len(key) <= columnStatsMaxTopValueLength {
+		a.addTop(key, value)
+	}
+}
+
+func (a *columnStatsAccumulator) stats(rows int64) ColumnStats {
+	s := ColumnStats{
+		NullCount:     rows - a.nonNull,
+		DistinctCount: a.distinct.count(),
+	}
+
+	if a.numbers {
+		s.Min = a.minNumber
+		s.Max = a.maxNumber
+	} else if a.dates {
+		s.Min = a.minDateS
+		s.Max = a.maxDateS
+	}
+
+	if a.strings {
+		minLength, maxLength := a.minLength, a.maxLength
+		s.MinLength = &minLength
+		s.MaxLength = &maxLength
+	}
+
+	top := append(topValues{}, a.top...)
+	sort.SliceStable(top, func(i, j int) bool {
+		return top[i].count > top[j].count
+	})
+	for i, c := range top {
+		if i == columnStatsTopK {
+			break
+		}
+
+		s.TopValues = append(s.TopValues, ValueCount{Value: c.value, Count: c.count})
+	}
+
+	return s
+}
+
+// Accumulates statistics about each top-level column of the object
+// rows written.
+type columnStatsCollector struct {
+	seed    maphash.Seed
+	rows    int64
+	columns map[string]*columnStatsAccumulator
+}
+
+func newColumnStatsCollector() *columnStatsCollector {
+	return &columnStatsCollector{
+		seed:    maphash.MakeSeed(),
+		columns: map[string]*columnStatsAccumulator{},
+	}
+}
+
+func (c *columnStatsCollector) add(row any) {
+	m, ok := row.(map[string]any)
+	if !ok {
+		return
+	}
+
+	c.rows++
+	for key, v := range m {
+		a, ok := c.columns[key]
+		if !ok {
+			if len(c.columns) >= columnStatsMaxColumns {
+				continue
+			}
+
+			a = &columnStatsAccumulator{topIndex: map[string]*valueCounter{}}
+			c.columns[key] = a
+		}
+
+		a.add(c.seed, v)
+	}
+}
+
+func (c *columnStatsCollector) stats() map[string]ColumnStats {
+	if c.rows == 0 {
+		return nil
+	}
+
+	stats := map[string]ColumnStats{}
+	for key, a := range c.columns {
+		stats[key] = a.stats(c.rows)
+	}
+
+	return stats
+}
@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_hyperLogLog(t *testing.T) {
+	c := newColumnStatsCollector()
+	for _, n := range []int{0, 1, 100, 10_000, 200_000} {
+		c.columns = map[string]*columnStatsAccumulator{}
+		c.rows = 0
+		for i := 0; i < n; i++ {
+			// Every value twice, duplicates shouldn't count
+			c.add(map[string]any{"a": float64(i)})
+			c.add(map[string]any{"a": float64(i)})
+		}
+
+		if n == 0 {
+			assert.Nil(t, c.stats())
+			continue
+		}
+
+		got := c.stats()["a"].DistinctCount
+		assert.LessOrEqual(t, math.Abs(float64(got-int64(n))), float64(n)*0.05, n)
+	}
+}
+
+func Test_columnStatsCollector(t *testing.T) {
+	c := newColumnStatsCollector()
+	rows := []any{
+		map[string]any{"n": float64(3), "s": "héllo", "d": "2022-02-05", "t": "2022-02-05T10:00:00Z", "o": map[string]any{"x": 1}},
+		map[string]any{"n": int64(-1), "s": "a", "d": "2021-12-31", "t": "2022-02-05T09:00:00+02:00", "o": nil},
+		map[string]any{"n": nil, "s": "a", "d": "2023-01-01"},
+		map[string]any{"s": "a", "mixed": "2022-01-01"},
+		map[string]any{"mixed": float64(10)},
+		// Only object rows are counted
+		[]any{1, 2},
+	}
+	for _, row := range rows {
+		c.add(row)
+	}
+
+	stats := c.stats()
+	one, five := int64(1), int64(5)
+
+	n := stats["n"]
+	assert.Equal(t, int64(3), n.NullCount)
+	assert.Equal(t, float64(-1), n.Min)
+	assert.Equal(t, float64(3), n.Max)
+	assert.Equal(t, int64(2), n.DistinctCount)
+	assert.Nil(t, n.MinLength)
+
+	s := stats["s"]
+	assert.Equal(t, int64(1), s.NullCount)
+	assert.Nil(t, s.Min)
+	assert.Equal(t, &one, s.MinLength)
+	assert.Equal(t, &five, s.MaxLength)
+	assert.Equal(t, int64(2), s.DistinctCount)
+	assert.Equal(t, ValueCount{Value: "a", Count: 3}, s.TopValues[0])
+	assert.Equal(t, ValueCount{Value: "héllo", Count: 1}, s.TopValues[1])
+
+	d := stats["d"]
+	assert.Equal(t, "2021-12-31", d.Min)
+	assert.Equal(t, "2023-01-01", d.Max)
+
+	// Compared as times, not strings
+	tm := stats["t"]
+	assert.Equal(t, "2022-02-05T09:00:00+02:00", tm.Min)
+	assert.Equal(t, "2022-02-05T10:00:00Z", tm.Max)
+
+	// Nested values aren't null but have no other stats
+	o := stats["o"]
+	assert.Equal(t, int64(4), o.NullCount)
+	assert.Equal(t, int64(0), o.DistinctCount)
+
+	// Numbers win over dates
+	mixed := stats["mixed"]
+	assert.Equal(t, float64(10), mixed.Min)
+	assert.Equal(t, float64(10), mixed.Max)
+}
+
+func Test_columnStatsCollector_topValues(t *testing.T) {
+	c := newColumnStatsCollector()
+	// A few common values among many rare ones
+	for i := 0; i < 10_000; i++ {
+		v := fmt.Sprintf("rare-%d", i)
+		if i%10 == 0 {
+			v = fmt.Sprintf("common-%d", i%30)
+		}
+		c.add(map[string]any{"a": v})
+	}
+
+	top := c.stats()["a"].TopValues
+	assert.Equal(t, columnStatsTopK, len(top))
+	var values []any
+	for _, vc := range top[:3] {
+		values = append(values, vc.Value)
+		assert.GreaterOrEqual(t, vc.Count, int64(333))
+	}
+	assert.ElementsMatch(t, []any{"common-0", "common-10", "common-20"}, values)
+}
+
+func Test_columnStatsCollector_maxColumns(t *testing.T) {
+	defer func(n int) { columnStatsMaxColumns = n }(columnStatsMaxColumns)
+	columnStatsMaxColumns = 2
+
+	c := newColumnStatsCollector()
+	c.add(map[string]any{"a": 1, "b": 2})
+	c.add(map[string]any{"c": 3})
+	assert.Equal(t, 2, len(c.stats()))
+}
+
+func Test_evalPanel_columnStats(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	panel := PanelInfo{
+		Id:      newId(),
+		Name:    "csv",
+		Type:    LiteralPanel,
+		Content: "a,b\n1,x\n3,y\n2,x",
+		LiteralPanelInfo: &LiteralPanelInfo{
+			Literal: LiteralPanelInfoLiteral{
+				ContentTypeInfo: ContentTypeInfo{Type: "text/csv"},
+			},
+		},
+	}
+	project := &ProjectState{
+		Id:    "column-stats-test",
+		Pages: []ProjectPage{{Panels: []PanelInfo{panel}}},
+	}
+
+	err, _ := ec.evalPanel(context.Background(), project, 0, &project.Pages[0].Panels[0])
+	assert.Nil(t, err)
+
+	stats := project.Pages[0].Panels[0].ResultMeta.ColumnStats
+	// CSV values are all strings
+	one := int64(1)
+	assert.Nil(t, stats["a"].Min)
+	assert.Equal(t, &one, stats["a"].MaxLength)
+	assert.Equal(t, int64(3), stats["a"].DistinctCount)
+	assert.Equal(t, ValueCount{Value: "x", Count: 2}, stats["b"].TopValues[0])
+
+	out, err := ec.evalMacros(`{{ DM_getPanelStats("csv").b.topValues.0.value }} {{ DM_getPanelStats("0").a.distinctCount|floatformat:0 }}`, project, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "x 3", out)
+}
@@ -0,0 +1,541 @@
+package runner
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Key of the footer metadata holding the schema and row count.
+const columnarMetaKey = "datastation"
+
+// Rows buffered to pick column types before anything is written.
+const columnarSchemaRows = 1_000
+
+type columnarColumn struct {
+	Name    string        `json:"name"`
+	Type    ScalarName    `json:"type"`
+	Subtype ScalarSubtype `json:"subtype,omitempty"`
+	// Some row had null or no value for this column
+	Nullable bool `json:"nullable"`
+}
+
+type columnarMeta struct {
+	Columns []columnarColumn `json:"columns"`
+	Rows    int64            `json:"rows"`
+	// The JSON results this is a copy of, if any. A columnar file
+	// that doesn't match its JSON results is out of date.
+	ResultsSize    int64     `json:"resultsSize,omitempty"`
+	ResultsModTime time.Time `json:"resultsModTime,omitempty"`
+}
+
+func (m columnarMeta) Shape() Shape {
+	children := map[string]Shape{}
+	for _, c := range m.Columns {
+		s := Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: c.Type, Subtype: c.Subtype}}
+		if c.Nullable && c.Type != NullScalar {
+			s = Shape{Kind: VariedKind, VariedShape: &VariedShape{Children: []Shape{s, NullShape}}}
+		}
+		children[c.Name] = s
+	}
+
+	return Shape{
+		Kind: ArrayKind,
+		ArrayShape: &ArrayShape{
+			Children: Shape{Kind: ObjectKind, ObjectShape: &ObjectShape{Children: children}},
+		},
+	}
+}
+
+// Stores an array of flat objects as Parquet so readers can load
+// only the columns they need. Anything else (nested values,
+// namespaces, columns changing type) can't be stored and the writer
+// gives up, see Failed.
+type ColumnarResultItemWriter struct {
+	fileName string
+	fw       source.ParquetFile
+	pw       *writer.CSVWriter
+	meta     columnarMeta
+
+	// Internal state
+	index   map[string]int
+	pending []map[string]any
+	failed  bool
+}
+
+func openColumnarResultItemWriter(f string) (*ColumnarResultItemWriter, error) {
+	// Don't leave an older copy around if this one fails
+	err := os.Remove(f)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return &ColumnarResultItemWriter{fileName: f}, nil
+}
+
+// Values are stored the way they'd come back out of the JSON
+// results, except integers are kept as int64 so ones past 2^53 stay
+// exact. Times are strings.
+func columnarValue(v any) (any, ScalarShape, bool) {
+	switch t := v.(type) {
+	case nil:
+		return nil, ScalarShape{Name: NullScalar}, true
+	case bool:
+		return t, ScalarShape{Name: BooleanScalar}, true
+	case string:
+		return t, *stringShape(t).ScalarShape, true
+	case time.Time:
+		return t.Format(time.RFC3339Nano), ScalarShape{Name: StringScalar, Subtype: DatetimeSubtype}, true
+	case float64:
+		return t, *numberShape(t).ScalarShape, true
+	case float32:
+		return float64(t), *numberShape(float64(t)).ScalarShape, true
+	case int:
+		return int64(t), columnarInteger, true
+	case int8:
+		return int64(t), columnarInteger, true
+	case int16:
+		return int64(t), columnarInteger, true
+	case int32:
+		return int64(t), columnarInteger, true
+	case int64:
+		return t, columnarInteger, true
+	case uint:
+		return columnarUint(uint64(t))
+	case uint8:
+		return int64(t), columnarInteger, true
+	case uint16:
+		return int64(t), columnarInteger, true
+	case uint32:
+		return int64(t), columnarInteger, true
+	case uint64:
+		return columnarUint(t)
+	}
+
+	return nil, ScalarShape{}, false
+}
+
+var columnarInteger = ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}
+
+func columnarUint(u uint64) (any, ScalarShape, bool) {
+	if u > math.MaxInt64 {
+		return float64(u), ScalarShape{Name: NumberScalar, Subtype: DecimalSubtype}, true
+	}
+
+	return int64(u), columnarInteger, true
+}
+
+func (cw *ColumnarResultItemWriter) fail() {
+	cw.failed = true
+	cw.pending = nil
+	if cw.pw != nil {
+		cw.pw.WriteStop()
+		cw.fw.Close()
+		cw.pw = nil
+	}
+	os.Remove(cw.fileName)
+}
+
+// True once the results turned out not to fit. Nothing is left on
+// disk then.
+func (cw *ColumnarResultItemWriter) Failed() bool {
+	return cw.failed
+}
+
+func (cw *ColumnarResultItemWriter) WriteRow(r any, written int) error {
+	if cw.failed {
+		return nil
+	}
+
+	row, ok := r.(map[string]any)
+	if !ok {
+		cw.fail()
+		return nil
+	}
+
+	if cw.pw != nil {
+		return cw.write(row)
+	}
+
+	// Rows may be reused by the caller
+	cp := make(map[string]any, len(row))
+	for k, v := range row {
+		cp[k] = v
+	}
+	cw.pending = append(cw.pending, cp)
+	if len(cw.pending) < columnarSchemaRows {
+		return nil
+	}
+
+	return cw.flushPending()
+}
+
+func (cw *ColumnarResultItemWriter) inferSchema() bool {
+	types := map[string]ScalarShape{}
+	var names []string
+	for _, row := range cw.pending {
+		for k, v := range row {
+			_, t, ok := columnarValue(v)
+			if !ok {
+				return false
+			}
+
+			existing, seen := types[k]
+			if !seen {
+				names = append(names, k)
+				types[k] = t
+				continue
+			}
+
+			if existing.Name == NullScalar {
+				types[k] = t
+			} else if t.Name != NullScalar {
+				if t.Name != existing.Name {
+					return false
+				}
+				types[k] = ScalarShape{Name: t.Name, Subtype: mergeSubtypes(existing.Subtype, t.Subtype)}
+			}
+		}
+	}
+
+	sort.Strings(names)
+	cw.index = map[string]int{}
+	for i, name := range names {
+		cw.index[name] = i
+		cw.meta.Columns = append(cw.meta.Columns, columnarColumn{Name: name, Type: types[name].Name, Subtype: types[name].Subtype})
+	}
+
+	return true
+}
+
+func (cw *ColumnarResultItemWriter) flushPending() error {
+	if !cw.inferSchema() {
+		cw.fail()
+		return nil
+	}
+
+	// Names are stored in the metadata since Parquet is picky about
+	// column names.
+	var md []string
+	for i, c := range cw.meta.Columns {
+		name := "name=c" + strconv.Itoa(i)
+		switch c.Type {
+		case BooleanScalar:
+			md = append(md, name+", type=BOOLEAN, repetitiontype=OPTIONAL")
+		case NumberScalar:
+			if c.Subtype == IntegerSubtype {
+				md = append(md, name+", type=INT64, repetitiontype=OPTIONAL")
+			} else {
+				md = append(md, name+", type=DOUBLE, repetitiontype=OPTIONAL")
+			}
+		default:
+			md = append(md, name+", type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL")
+		}
+	}
+
+	var err error
+	cw.fw, err = local.NewLocalFileWriter(cw.fileName)
+	if err != nil {
+		return edse(err)
+	}
+
+	cw.pw, err = writer.NewCSVWriter(md, cw.fw, int64(preferredParallelism))
+	if err != nil {
+		cw.fw.Close()
+		return edse(err)
+	}
+
+	pending := cw.pending
+	cw.pending = nil
+	for _, row := range pending {
+		err := cw.write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cw *ColumnarResultItemWriter) write(row map[string]any) error {
+	// The writer holds on to records until the row group is flushed
+	record := make([]any, len(cw.meta.Columns))
+	for k, v := range row {
+		i, ok := cw.index[k]
+		if !ok {
+			// New column after the schema was written
+			cw.fail()
+			return nil
+		}
+
+		value, t, ok := columnarValue(v)
+		c := &cw.meta.Columns[i]
+		if !ok || (t.Name != NullScalar && t.Name != c.Type) {
+			cw.fail()
+			return nil
+		}
+
+		if t.Name != NullScalar {
+			// The column's storage type is fixed once it's written
+			subtype := mergeSubtypes(c.Subtype, t.Subtype)
+			if c.Subtype == IntegerSubtype && subtype != IntegerSubtype {
+				cw.fail()
+				return nil
+			}
+			c.Subtype = subtype
+		}
+
+		switch n := value.(type) {
+		case float64:
+			if c.Subtype == IntegerSubtype {
+				value = int64(n)
+			}
+		case int64:
+			if c.Subtype != IntegerSubtype {
+				value = float64(n)
+			}
+		}
+
+		record[i] = value
+	}
+
+	for i, v := range record {
+		if v == nil {
+			cw.meta.Columns[i].Nullable = true
+		}
+	}
+
+	cw.meta.Rows++
+	return cw.pw.Write(record)
+}
+
+func (cw *ColumnarResultItemWriter) SetNamespace(ns string) error {
+	cw.fail()
+	return nil
+}
+
+func (cw *ColumnarResultItemWriter) Shape(id string, sampleSize int) (*Shape, error) {
+	if cw.failed {
+		return nil, edsef("Results could not be stored as columns")
+	}
+
+	s := cw.meta.Shape()
+	return &s, nil
+}
+
+func (cw *ColumnarResultItemWriter) Close() error {
+	if cw.failed {
+		return nil
+	}
+
+	if cw.pw == nil {
+		// Nothing worth reading columns from
+		if len(cw.pending) == 0 {
+			cw.fail()
+			return nil
+		}
+
+		err := cw.flushPending()
+		if err != nil || cw.failed {
+			return err
+		}
+	}
+
+	bs, err := jsonMarshal(cw.meta)
+	if err != nil {
+		return err
+	}
+	v := string(bs)
+	cw.pw.Footer.KeyValueMetadata = append(cw.pw.Footer.KeyValueMetadata, &parquet.KeyValue{Key: columnarMetaKey, Value: &v})
+
+	err = cw.pw.WriteStop()
+	if err != nil {
+		cw.fw.Close()
+		return edse(err)
+	}
+
+	return cw.fw.Close()
+}
+
+// Records which JSON results this is a copy of. Must be called
+// after the JSON results are closed and before Close.
+func (cw *ColumnarResultItemWriter) setResultsFile(f string) error {
+	fi, err := os.Stat(f)
+	if err != nil {
+		return err
+	}
+
+	cw.meta.ResultsSize = fi.Size()
+	cw.meta.ResultsModTime = fi.ModTime()
+	return nil
+}
+
+func (ec EvalContext) getColumnarResultsFile(projectId, panelId string) string {
+	return ec.GetPanelResultsFile(projectId, panelId) + ".parquet"
+}
+
+type columnarReader struct {
+	fr   source.ParquetFile
+	pr   *reader.ParquetReader
+	meta columnarMeta
+}
+
+func (cr *columnarReader) Close() {
+	cr.pr.ReadStop()
+	cr.fr.Close()
+}
+
+// Returns nil when the panel has no columnar copy of its results or
+// the copy is out of date.
+func (ec EvalContext) openColumnarResults(projectId, panelId string) *columnarReader {
+	fi, err := os.Stat(ec.GetPanelResultsFile(projectId, panelId))
+	if err != nil {
+		return nil
+	}
+
+	f := ec.getColumnarResultsFile(projectId, panelId)
+	if _, err := os.Stat(f); err != nil {
+		return nil
+	}
+
+	fr, err := local.NewLocalFileReader(f)
+	if err != nil {
+		Warnln("Could not open columnar results: %s", err)
+		return nil
+	}
+
+	pr, err := reader.NewParquetColumnReader(fr, int64(preferredParallelism))
+	if err != nil {
+		Warnln("Could not read columnar results: %s", err)
+		fr.Close()
+		return nil
+	}
+
+	cr := &columnarReader{fr: fr, pr: pr}
+	found := false
+	for _, kv := range pr.Footer.KeyValueMetadata {
+		if kv.Key == columnarMetaKey && kv.Value != nil {
+			found = jsonUnmarshal([]byte(*kv.Value), &cr.meta) == nil
+		}
+	}
+
+	if !found ||
+		cr.meta.ResultsSize != fi.Size() ||
+		!cr.meta.ResultsModTime.Equal(fi.ModTime()) ||
+		cr.meta.Rows != pr.GetNumRows() {
+		cr.Close()
+		return nil
+	}
+
+	return cr
+}
+
+// Streams rows with only the requested columns, skipping the first
+// offset rows. A negative limit reads every row after that.
+func (cr *columnarReader) rows(columns []string, offset, limit int) *RowIterator {
+	index := map[string]int{}
+	for i, c := range cr.meta.Columns {
+		index[c.Name] = i
+	}
+
+	remaining := cr.meta.Rows - int64(offset)
+	if remaining < 0 {
+		remaining = 0
+	}
+	if limit >= 0 && int64(limit) < remaining {
+		remaining = int64(limit)
+	}
+
+	return newRowIterator(func(emit func(map[string]any) bool) error {
+		defer cr.Close()
+
+		var read []int
+		seen := map[int]bool{}
+		for _, c := range columns {
+			if i, ok := index[c]; ok && !seen[i] {
+				seen[i] = true
+				read = append(read, i)
+				if offset > 0 {
+					cr.pr.SkipRowsByIndex(int64(i), int64(offset))
+				}
+			}
+		}
+
+		batch := int64(1_000)
+		done := int64(0)
+		values := map[int][]any{}
+		for remaining > 0 {
+			n := batch
+			if remaining < n {
+				n = remaining
+			}
+
+			for _, i := range read {
+				vs, _, _, err := cr.pr.ReadColumnByIndex(int64(i), n)
+				if err != nil {
+					return edsef("Failed to read columnar results at row %d: %s", int64(offset)+done, err)
+				}
+				values[i] = vs
+			}
+
+			for j := int64(0); j < n; j++ {
+				row := make(map[string]any, len(columns))
+				for _, c := range columns {
+					i, ok := index[c]
+					if !ok || j >= int64(len(values[i])) {
+						row[c] = nil
+						continue
+					}
+
+					row[c] = values[i][j]
+				}
+
+				if !emit(row) {
+					return nil
+				}
+			}
+
+			remaining -= n
+			done += n
+		}
+
+		return nil
+	})
+}
+
+// Reads only the requested columns when the panel has an up-to-date
+// columnar copy of its results. Returns false otherwise and the JSON
+// results need to be read instead.
+func (ec EvalContext) loadColumnarPanel(projectId, panelId string, columns []string, offset, limit int) (*RowIterator, bool) {
+	cr := ec.openColumnarResults(projectId, panelId)
+	if cr == nil {
+		return nil, false
+	}
+
+	return cr.rows(columns, offset, limit), true
+}
+
+// Reads the shape saved when the results were written, or from the
+// columnar copy of the results. Only scans the JSON results when
+// there's neither.
+func (ec EvalContext) shapeFromResults(projectId, panelId string) (*Shape, error) {
+	if s := ec.loadResultsShape(projectId, panelId); s != nil {
+		return s, nil
+	}
+
+	if cr := ec.openColumnarResults(projectId, panelId); cr != nil {
+		defer cr.Close()
+		s := cr.meta.Shape()
+		return &s, nil
+	}
+
+	return ShapeFromFile(ec.GetPanelResultsFile(projectId, panelId), panelId, 100)
+}
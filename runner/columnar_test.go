@@ -0,0 +1,215 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeColumnarTestResults(t *testing.T, ec EvalContext, projectId, panelId string, rows []map[string]any) {
+	rw, err := ec.GetResultWriter(context.Background(), projectId, panelId)
+	assert.Nil(t, err)
+	for _, row := range rows {
+		assert.Nil(t, rw.WriteRow(row))
+	}
+	assert.Nil(t, rw.Close())
+}
+
+func Test_ColumnarResultItemWriter(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+	ec.settings.ColumnarResults = true
+
+	projectId, panelId := "columnar", newId()
+	defer os.Remove(ec.GetPanelResultsFile(projectId, panelId))
+	defer os.Remove(ec.getColumnarResultsFile(projectId, panelId))
+
+	day := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	writeColumnarTestResults(t, ec, projectId, panelId, []map[string]any{
+		{"a": 1, "b": "x", "c": true, "d": day},
+		{"a": 2.5, "b": nil, "c": false},
+		{"a": int64(3), "b": "z", "c": true},
+	})
+
+	cr := ec.openColumnarResults(projectId, panelId)
+	assert.NotNil(t, cr)
+	assert.Equal(t, int64(3), cr.meta.Rows)
+	assert.Equal(t, []columnarColumn{
+		{Name: "a", Type: NumberScalar, Subtype: DecimalSubtype},
+		{Name: "b", Type: StringScalar, Nullable: true},
+		{Name: "c", Type: BooleanScalar},
+		{Name: "d", Type: StringScalar, Subtype: DatetimeSubtype, Nullable: true},
+	}, cr.meta.Columns)
+	cr.Close()
+
+	rows, ok := ec.loadColumnarPanel(projectId, panelId, []string{"b", "a", "missing"}, 1, 5)
+	assert.True(t, ok)
+	var got []map[string]any
+	for rows.Next() {
+		got = append(got, rows.Row())
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []map[string]any{
+		{"a": 2.5, "b": nil, "missing": nil},
+		{"a": float64(3), "b": "z", "missing": nil},
+	}, got)
+
+	rows, ok = ec.loadColumnarPanel(projectId, panelId, []string{"d"}, 0, -1)
+	assert.True(t, ok)
+	assert.True(t, rows.Next())
+	assert.Equal(t, "2022-03-01T00:00:00Z", rows.Row()["d"])
+	rows.Close()
+
+	s, err := ec.shapeFromResults(projectId, panelId)
+	assert.Nil(t, err)
+	assert.True(t, ShapeIsObjectArray(*s))
+	assert.Equal(t, NumberScalar, s.ArrayShape.Children.ObjectShape.Children["a"].ScalarShape.Name)
+	assert.Equal(t, VariedKind, s.ArrayShape.Children.ObjectShape.Children["b"].Kind)
+
+	// Out of date once the JSON results change
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, os.WriteFile(ec.GetPanelResultsFile(projectId, panelId), []byte(`[{"a": 1}]`), os.ModePerm))
+	_, ok = ec.loadColumnarPanel(projectId, panelId, []string{"a"}, 0, -1)
+	assert.False(t, ok)
+}
+
+func Test_ColumnarResultItemWriter_unsupported(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+	ec.settings.ColumnarResults = true
+
+	tests := [][]map[string]any{
+		{{"a": map[string]any{"b": 1}}},
+		{{"a": []any{1}}},
+		{{"a": 1}, {"a": "1"}},
+		{},
+	}
+
+	for _, rows := range tests {
+		projectId, panelId := "columnar", newId()
+		writeColumnarTestResults(t, ec, projectId, panelId, rows)
+
+		_, err := os.Stat(ec.getColumnarResultsFile(projectId, panelId))
+		assert.True(t, os.IsNotExist(err), rows)
+		_, ok := ec.loadColumnarPanel(projectId, panelId, []string{"a"}, 0, -1)
+		assert.False(t, ok)
+
+		// The JSON results are unaffected
+		assert.True(t, ec.panelResultsExist(projectId, panelId))
+		os.Remove(ec.GetPanelResultsFile(projectId, panelId))
+	}
+
+	// New columns after the schema is picked
+	projectId, panelId := "columnar", newId()
+	defer os.Remove(ec.GetPanelResultsFile(projectId, panelId))
+	var rows []map[string]any
+	for i := 0; i < columnarSchemaRows; i++ {
+		rows = append(rows, map[string]any{"a": i})
+	}
+	rows = append(rows, map[string]any{"a": 1, "b": 2})
+	writeColumnarTestResults(t, ec, projectId, panelId, rows)
+	_, ok := ec.loadColumnarPanel(projectId, panelId, []string{"a"}, 0, -1)
+	assert.False(t, ok)
+
+	// Integer columns can't take decimals once they're written
+	projectId, panelId = "columnar", newId()
+	defer os.Remove(ec.GetPanelResultsFile(projectId, panelId))
+	rows[len(rows)-1] = map[string]any{"a": 1.5}
+	writeColumnarTestResults(t, ec, projectId, panelId, rows)
+	_, ok = ec.loadColumnarPanel(projectId, panelId, []string{"a"}, 0, -1)
+	assert.False(t, ok)
+}
+
+func Test_ColumnarResultItemWriter_integers(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+	ec.settings.ColumnarResults = true
+
+	projectId, panelId := "columnar", newId()
+	defer os.Remove(ec.GetPanelResultsFile(projectId, panelId))
+	defer os.Remove(ec.getColumnarResultsFile(projectId, panelId))
+
+	// Past 2^53 a float64 can't tell these apart
+	big := int64(1<<60 + 1)
+	writeColumnarTestResults(t, ec, projectId, panelId, []map[string]any{
+		{"id": big, "n": 2.0, "day": "2022-03-01"},
+		{"id": uint32(7), "n": 3, "day": nil},
+	})
+
+	cr := ec.openColumnarResults(projectId, panelId)
+	assert.NotNil(t, cr)
+	assert.Equal(t, []columnarColumn{
+		{Name: "day", Type: StringScalar, Subtype: DateSubtype, Nullable: true},
+		{Name: "id", Type: NumberScalar, Subtype: IntegerSubtype},
+		{Name: "n", Type: NumberScalar, Subtype: IntegerSubtype},
+	}, cr.meta.Columns)
+
+	rows := cr.rows([]string{"id", "n"}, 0, -1)
+	var got []map[string]any
+	for rows.Next() {
+		got = append(got, rows.Row())
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []map[string]any{
+		{"id": big, "n": int64(2)},
+		{"id": int64(7), "n": int64(3)},
+	}, got)
+
+	// Subtypes come back with the shape
+	s, err := ec.shapeFromResults(projectId, panelId)
+	assert.Nil(t, err)
+	children := s.ArrayShape.Children.ObjectShape.Children
+	assert.Equal(t, IntegerSubtype, children["id"].ScalarShape.Subtype)
+	assert.Equal(t, DateSubtype, children["day"].VariedShape.Children[0].ScalarShape.Subtype)
+}
+
+func Test_getResultColumns_columnar(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+	ec.settings.ColumnarResults = true
+
+	sourceId := newId()
+	project := &ProjectState{
+		Id: "columnar",
+		Pages: []ProjectPage{
+			{
+				Panels: []PanelInfo{
+					{
+						Id: sourceId,
+						ResultMeta: PanelResult{
+							Shape: Shape{
+								Kind: ArrayKind,
+								ArrayShape: &ArrayShape{
+									Children: Shape{Kind: ObjectKind, ObjectShape: &ObjectShape{}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	defer os.Remove(ec.GetPanelResultsFile(project.Id, sourceId))
+	defer os.Remove(ec.getColumnarResultsFile(project.Id, sourceId))
+
+	var rows []map[string]any
+	for i := 0; i < 2_500; i++ {
+		rows = append(rows, map[string]any{"i": i, "name": "row"})
+	}
+	writeColumnarTestResults(t, ec, project.Id, sourceId, rows)
+
+	tableId := newId()
+	defer os.Remove(ec.GetPanelResultsFile(project.Id, tableId))
+	defer os.Remove(ec.getColumnarResultsFile(project.Id, tableId))
+	err := ec.getResultColumns(context.Background(), project, tableId, sourceId, "", []string{"i"}, 2, 1_000)
+	assert.Nil(t, err)
+
+	var got []map[string]any
+	assert.Nil(t, readJSONFileInto(ec.GetPanelResultsFile(project.Id, tableId), &got))
+	assert.Equal(t, 500, len(got))
+	assert.Equal(t, map[string]any{"i": float64(2_000)}, got[0])
+	assert.Equal(t, map[string]any{"i": float64(2_499)}, got[499])
+}
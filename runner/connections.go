@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/ssh"
+)
+
+// Keeps database pools and SSH clients open between evals. Only used
+// by long-lived runners, a runner that evaluates a single panel and
+// exits opens and closes everything itself.
+//
+// Entries are keyed by connector or server id. When its connection
+// string or credentials change the old pool or client is closed and
+// replaced so edits don't leave connections open.
+type connectionCache struct {
+	mu  sync.Mutex
+	dbs map[string]cachedDB
+	ssh map[string]cachedSSHClient
+}
+
+type cachedDB struct {
+	key string
+	db  *sqlx.DB
+}
+
+type cachedSSHClient struct {
+	key    string
+	client *ssh.Client
+}
+
+func newConnectionCache() *connectionCache {
+	return &connectionCache{
+		dbs: map[string]cachedDB{},
+		ssh: map[string]cachedSSHClient{},
+	}
+}
+
+func (cc *connectionCache) getDB(connectorId, vendor, connStr string, open func() (*sqlx.DB, error)) (*sqlx.DB, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	key := vendor + "\x00" + connStr
+	if cached, ok := cc.dbs[connectorId]; ok {
+		if cached.key == key {
+			return cached.db, nil
+		}
+
+		Logln("Reconnecting database %s, its connection changed", connectorId)
+		cached.db.Close()
+		delete(cc.dbs, connectorId)
+	}
+
+	db, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	cc.dbs[connectorId] = cachedDB{key, db}
+	return db, nil
+}
+
+// Credentials are hashed into the key so changing them opens a new
+// client rather than reusing the old one.
+func sshClientKey(si ServerInfo) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", si.Password.Value, si.Passphrase.Value, si.PrivateKeyFile)
+	credentials := hex.EncodeToString(h.Sum(nil))
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%s", si.Id, si.Address, si.Username, si.Type, credentials)
+}
+
+func (cc *connectionCache) getSSHClient(si ServerInfo, dial func(ServerInfo) (*ssh.Client, error)) (*ssh.Client, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	key := sshClientKey(si)
+	if cached, ok := cc.ssh[si.Id]; ok {
+		if cached.key == key {
+			// The server may have hung up since the last eval
+			_, _, err := cached.client.SendRequest("keepalive@openssh.com", true, nil)
+			if err == nil {
+				return cached.client, nil
+			}
+
+			Logln("Reconnecting to %s: %s", si.Address, err)
+		} else {
+			Logln("Reconnecting to %s, server settings changed", si.Address)
+		}
+
+		cached.client.Close()
+		delete(cc.ssh, si.Id)
+	}
+
+	client, err := dial(si)
+	if err != nil {
+		return nil, err
+	}
+
+	cc.ssh[si.Id] = cachedSSHClient{key, client}
+	return client, nil
+}
+
+func (cc *connectionCache) Close() error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	var firstErr error
+	for id, cached := range cc.dbs {
+		if err := cached.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(cc.dbs, id)
+	}
+
+	for id, cached := range cc.ssh {
+		if err := cached.client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(cc.ssh, id)
+	}
+
+	return firstErr
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sshClientKey(t *testing.T) {
+	si := ServerInfo{Id: "a", Address: "host", Username: "u", Type: SSHPassword, Password: Encrypt{Value: "x", Encrypted: true}}
+	key := sshClientKey(si)
+	assert.Equal(t, key, sshClientKey(si))
+
+	changed := si
+	changed.Password.Value = "y"
+	assert.NotEqual(t, key, sshClientKey(changed))
+
+	changed = si
+	changed.Passphrase.Value = "p"
+	assert.NotEqual(t, key, sshClientKey(changed))
+
+	changed = si
+	changed.PrivateKeyFile = "~/.ssh/id_ed25519"
+	assert.NotEqual(t, key, sshClientKey(changed))
+}
+
+func Test_connectionCache_getDB(t *testing.T) {
+	cc := newConnectionCache()
+	opened := 0
+	open := func() (*sqlx.DB, error) {
+		opened++
+		return sqlx.Open("sqlite3", ":memory:")
+	}
+
+	first, err := cc.getDB("c1", "sqlite3", "a", open)
+	assert.Nil(t, err)
+	again, err := cc.getDB("c1", "sqlite3", "a", open)
+	assert.Nil(t, err)
+	assert.Equal(t, first, again)
+	assert.Equal(t, 1, opened)
+
+	// A changed connection string replaces and closes the old pool
+	replaced, err := cc.getDB("c1", "sqlite3", "b", open)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, replaced)
+	assert.Equal(t, 2, opened)
+	assert.NotNil(t, first.Ping())
+	assert.Nil(t, replaced.Ping())
+
+	other, err := cc.getDB("c2", "sqlite3", "b", open)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, opened)
+
+	assert.Nil(t, cc.Close())
+	assert.NotNil(t, replaced.Ping())
+	assert.NotNil(t, other.Ping())
+	assert.Empty(t, cc.dbs)
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseCron_errors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		_, err := parseCron(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func Test_cronExpr_next(t *testing.T) {
+	utc := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tests := []struct {
+		expr string
+		from string
+		exp  string
+	}{
+		{"* * * * *", "2022-03-01 10:00", "2022-03-01 10:01"},
+		{"*/15 * * * *", "2022-03-01 10:07", "2022-03-01 10:15"},
+		{"5/15 * * * *", "2022-03-01 10:21", "2022-03-01 10:35"},
+		{"0 2 * * *", "2022-03-01 10:00", "2022-03-02 02:00"},
+		{"@daily", "2022-12-31 23:59", "2023-01-01 00:00"},
+		{"@hourly", "2022-03-01 10:00", "2022-03-01 11:00"},
+		{"30 9 * * mon-fri", "2022-03-04 10:00", "2022-03-07 09:30"}, // Friday -> Monday
+		{"0 0 * * 7", "2022-03-01 00:00", "2022-03-06 00:00"},        // Sunday
+		{"0 0 1,15 jan,jul *", "2022-03-01 00:00", "2022-07-01 00:00"},
+		{"0 0 29 2 *", "2022-03-01 00:00", "2024-02-29 00:00"},
+		// Either day field matches when both are restricted
+		{"0 0 13 * fri", "2022-03-01 00:00", "2022-03-04 00:00"},
+		{"0 0 31 2 *", "2022-03-01 00:00", "0001-01-01 00:00"},
+	}
+
+	for _, test := range tests {
+		c, err := parseCron(test.expr)
+		assert.Nil(t, err, test.expr)
+		assert.Equal(t, utc(test.exp), c.next(utc(test.from)), test.expr)
+	}
+}
@@ -2,6 +2,8 @@ package runner
 
 import (
 	"bufio"
+	"context"
+	"database/sql/driver"
 	"fmt"
 	"io"
 	"net"
@@ -45,6 +47,17 @@ var SQLITE_PRAGMAS = []string{
 	"mmap_size = 30000000000",
 }
 
+// Database/sql connectors that can import DM_getPanel tables
+var getPanelCallsAllowed = map[DatabaseConnectorInfoType]bool{
+	MySQLDatabase:      true,
+	SQLiteDatabase:     true,
+	PostgresDatabase:   true,
+	SQLServerDatabase:  true,
+	OracleDatabase:     true,
+	ClickHouseDatabase: true,
+	SnowflakeDatabase:  true,
+}
+
 var defaultPorts = map[DatabaseConnectorInfoType]string{
 	PostgresDatabase:      "5432",
 	MySQLDatabase:         "3306",
@@ -376,7 +389,7 @@ func writeRowFromDatabase(dbInfo DatabaseConnectorInfoDatabase, out *ResultWrite
 				// Default to treating everything as a string
 				row[col] = string(bs)
 				if !wroteFirstRow && !textTypes[t] {
-					Logln("Skipping unknown type: " + s.DatabaseTypeName())
+					out.stats.logln(WarnLevel, "Skipping unknown type: %s", s.DatabaseTypeName())
 				}
 			}
 		}
@@ -385,16 +398,97 @@ func writeRowFromDatabase(dbInfo DatabaseConnectorInfoDatabase, out *ResultWrite
 	return out.WriteRow(row)
 }
 
-func (ec *EvalContext) loadJSONArrayPanel(projectId, panelId string) (chan map[string]any, error) {
+func (ec *EvalContext) loadJSONArrayPanel(projectId, panelId string) (*RowIterator, error) {
 	f := ec.GetPanelResultsFile(projectId, panelId)
 	return loadJSONArrayFileWithPath(f, ec.path)
 }
 
+// Connections through an SSH tunnel can't be reused since the tunnel
+// only lives as long as the eval.
+func (ec EvalContext) openDatabase(connectorId, vendor, connStr string, reusable bool) (*sqlx.DB, func(), error) {
+	open := func() (*sqlx.DB, error) {
+		if vendor == string(ODBCDatabase) {
+			return openODBCDriver(connStr)
+		}
+
+		return sqlx.Open(vendor, connStr)
+	}
+
+	// In-memory SQLite databases disappear with their connection
+	if ec.conns == nil || !reusable || strings.Contains(connStr, ":memory:") {
+		db, err := open()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return db, func() {
+			db.Close()
+		}, nil
+	}
+
+	db, err := ec.conns.getDB(connectorId, vendor, connStr, open)
+	return db, func() {}, err
+}
+
+// Statements that don't return rows, like DDL, have no columns and
+// aren't written. Without namespaces there's nowhere to put result
+// sets after the first so they're only counted.
+func writeResultSets(dbInfo DatabaseConnectorInfoDatabase, w *ResultWriter, rows *sqlx.Rows, namespaced bool, resultSets *int) error {
+	for {
+		columns, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+
+		if len(columns) == 0 {
+			// Some drivers only run the statement once it's read
+			for rows.Next() {
+			}
+		} else {
+			if namespaced {
+				*resultSets++
+				err = w.SetNamespace(fmt.Sprintf("stmt%d", *resultSets))
+				if err != nil {
+					return err
+				}
+			}
+
+			wroteFirstRow := false
+			for rows.Next() {
+				err := writeRowFromDatabase(dbInfo, w, rows, wroteFirstRow)
+				if err != nil {
+					return err
+				}
+
+				wroteFirstRow = true
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if !rows.NextResultSet() {
+			return rows.Err()
+		}
+
+		if !namespaced {
+			skipped := 1
+			for rows.NextResultSet() {
+				skipped++
+			}
+			w.stats.logln(WarnLevel, "Only the first result set was kept, %d more were returned", skipped)
+			return rows.Err()
+		}
+	}
+}
+
 func (ec *EvalContext) EvalDatabasePanelWithWriter(
+	ctx context.Context,
 	project *ProjectState,
 	pageIndex int,
 	panel *PanelInfo,
-	panelResultLoader func(projectId, panelId string) (chan map[string]any, error),
+	panelResultLoader func(projectId, panelId string) (*RowIterator, error),
 	cache CacheSettings,
 	w *ResultWriter,
 ) error {
@@ -413,6 +507,7 @@ func (ec *EvalContext) EvalDatabasePanelWithWriter(
 	}
 
 	dbInfo := connector.Database
+	ec.stats.setConnectorType(dbInfo.Type)
 
 	// A few database types are cool with empty queries
 	if panel.Content == "" &&
@@ -426,6 +521,10 @@ func (ec *EvalContext) EvalDatabasePanelWithWriter(
 		panelResultLoader = ec.loadJSONArrayPanel
 	}
 
+	if len(ec.params) > 0 && !macroParamsAllowed[dbInfo.Type] {
+		return makeErrUnsupported("DM_param() is not yet supported by this connector.")
+	}
+
 	serverId := panel.ServerId
 	if serverId == "" {
 		serverId = connector.ServerId
@@ -441,35 +540,40 @@ func (ec *EvalContext) EvalDatabasePanelWithWriter(
 
 	switch dbInfo.Type {
 	case ElasticsearchDatabase:
-		return ec.evalElasticsearch(panel, dbInfo, server, w)
+		return ec.evalElasticsearch(ctx, panel, dbInfo, server, w)
 	case InfluxDatabase:
-		return ec.evalInfluxQL(panel, dbInfo, server, w)
+		return ec.evalInfluxQL(ctx, panel, dbInfo, server, w)
 	case InfluxFluxDatabase:
-		return ec.evalFlux(panel, dbInfo, server, w)
+		return ec.evalFlux(ctx, panel, dbInfo, server, w)
 	case PrometheusDatabase:
-		return ec.evalPrometheus(panel, dbInfo, server, w)
+		return ec.evalPrometheus(ctx, panel, dbInfo, server, w)
 	case BigQueryDatabase:
-		return ec.evalBigQuery(panel, dbInfo, w)
+		return ec.evalBigQuery(ctx, panel, dbInfo, w)
 	case SplunkDatabase:
-		return evalSplunk(panel, dbInfo, server, w)
+		return ec.evalSplunk(ctx, panel, dbInfo, server, w)
 	case CassandraDatabase, ScyllaDatabase:
-		return ec.evalCQL(panel, dbInfo, server, w)
+		return ec.evalCQL(ctx, panel, dbInfo, server, w)
 	case AthenaDatabase:
-		return ec.evalAthena(panel, dbInfo, w)
+		return ec.evalAthena(ctx, panel, dbInfo, w)
 	case GoogleSheetsDatabase:
-		return ec.evalGoogleSheets(panel, dbInfo, w)
+		return ec.evalGoogleSheets(ctx, panel, dbInfo, w)
 	case AirtableDatabase:
-		return ec.evalAirtable(panel, dbInfo, w)
+		return ec.evalAirtable(ctx, panel, dbInfo, w)
 	case Neo4jDatabase:
-		return ec.evalNeo4j(panel, dbInfo, server, w)
+		return ec.evalNeo4j(ctx, panel, dbInfo, server, w)
 	case MongoDatabase:
-		return ec.evalMongo(panel, dbInfo, server, w)
+		return ec.evalMongo(ctx, panel, dbInfo, server, w)
 	}
 
 	mangleInsert := defaultMangleInsert
 	qt := ansiSQLQuote
-	if dbInfo.Type == PostgresDatabase {
+	switch dbInfo.Type {
+	case PostgresDatabase:
 		mangleInsert = postgresMangleInsert
+	case SQLServerDatabase:
+		mangleInsert = sqlServerMangleInsert
+	case OracleDatabase:
+		mangleInsert = oracleMangleInsert
 	}
 
 	if dbInfo.Type == MySQLDatabase {
@@ -483,9 +587,11 @@ func (ec *EvalContext) EvalDatabasePanelWithWriter(
 		panel.Content,
 		idShapeMap,
 		idMap,
-		dbInfo.Type == MySQLDatabase || dbInfo.Type == SQLiteDatabase || dbInfo.Type == PostgresDatabase,
+		getPanelCallsAllowed[dbInfo.Type],
 		qt,
+		dbInfo.Type,
 		cache.CachePresent,
+		panel.Database.Extra["unnest_arrays"] == "true",
 	)
 	if err != nil {
 		return err
@@ -501,7 +607,7 @@ func (ec *EvalContext) EvalDatabasePanelWithWriter(
 
 		defer os.Remove(tmp.Name())
 
-		err = ec.remoteFileReader(*server, dbInfo.Database, func(r *bufio.Reader) error {
+		err = ec.remoteFileReader(ctx, *server, dbInfo.Database, func(r *bufio.Reader) error {
 			_, err := io.Copy(tmp, r)
 			return err
 		})
@@ -517,7 +623,7 @@ func (ec *EvalContext) EvalDatabasePanelWithWriter(
 		return err
 	}
 
-	return ec.withRemoteConnection(server, host, port, func(proxyHost, proxyPort string) error {
+	return ec.withRemoteConnection(ctx, server, host, port, func(proxyHost, proxyPort string) error {
 		dbInfo.Address = proxyHost + ":" + proxyPort
 		if extra != "" {
 			dbInfo.Address += "?" + extra
@@ -527,78 +633,150 @@ func (ec *EvalContext) EvalDatabasePanelWithWriter(
 			return err
 		}
 
-		var db *sqlx.DB
-		if vendor == string(ODBCDatabase) {
-			db, err = openODBCDriver(connStr)
-		} else {
-			db, err = sqlx.Open(vendor, connStr)
+		ec.stats.setPhase("Connecting to %s", connector.Name)
+		connected := ec.stats.time(connectPhase)
+		db, release, err := ec.openDatabase(connector.Id, vendor, connStr, server == nil)
+		if err != nil {
+			return err
 		}
+		defer release()
+
+		// Imported panels are temporary tables that only exist
+		// on the connection that created them.
+		conn, err := db.Connx(ctx)
 		if err != nil {
 			return err
 		}
+		defer func() {
+			// Don't give a connection with leftover
+			// temporary tables back to a shared pool.
+			if ec.conns != nil && len(panelsToImport) > 0 && !cache.Enabled {
+				_ = conn.Raw(func(any) error {
+					return driver.ErrBadConn
+				})
+			}
+
+			conn.Close()
+		}()
 
 		if vendor == "sqlite3_extended" {
 			for _, pragma := range SQLITE_PRAGMAS {
-				_, err = db.Exec("PRAGMA " + pragma)
+				_, err = conn.ExecContext(ctx, "PRAGMA "+pragma)
 				if err != nil {
 					return err
 				}
 			}
 		}
+		connected()
 
 		preparer := func(q string) (func([]any) error, func(), error) {
-			stmt, err := db.Prepare(mangleInsert(q))
+			// Prepared statements are batches in the ClickHouse
+			// driver, values are bound client-side instead.
+			if dbInfo.Type == ClickHouseDatabase {
+				return func(values []any) error {
+					_, err := conn.ExecContext(ctx, q, values...)
+					return err
+				}, func() {}, nil
+			}
+
+			stmt, err := conn.PrepareContext(ctx, mangleInsert(q))
 			if err != nil {
 				return nil, nil, err
 			}
 
 			return func(values []any) error {
-					_, err := stmt.Exec(values...)
+					_, err := stmt.ExecContext(ctx, values...)
 					return err
 				}, func() {
 					stmt.Close()
 				}, nil
 		}
 
-		wroteFirstRow := false
+		// Imported panels are loaded one at a time
+		importLoader := func(projectId, panelId string) (*RowIterator, error) {
+			var columns []string
+			nested := false
+			for _, p := range panelsToImport {
+				if p.id == panelId {
+					ec.stats.setPhase("Importing DM_getPanel table %s", p.tableName)
+					for _, c := range p.columns {
+						columns = append(columns, c.name)
+					}
+					nested = len(p.children) > 0
+				}
+			}
+
+			// Only the imported columns need to be read, unless
+			// child tables need the nested arrays too
+			if ec.path == "" && !nested {
+				if rows, ok := ec.loadColumnarPanel(projectId, panelId, columns, 0, -1); ok {
+					return rows, nil
+				}
+			}
+
+			return panelResultLoader(projectId, panelId)
+		}
+
+		// Importing is done once the query starts
+		imported := ec.stats.time(importPhase)
 		_, err = importAndRun(
 			func(createTableStmt string) error {
-				_, err := db.Exec(createTableStmt)
+				_, err := conn.ExecContext(ctx, createTableStmt)
 				return err
 			},
 			preparer,
 			func(query string) ([]map[string]any, error) {
-				rows, err := db.Queryx(query)
-				if err != nil {
-					// odbc driver returns an error for an empty result
-					// see https://github.com/alexbrainman/odbc/blob/9c9a2e61c5e2c1a257a51ea49169fc9008c51f0e/odbcstmt.go#L134
-					if vendor == string(ODBCDatabase) && err.Error() == "Stmt did not create a result set" {
-						return nil, nil
-					}
+				imported()
+				ec.stats.setPhase("Running query")
 
-					return nil, err
+				statements := splitSQLStatements(query, dbInfo.Type)
+				if len(statements) == 0 {
+					statements = []string{query}
 				}
 
-				defer rows.Close()
+				// Scripts and procedure calls write each result
+				// set under its own key, other single statements
+				// write their rows as-is.
+				namespaced := len(statements) > 1 || isMultiResultStatement(statements[0], dbInfo.Type)
+				params := ec.params
+				resultSets := 0
+				for i, stmt := range statements {
+					if namespaced {
+						ec.stats.setPhase("Running statement %d of %d", i+1, len(statements))
+					}
+
+					n := strings.Count(stmt, macroParamMarker)
+					stmtParams := params[:n]
+					params = params[n:]
 
-				for rows.Next() {
-					err := writeRowFromDatabase(dbInfo, w, rows, wroteFirstRow)
+					rows, err := conn.QueryxContext(ctx, bindMacroParams(stmt, dbInfo.Type), stmtParams...)
 					if err != nil {
+						// odbc driver returns an error for an empty result
+						// see https://github.com/alexbrainman/odbc/blob/9c9a2e61c5e2c1a257a51ea49169fc9008c51f0e/odbcstmt.go#L134
+						if vendor == string(ODBCDatabase) && err.Error() == "Stmt did not create a result set" {
+							continue
+						}
+
 						return nil, err
 					}
 
-					wroteFirstRow = true
+					err = writeResultSets(dbInfo, w, rows, namespaced, &resultSets)
+					rows.Close()
+					if err != nil {
+						return nil, err
+					}
 				}
 
-				return nil, rows.Err()
-
+				return nil, nil
 			},
 			project.Id,
 			query,
 			panelsToImport,
 			qt,
-			panelResultLoader,
+			importLoader,
 			cache,
+			dbInfo.Type,
+			getBulkLoader(ctx, dbInfo.Type, conn),
 		)
 
 		return err
@@ -606,17 +784,18 @@ func (ec *EvalContext) EvalDatabasePanelWithWriter(
 }
 
 func (ec *EvalContext) EvalDatabasePanel(
+	ctx context.Context,
 	project *ProjectState,
 	pageIndex int,
 	panel *PanelInfo,
-	panelResultLoader func(projectId, panelId string) (chan map[string]any, error),
+	panelResultLoader func(projectId, panelId string) (*RowIterator, error),
 	cache CacheSettings,
 ) error {
-	w, err := ec.GetResultWriter(project.Id, panel.Id)
+	w, err := ec.GetResultWriter(ctx, project.Id, panel.Id)
 	if err != nil {
 		return err
 	}
 	defer w.Close()
 
-	return ec.EvalDatabasePanelWithWriter(project, pageIndex, panel, panelResultLoader, cache, w)
+	return ec.EvalDatabasePanelWithWriter(ctx, project, pageIndex, panel, panelResultLoader, cache, w)
 }
@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,7 +16,7 @@ type airtableResponse struct {
 	} `json:"records"`
 }
 
-func (ec EvalContext) evalAirtable(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, w *ResultWriter) error {
+func (ec EvalContext) evalAirtable(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, w *ResultWriter) error {
 	token, err := ec.decrypt(&dbInfo.ApiKey)
 	if err != nil {
 		return edse(err)
@@ -45,7 +46,7 @@ func (ec EvalContext) evalAirtable(panel *PanelInfo, dbInfo DatabaseConnectorInf
 		if offset != "" {
 			offsetParam = "&offset=" + url.QueryEscape(offset)
 		}
-		rsp, err := makeHTTPRequest(httpRequest{
+		rsp, err := makeHTTPRequest(ctx, httpRequest{
 			url: baseUrl + offsetParam,
 			headers: []HttpConnectorInfoHeader{
 				{
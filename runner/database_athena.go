@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"context"
 	"strconv"
 	"time"
 
@@ -34,7 +35,7 @@ func mapAthenaType(value, t string) any {
 	}
 }
 
-func (ec EvalContext) evalAthena(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, w *ResultWriter) error {
+func (ec EvalContext) evalAthena(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, w *ResultWriter) error {
 	secret, err := ec.decrypt(&dbInfo.Password)
 	if err != nil {
 		return err
@@ -73,7 +74,7 @@ func (ec EvalContext) evalAthena(panel *PanelInfo, dbInfo DatabaseConnectorInfoD
 	r.SetOutputLocation(dbInfo.Address)
 	s.SetResultConfiguration(&r)
 
-	result, err := svc.StartQueryExecution(&s)
+	result, err := svc.StartQueryExecutionWithContext(ctx, &s)
 	if err != nil {
 		return err
 	}
@@ -83,7 +84,7 @@ func (ec EvalContext) evalAthena(panel *PanelInfo, dbInfo DatabaseConnectorInfoD
 
 	var qrop *athena.GetQueryExecutionOutput
 	for {
-		qrop, err = svc.GetQueryExecution(&qri)
+		qrop, err = svc.GetQueryExecutionWithContext(ctx, &qri)
 		if err != nil {
 			return err
 		}
@@ -92,7 +93,18 @@ func (ec EvalContext) evalAthena(panel *PanelInfo, dbInfo DatabaseConnectorInfoD
 		if state != "RUNNING" && state != "QUEUED" {
 			break
 		}
-		time.Sleep(time.Duration(2) * time.Second)
+
+		select {
+		case <-ctx.Done():
+			// Don't leave the query running (and billing) in the background
+			var sqi athena.StopQueryExecutionInput
+			sqi.SetQueryExecutionId(*result.QueryExecutionId)
+			if _, err := svc.StopQueryExecution(&sqi); err != nil {
+				ec.stats.logln(WarnLevel, "Could not stop Athena query: %s", err)
+			}
+			return ctx.Err()
+		case <-time.After(time.Duration(2) * time.Second):
+		}
 	}
 
 	if *qrop.QueryExecution.Status.State != "SUCCEEDED" {
@@ -106,8 +118,8 @@ func (ec EvalContext) evalAthena(panel *PanelInfo, dbInfo DatabaseConnectorInfoD
 	first := true
 	var columns []string
 	var types []string
-	errC := make(chan error)
-	err = svc.GetQueryResultsPages(&ip,
+	errC := make(chan error, 1)
+	err = svc.GetQueryResultsPagesWithContext(ctx, &ip,
 		func(page *athena.GetQueryResultsOutput, lastPage bool) bool {
 			if first {
 				for _, col := range page.ResultSet.ResultSetMetadata.ColumnInfo {
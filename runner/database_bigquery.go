@@ -8,9 +8,7 @@ import (
 	"google.golang.org/api/option"
 )
 
-func (ec EvalContext) evalBigQuery(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, w *ResultWriter) error {
-	ctx := context.Background()
-
+func (ec EvalContext) evalBigQuery(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, w *ResultWriter) error {
 	token, err := ec.decrypt(&dbInfo.ApiKey)
 	if err != nil {
 		return err
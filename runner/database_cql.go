@@ -1,10 +1,12 @@
 package runner
 
 import (
+	"context"
+
 	"github.com/gocql/gocql"
 )
 
-func (ec EvalContext) evalCQL(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
+func (ec EvalContext) evalCQL(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
 	_, host, port, _, err := getHTTPHostPort(dbInfo.Address)
 	if err != nil {
 		return err
@@ -15,7 +17,7 @@ func (ec EvalContext) evalCQL(panel *PanelInfo, dbInfo DatabaseConnectorInfoData
 		return err
 	}
 
-	return ec.withRemoteConnection(server, host, port, func(proxyHost, proxyPort string) error {
+	return ec.withRemoteConnection(ctx, server, host, port, func(proxyHost, proxyPort string) error {
 		cluster := gocql.NewCluster(proxyHost + ":" + proxyPort)
 		cluster.Keyspace = dbInfo.Database
 		cluster.Consistency = gocql.Quorum
@@ -32,7 +34,7 @@ func (ec EvalContext) evalCQL(panel *PanelInfo, dbInfo DatabaseConnectorInfoData
 		}
 		defer sess.Close()
 
-		iter := sess.Query(panel.Content).Iter()
+		iter := sess.Query(bindMacroParams(panel.Content, dbInfo.Type), ec.params...).WithContext(ctx).Iter()
 		for {
 			// TODO: Can we reuse this map?
 			row := map[string]any{}
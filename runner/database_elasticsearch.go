@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"context"
 	"encoding/base64"
 	"net/url"
 )
@@ -20,8 +21,8 @@ type elasticsearchResponse struct {
 	} `json:"error"`
 }
 
-func makeScrollRequest(baseUrl, scrollId string, req httpRequest) (*elasticsearchResponse, error) {
-	rsp, err := makeHTTPRequest(req)
+func makeScrollRequest(ctx context.Context, baseUrl, scrollId string, req httpRequest) (*elasticsearchResponse, error) {
+	rsp, err := makeHTTPRequest(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -42,8 +43,9 @@ func makeScrollRequest(baseUrl, scrollId string, req httpRequest) (*elasticsearc
 			return
 		}
 
-		// Clear the scroll context under any condition
-		_, err = makeHTTPRequest(httpRequest{
+		// Clear the scroll context under any condition, even
+		// if the eval was cancelled.
+		_, err = makeHTTPRequest(context.Background(), httpRequest{
 			allowInsecure: req.allowInsecure,
 			url:           baseUrl + "/_search/scroll",
 			method:        "DELETE",
@@ -73,7 +75,7 @@ func makeScrollRequest(baseUrl, scrollId string, req httpRequest) (*elasticsearc
 	return &r, nil
 }
 
-func (ec EvalContext) evalElasticsearch(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
+func (ec EvalContext) evalElasticsearch(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
 	var customCaCerts []string
 	for _, caCert := range ec.settings.CaCerts {
 		customCaCerts = append(customCaCerts, caCert.File)
@@ -96,7 +98,7 @@ func (ec EvalContext) evalElasticsearch(panel *PanelInfo, dbInfo DatabaseConnect
 		return err
 	}
 
-	return ec.withRemoteConnection(server, host, port, func(proxyHost, proxyPort string) error {
+	return ec.withRemoteConnection(ctx, server, host, port, func(proxyHost, proxyPort string) error {
 		baseUrl := makeHTTPUrl(tls, proxyHost, proxyPort, rest)
 		u := baseUrl + "/" + indexes + "/_search"
 
@@ -124,7 +126,7 @@ func (ec EvalContext) evalElasticsearch(panel *PanelInfo, dbInfo DatabaseConnect
 		u += "&size=10000"
 		// Closes the scroll after 1m of *idling* not 1m of scrolling
 		u += "&scroll=1m"
-		Logln("Making Elasticsearch request: %s. With query: (%s)", u, q)
+		ec.stats.logln(InfoLevel, "Making Elasticsearch request: %s. With query: (%s)", u, q)
 
 		var headers []HttpConnectorInfoHeader
 		if password != "" {
@@ -141,7 +143,8 @@ func (ec EvalContext) evalElasticsearch(panel *PanelInfo, dbInfo DatabaseConnect
 		}
 
 		// Set up the scroll context
-		rsp, err := makeHTTPRequest(httpRequest{
+		ec.stats.setPhase("Scrolling Elasticsearch page 1")
+		rsp, err := makeHTTPRequest(ctx, httpRequest{
 			allowInsecure: panel.Database.Extra["allow_insecure"] == "true",
 			url:           u,
 			method:        "POST",
@@ -169,7 +172,8 @@ func (ec EvalContext) evalElasticsearch(panel *PanelInfo, dbInfo DatabaseConnect
 			}
 		}
 
-		for {
+		for page := 2; ; page++ {
+			ec.stats.setPhase("Scrolling Elasticsearch page %d", page)
 			bodyBytes, err := jsonMarshal(map[string]any{
 				"scroll":    "1m",
 				"scroll_id": scrollId,
@@ -178,8 +182,8 @@ func (ec EvalContext) evalElasticsearch(panel *PanelInfo, dbInfo DatabaseConnect
 				return err
 			}
 
-			Logln("Making new request with scroll id")
-			r, err := makeScrollRequest(baseUrl, scrollId, httpRequest{
+			ec.stats.logln(DebugLevel, "Making new request with scroll id")
+			r, err := makeScrollRequest(ctx, baseUrl, scrollId, httpRequest{
 				allowInsecure: panel.Database.Extra["allow_insecure"] == "true",
 				url:           baseUrl + "/_search/scroll",
 				method:        "POST",
@@ -30,14 +30,14 @@ func writeGoogleSheet(sheet *sheets.ValueRange, w *ResultWriter) error {
 	return nil
 }
 
-func fetchGoogleSheetValueRange(srv *sheets.Service, sheetId string, sInfo *sheets.Sheet) (*sheets.ValueRange, error) {
+func fetchGoogleSheetValueRange(ctx context.Context, srv *sheets.Service, sheetId string, sInfo *sheets.Sheet) (*sheets.ValueRange, error) {
 	rows := sInfo.Properties.GridProperties.RowCount
 	columns := sInfo.Properties.GridProperties.ColumnCount
 	title := sInfo.Properties.Title
 
 	readRange := fmt.Sprintf("%s!A1:%s%d", title, indexToExcelColumn(int(columns)), rows+1)
 
-	rsp, err := srv.Spreadsheets.Values.Get(sheetId, readRange).Do()
+	rsp, err := srv.Spreadsheets.Values.Get(sheetId, readRange).Context(ctx).Do()
 	if err != nil {
 		return nil, makeErrUser(err.Error())
 	}
@@ -45,9 +45,7 @@ func fetchGoogleSheetValueRange(srv *sheets.Service, sheetId string, sInfo *shee
 	return rsp, nil
 }
 
-func (ec EvalContext) evalGoogleSheets(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, w *ResultWriter) error {
-	ctx := context.Background()
-
+func (ec EvalContext) evalGoogleSheets(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, w *ResultWriter) error {
 	token, err := ec.decrypt(&dbInfo.ApiKey)
 	if err != nil {
 		return err
@@ -58,7 +56,7 @@ func (ec EvalContext) evalGoogleSheets(panel *PanelInfo, dbInfo DatabaseConnecto
 		return edsef("Unable to retrieve Sheets client: %v", err)
 	}
 
-	rsp, err := srv.Spreadsheets.Get(panel.Database.Table).Do()
+	rsp, err := srv.Spreadsheets.Get(panel.Database.Table).Context(ctx).Do()
 	if err != nil {
 		return makeErrUser(err.Error())
 	}
@@ -67,7 +65,7 @@ func (ec EvalContext) evalGoogleSheets(panel *PanelInfo, dbInfo DatabaseConnecto
 
 	// Single sheet files get flattened into just an array, not a dict mapping sheet name to sheet contents
 	if len(sheets) == 1 {
-		valueRange, err := fetchGoogleSheetValueRange(srv, panel.Database.Table, sheets[0])
+		valueRange, err := fetchGoogleSheetValueRange(ctx, srv, panel.Database.Table, sheets[0])
 		if err != nil {
 			return err
 		}
@@ -82,7 +80,7 @@ func (ec EvalContext) evalGoogleSheets(panel *PanelInfo, dbInfo DatabaseConnecto
 			return err
 		}
 
-		valueRange, err := fetchGoogleSheetValueRange(srv, panel.Database.Table, sheets[0])
+		valueRange, err := fetchGoogleSheetValueRange(ctx, srv, panel.Database.Table, sheets[0])
 		if err != nil {
 			return err
 		}
@@ -26,7 +26,7 @@ type influxResponse struct {
 
 // InfluxQL is supported in 1 and 2 but requires special setup in 2.
 // See https://docs.influxdata.com/influxdb/v2.1/query-data/influxql/#verify-buckets-have-a-mapping
-func (ec EvalContext) evalInfluxQL(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
+func (ec EvalContext) evalInfluxQL(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
 	tls, host, port, _, err := getHTTPHostPort(dbInfo.Address)
 	if err != nil {
 		return err
@@ -42,7 +42,7 @@ func (ec EvalContext) evalInfluxQL(panel *PanelInfo, dbInfo DatabaseConnectorInf
 		return err
 	}
 
-	return ec.withRemoteConnection(server, host, port, func(proxyHost, proxyPort string) error {
+	return ec.withRemoteConnection(ctx, server, host, port, func(proxyHost, proxyPort string) error {
 		params := url.Values{}
 		params.Add("q", panel.Content)
 		params.Add("db", dbInfo.Database)
@@ -65,7 +65,7 @@ func (ec EvalContext) evalInfluxQL(panel *PanelInfo, dbInfo DatabaseConnectorInf
 		for _, c := range ec.settings.CaCerts {
 			customCaCerts = append(customCaCerts, c.File)
 		}
-		rsp, err := makeHTTPRequest(httpRequest{
+		rsp, err := makeHTTPRequest(ctx, httpRequest{
 			url:           u,
 			method:        "GET",
 			headers:       headers,
@@ -110,7 +110,7 @@ func (ec EvalContext) evalInfluxQL(panel *PanelInfo, dbInfo DatabaseConnectorInf
 }
 
 // Flux language is only supported in influx2.
-func (ec EvalContext) evalFlux(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
+func (ec EvalContext) evalFlux(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
 	tls, host, port, rest, err := getHTTPHostPort(dbInfo.Address)
 	if err != nil {
 		return err
@@ -121,7 +121,7 @@ func (ec EvalContext) evalFlux(panel *PanelInfo, dbInfo DatabaseConnectorInfoDat
 		return err
 	}
 
-	return ec.withRemoteConnection(server, host, port, func(proxyHost, proxyPort string) error {
+	return ec.withRemoteConnection(ctx, server, host, port, func(proxyHost, proxyPort string) error {
 		url := makeHTTPUrl(tls, proxyHost, proxyPort, rest)
 
 		// TODO: support custom certs
@@ -132,7 +132,7 @@ func (ec EvalContext) evalFlux(panel *PanelInfo, dbInfo DatabaseConnectorInfoDat
 
 		queryApi := client.QueryAPI(dbInfo.Database)
 
-		result, err := queryApi.Query(context.Background(), panel.Content)
+		result, err := queryApi.Query(ctx, panel.Content)
 		if err != nil {
 			return err
 		}
@@ -1,12 +1,13 @@
 package runner
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os/exec"
 )
 
-func (ec EvalContext) evalMongo(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
+func (ec EvalContext) evalMongo(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
 	_, conn, err := ec.getConnectionString(dbInfo)
 	if err != nil {
 		return err
@@ -21,7 +22,7 @@ func (ec EvalContext) evalMongo(panel *PanelInfo, dbInfo DatabaseConnectorInfoDa
 	eval := fmt.Sprintf("'EJSON.stringify(%s)'", panel.Content)
 
 	args := []string{conn, "--quiet", "--authenticationDatabase", authDB, "--eval", eval}
-	stdout, err := exec.Command("mongosh", args...).Output()
+	stdout, err := exec.CommandContext(ctx, "mongosh", args...).Output()
 	if err != nil {
 		log.Println(err)
 		if exitErr, ok := err.(*exec.ExitError); ok {
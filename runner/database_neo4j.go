@@ -1,10 +1,12 @@
 package runner
 
 import (
+	"context"
+
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 )
 
-func (ec EvalContext) evalNeo4j(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
+func (ec EvalContext) evalNeo4j(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
 	_, conn, err := ec.getConnectionString(dbInfo)
 	if err != nil {
 		return err
@@ -22,8 +24,11 @@ func (ec EvalContext) evalNeo4j(panel *PanelInfo, dbInfo DatabaseConnectorInfoDa
 	defer driver.Close()
 
 	sess := driver.NewSession(neo4j.SessionConfig{})
+	defer sess.Close()
+	// This version of the driver doesn't take a context
+	defer closeOnDone(ctx, func() { sess.Close() })()
 
-	result, err := sess.Run(panel.Content, nil)
+	result, err := sess.Run(bindMacroParams(panel.Content, dbInfo.Type), neo4jMacroParams(ec.params))
 	if err != nil {
 		return err
 	}
@@ -11,7 +11,7 @@ import (
 	"github.com/prometheus/common/model"
 )
 
-func (ec EvalContext) evalPrometheus(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
+func (ec EvalContext) evalPrometheus(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
 	begin, end, allTime, err := timestampsFromRange(panel.DatabasePanelInfo.Database.Range)
 	if err != nil {
 		return err
@@ -32,7 +32,7 @@ func (ec EvalContext) evalPrometheus(panel *PanelInfo, dbInfo DatabaseConnectorI
 		return err
 	}
 
-	return ec.withRemoteConnection(server, host, port, func(proxyHost, proxyPort string) error {
+	return ec.withRemoteConnection(ctx, server, host, port, func(proxyHost, proxyPort string) error {
 		step := time.Second * time.Duration(math.Floor(panel.DatabasePanelInfo.Database.Step))
 		if step <= 0*time.Second {
 			// Default to 15 minutes
@@ -67,7 +67,7 @@ func (ec EvalContext) evalPrometheus(panel *PanelInfo, dbInfo DatabaseConnectorI
 			r.Start = begin
 			r.End = end
 		}
-		result, _, err := v1api.QueryRange(context.Background(), panel.Content, r)
+		result, _, err := v1api.QueryRange(ctx, panel.Content, r)
 		if err != nil {
 			return err
 		}
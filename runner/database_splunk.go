@@ -1,5 +1,155 @@
 package runner
 
-func evalSplunk(panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
-	panic("Not implemented")
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The export endpoint streams one JSON object per line rather than
+// waiting for the whole search job to finish.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fjobs.2Fexport
+type splunkExportLine struct {
+	Preview  bool           `json:"preview"`
+	Result   map[string]any `json:"result"`
+	Messages []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"messages"`
+}
+
+// Searches must start with a command. Users will usually write
+// just the search terms as they would in the Splunk search bar.
+func makeSplunkSearch(q string) string {
+	q = strings.TrimSpace(q)
+	if strings.HasPrefix(q, "|") || strings.HasPrefix(q, "search ") {
+		return q
+	}
+
+	return "search " + q
+}
+
+func makeSplunkSearchParams(q string, r TimeSeriesRange) (url.Values, error) {
+	params := url.Values{}
+	params.Add("search", makeSplunkSearch(q))
+	params.Add("output_mode", "json")
+
+	if r.Type == "" || r.Type == None {
+		return params, nil
+	}
+
+	begin, end, allTime, err := timestampsFromRange(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !allTime {
+		params.Add("earliest_time", strconv.FormatInt(begin.Unix(), 10))
+		params.Add("latest_time", strconv.FormatInt(end.Unix(), 10))
+	}
+
+	return params, nil
+}
+
+func writeSplunkResults(body io.Reader, w *ResultWriter) error {
+	dec := jsonNewDecoder(body)
+	for {
+		var line splunkExportLine
+		err := dec.Decode(&line)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, m := range line.Messages {
+			if m.Type == "ERROR" || m.Type == "FATAL" {
+				return makeErrUser(m.Text)
+			}
+		}
+
+		// Preview rows are repeated in the final results
+		if line.Preview || line.Result == nil {
+			continue
+		}
+
+		err = w.WriteRow(line.Result)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (ec EvalContext) evalSplunk(ctx context.Context, panel *PanelInfo, dbInfo DatabaseConnectorInfoDatabase, server *ServerInfo, w *ResultWriter) error {
+	var customCaCerts []string
+	for _, caCert := range ec.settings.CaCerts {
+		customCaCerts = append(customCaCerts, caCert.File)
+	}
+
+	tls, host, port, rest, err := getHTTPHostPort(dbInfo.Address)
+	if err != nil {
+		return err
+	}
+
+	password, err := ec.decrypt(&dbInfo.Password)
+	if err != nil {
+		return err
+	}
+
+	token, err := ec.decrypt(&dbInfo.ApiKey)
+	if err != nil {
+		return err
+	}
+
+	params, err := makeSplunkSearchParams(panel.Content, panel.Database.Range)
+	if err != nil {
+		return err
+	}
+
+	headers := []HttpConnectorInfoHeader{{
+		Name:  "content-type",
+		Value: "application/x-www-form-urlencoded",
+	}}
+	if password != "" {
+		basic := base64.StdEncoding.EncodeToString([]byte(dbInfo.Username + ":" + password))
+		headers = append(headers, HttpConnectorInfoHeader{
+			Name:  "Authorization",
+			Value: "Basic " + basic,
+		})
+	} else if token != "" {
+		headers = append(headers, HttpConnectorInfoHeader{
+			Name:  "Authorization",
+			Value: "Bearer " + token,
+		})
+	}
+
+	return ec.withRemoteConnection(ctx, server, host, port, func(proxyHost, proxyPort string) error {
+		u := makeHTTPUrl(tls, proxyHost, proxyPort, strings.TrimSuffix(rest, "/")+"/services/search/jobs/export")
+		ec.stats.logln(InfoLevel, "Making Splunk request: %s. With search: (%s)", u, params.Get("search"))
+
+		rsp, err := makeHTTPRequest(ctx, httpRequest{
+			allowInsecure: panel.Database.Extra["allowInsecure"] == "true",
+			url:           u,
+			method:        "POST",
+			headers:       headers,
+			customCaCerts: customCaCerts,
+			body:          []byte(params.Encode()),
+			sendBody:      true,
+		})
+		if err != nil {
+			return err
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode >= 400 {
+			b, _ := io.ReadAll(rsp.Body)
+			return makeErrUser(string(b))
+		}
+
+		return writeSplunkResults(rsp.Body, w)
+	})
 }
@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_makeSplunkSearch(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{"index=main error", "search index=main error"},
+		{"  search index=main ", "search index=main"},
+		{"| inputlookup hosts.csv", "| inputlookup hosts.csv"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.exp, makeSplunkSearch(test.in))
+	}
+}
+
+func Test_makeSplunkSearchParams(t *testing.T) {
+	begin := time.Unix(1_600_000_000, 0)
+	end := time.Unix(1_600_003_600, 0)
+	allTime := TimeSeriesRelativeTimes("all-time")
+
+	params, err := makeSplunkSearchParams("x", TimeSeriesRange{Type: AbsoluteRange, BeginDate: &begin, EndDate: &end})
+	assert.Nil(t, err)
+	assert.Equal(t, "1600000000", params.Get("earliest_time"))
+	assert.Equal(t, "1600003600", params.Get("latest_time"))
+
+	params, err = makeSplunkSearchParams("x", TimeSeriesRange{Type: RelativeRange, Relative: &allTime})
+	assert.Nil(t, err)
+	assert.False(t, params.Has("earliest_time"))
+	assert.False(t, params.Has("latest_time"))
+}
+
+func Test_evalSplunk(t *testing.T) {
+	var form url.Values
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/services/search/jobs/export", r.URL.Path)
+		auth = r.Header.Get("Authorization")
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		form, err = url.ParseQuery(string(body))
+		assert.Nil(t, err)
+
+		if form.Get("search") == "search bad" {
+			_, _ = w.Write([]byte(`{"messages":[{"type":"ERROR","text":"Unknown search command 'bad'."}]}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"preview":true,"offset":0,"result":{"host":"a","count":"1"}}
+{"preview":false,"offset":0,"result":{"host":"a","count":"2"}}
+{"preview":false,"offset":1,"lastrow":true,"result":{"host":"b","count":"3"}}
+`))
+	}))
+	defer srv.Close()
+
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	connector := ConnectorInfo{
+		Id:   newId(),
+		Type: DatabaseConnector,
+		DatabaseConnectorInfo: &DatabaseConnectorInfo{
+			Database: DatabaseConnectorInfoDatabase{
+				Type:     SplunkDatabase,
+				Address:  srv.URL,
+				Username: "admin",
+				Password: Encrypt{Value: "changeme"},
+			},
+		},
+	}
+	panel := &PanelInfo{
+		Id:      newId(),
+		Type:    DatabasePanel,
+		Content: "index=main | stats count by host",
+		DatabasePanelInfo: &DatabasePanelInfo{
+			Database: DatabasePanelInfoDatabase{ConnectorId: connector.Id},
+		},
+	}
+	project := &ProjectState{
+		Id:         "splunk-test",
+		Connectors: []ConnectorInfo{connector},
+		Pages:      []ProjectPage{{Panels: []PanelInfo{*panel}}},
+	}
+
+	err := ec.EvalDatabasePanel(context.Background(), project, 0, panel, nil, *DefaultCacheSettings)
+	assert.Nil(t, err)
+	assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:changeme")), auth)
+	assert.Equal(t, "search index=main | stats count by host", form.Get("search"))
+	assert.Equal(t, "json", form.Get("output_mode"))
+
+	var m []map[string]any
+	err = readJSONFileInto(ec.GetPanelResultsFile(project.Id, panel.Id), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{
+		{"host": "a", "count": "2"},
+		{"host": "b", "count": "3"},
+	}, m)
+
+	// Token auth
+	project.Connectors[0].Database.Password = Encrypt{}
+	project.Connectors[0].Database.ApiKey = Encrypt{Value: "my-token"}
+	panel.Content = "bad"
+	err = ec.EvalDatabasePanel(context.Background(), project, 0, panel, nil, *DefaultCacheSettings)
+	assert.Equal(t, "Bearer my-token", auth)
+	assert.NotNil(t, err)
+	assert.Equal(t, "UserError", err.(*DSError).Name)
+}
@@ -1,8 +1,13 @@
 package runner
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
 	"testing"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -224,3 +229,170 @@ func Test_getConnectionString(t *testing.T) {
 		assert.Equal(t, test.expExtra, extra)
 	}
 }
+
+func Test_EvalDatabasePanel_multipleStatements(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	connector, err := MakeTmpSQLiteConnector()
+	assert.Nil(t, err)
+	project := &ProjectState{
+		Id:         "multiple-statements-test",
+		Connectors: []ConnectorInfo{*connector},
+		Pages:      []ProjectPage{{}},
+	}
+
+	tests := []struct {
+		sql    string
+		params []any
+		exp    any
+	}{
+		{
+			"SELECT 1 AS a;",
+			nil,
+			[]any{map[string]any{"a": float64(1)}},
+		},
+		{
+			`CREATE TEMP TABLE t (a INTEGER);
+INSERT INTO t VALUES (1), (2);
+SELECT a FROM t ORDER BY a;
+-- No rows is still a result set
+SELECT a FROM t WHERE a > 2;
+SELECT COUNT(*) AS n FROM t;`,
+			nil,
+			map[string]any{
+				"stmt1": []any{map[string]any{"a": float64(1)}, map[string]any{"a": float64(2)}},
+				"stmt2": []any{},
+				"stmt3": []any{map[string]any{"n": float64(2)}},
+			},
+		},
+		{
+			// Each statement gets its own values
+			"SELECT " + macroParamMarker + " AS a; SELECT " + macroParamMarker + " AS b, " + macroParamMarker + " AS c",
+			[]any{"x", "y';", float64(3)},
+			map[string]any{
+				"stmt1": []any{map[string]any{"a": "x"}},
+				"stmt2": []any{map[string]any{"b": "y';", "c": float64(3)}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		panel := &PanelInfo{
+			Type:    DatabasePanel,
+			Id:      newId(),
+			Content: test.sql,
+			DatabasePanelInfo: &DatabasePanelInfo{
+				Database: DatabasePanelInfoDatabase{ConnectorId: connector.Id},
+			},
+		}
+
+		ec.params = test.params
+		err := ec.EvalDatabasePanel(context.Background(), project, 0, panel, nil, *DefaultCacheSettings)
+		assert.Nil(t, err)
+
+		var result any
+		err = readJSONFileInto(ec.GetPanelResultsFile(project.Id, panel.Id), &result)
+		assert.Nil(t, err)
+		assert.Equal(t, test.exp, result)
+	}
+}
+
+// Returns two result sets from any query, like a procedure call.
+type multiResultDriver struct{}
+
+func (multiResultDriver) Open(string) (driver.Conn, error) { return multiResultConn{}, nil }
+
+type multiResultConn struct{}
+
+func (multiResultConn) Prepare(string) (driver.Stmt, error) { return multiResultStmt{}, nil }
+func (multiResultConn) Close() error                        { return nil }
+func (multiResultConn) Begin() (driver.Tx, error)           { return nil, io.EOF }
+
+type multiResultStmt struct{}
+
+func (multiResultStmt) Close() error  { return nil }
+func (multiResultStmt) NumInput() int { return -1 }
+func (multiResultStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, io.EOF
+}
+func (multiResultStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &multiResultRows{sets: [][]string{{"x", "y"}, {"z"}}}, nil
+}
+
+type multiResultRows struct {
+	sets [][]string
+	set  int
+	row  int
+}
+
+func (r *multiResultRows) Columns() []string { return []string{"a"} }
+func (r *multiResultRows) Close() error      { return nil }
+func (r *multiResultRows) Next(dest []driver.Value) error {
+	if r.row >= len(r.sets[r.set]) {
+		return io.EOF
+	}
+
+	dest[0] = r.sets[r.set][r.row]
+	r.row++
+	return nil
+}
+func (r *multiResultRows) HasNextResultSet() bool { return r.set+1 < len(r.sets) }
+func (r *multiResultRows) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+
+	r.set++
+	r.row = 0
+	return nil
+}
+
+func Test_writeResultSets(t *testing.T) {
+	sql.Register("multiresult", multiResultDriver{})
+	db, err := sqlx.Open("multiresult", "")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	dbInfo := DatabaseConnectorInfoDatabase{Type: SQLServerDatabase}
+	tests := []struct {
+		stmt string
+		exp  any
+	}{
+		{
+			"EXEC two_results",
+			map[string]any{
+				"stmt1": []any{map[string]any{"a": "x"}, map[string]any{"a": "y"}},
+				"stmt2": []any{map[string]any{"a": "z"}},
+			},
+		},
+		{
+			// Not known to return more than one
+			"SELECT a FROM t",
+			[]any{map[string]any{"a": "x"}, map[string]any{"a": "y"}},
+		},
+	}
+
+	for _, test := range tests {
+		panelId := newId()
+		w, err := ec.GetResultWriter(context.Background(), "write-result-sets-test", panelId)
+		assert.Nil(t, err)
+
+		rows, err := db.Queryx(test.stmt)
+		assert.Nil(t, err)
+
+		resultSets := 0
+		err = writeResultSets(dbInfo, w, rows, isMultiResultStatement(test.stmt, dbInfo.Type), &resultSets)
+		assert.Nil(t, err)
+		rows.Close()
+		assert.Nil(t, w.Close())
+
+		var result any
+		err = readJSONFileInto(ec.GetPanelResultsFile("write-result-sets-test", panelId), &result)
+		assert.Nil(t, err)
+		assert.Equal(t, test.exp, result, test.stmt)
+	}
+}
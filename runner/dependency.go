@@ -0,0 +1,340 @@
+package runner
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+type panelRef struct {
+	pageIndex  int
+	panelIndex int
+}
+
+type PanelEvalOutput struct {
+	Err    error
+	Stdout string
+	// Only has stdout and logs on failure
+	Result PanelResult
+}
+
+// Returns the ids of all panels this panel reads results from. Names
+// or indexes that don't resolve to a panel are skipped, evaluating
+// the panel will report them.
+func getPanelDependencies(project *ProjectState, pageIndex int, panel PanelInfo) []string {
+	page := project.Pages[pageIndex]
+	idMap := getIdMap(page)
+
+	var deps []string
+	add := func(id string) {
+		if id == "" || id == panel.Id {
+			return
+		}
+
+		for _, dep := range deps {
+			if dep == id {
+				return
+			}
+		}
+
+		deps = append(deps, id)
+	}
+
+	templated := []string{panel.Content}
+	if panel.FilaggPanelInfo != nil {
+		templated = append(templated, panel.Filagg.Filter)
+	}
+	if panel.HttpPanelInfo != nil {
+		templated = append(templated, panel.Http.Http.Url)
+		for _, header := range panel.Http.Http.Headers {
+			templated = append(templated, header.Value)
+		}
+	}
+
+	for _, t := range templated {
+		for _, nameOrIndex := range dmGetPanelReferences(t) {
+			add(idMap[nameOrIndex])
+		}
+	}
+
+	switch panel.Type {
+	case FilaggPanel:
+		if panel.FilaggPanelInfo != nil {
+			source, _, err := getDependentPanel(page, panel.Filagg.GetPanelSource())
+			if err == nil {
+				add(source.Id)
+			}
+		}
+	case TablePanel:
+		if panel.TablePanelInfo != nil {
+			add(panel.Table.PanelSource)
+		}
+	case GraphPanel:
+		if panel.GraphPanelInfo != nil {
+			add(panel.Graph.PanelSource)
+		}
+	}
+
+	return deps
+}
+
+// Groups ids into levels where every panel only depends on panels in
+// earlier levels. Panels within a level are independent of each
+// other. Dependencies on ids not in ids are assumed to already have
+// results. If there is a cycle, the ids making up the cycle are
+// returned instead.
+func sortPanelsByDependency(ids []string, deps map[string][]string) ([][]string, []string) {
+	inGraph := map[string]bool{}
+	for _, id := range ids {
+		inGraph[id] = true
+	}
+
+	remaining := map[string]int{}
+	dependents := map[string][]string{}
+	for _, id := range ids {
+		for _, dep := range deps[id] {
+			if !inGraph[dep] {
+				continue
+			}
+
+			remaining[id]++
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+
+	var levels [][]string
+	var level []string
+	for _, id := range ids {
+		if remaining[id] == 0 {
+			level = append(level, id)
+		}
+	}
+
+	sorted := 0
+	for len(level) > 0 {
+		levels = append(levels, level)
+		sorted += len(level)
+
+		var next []string
+		for _, id := range level {
+			for _, dependent := range dependents[id] {
+				remaining[dependent]--
+				if remaining[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+
+		level = next
+	}
+
+	if sorted == len(ids) {
+		return levels, nil
+	}
+
+	// Every unsorted panel has at least one unsorted dependency so
+	// following unsorted dependencies from any of them must
+	// eventually loop.
+	var start string
+	for _, id := range ids {
+		if remaining[id] > 0 {
+			start = id
+			break
+		}
+	}
+
+	seen := map[string]int{}
+	var path []string
+	current := start
+	for {
+		if i, ok := seen[current]; ok {
+			return nil, path[i:]
+		}
+
+		seen[current] = len(path)
+		path = append(path, current)
+
+		for _, dep := range deps[current] {
+			if inGraph[dep] && remaining[dep] > 0 {
+				current = dep
+				break
+			}
+		}
+	}
+}
+
+// Each concurrently evaluated panel gets its own copy of the project
+// since some evaluators (program SQL panels for example) add
+// connectors to it.
+func copyProjectForEval(project *ProjectState) *ProjectState {
+	cp := *project
+	cp.Connectors = append([]ConnectorInfo(nil), project.Connectors...)
+	return &cp
+}
+
+// Panels evaluated in this run don't have results metadata in the
+// project file yet but downstream panels need their shape.
+func (ec EvalContext) refreshResultShape(project *ProjectState, ref panelRef) {
+	panel := &project.Pages[ref.pageIndex].Panels[ref.panelIndex]
+	if !ec.panelResultsExist(project.Id, panel.Id) {
+		return
+	}
+
+	s, err := ec.shapeFromResults(project.Id, panel.Id)
+	if err != nil {
+		Logln("Could not get shape of panel %s: %s", panel.Name, err)
+		return
+	}
+
+	panel.ResultMeta.Shape = *s
+}
+
+// Results evaluated outside of the desktop app, by evalPage or a
+// long-lived runner for example, don't have their shape stored in
+// the project.
+func (ec EvalContext) fillMissingResultShapes(project *ProjectState, pageIndex int, panel PanelInfo) {
+	for _, id := range getPanelDependencies(project, pageIndex, panel) {
+		for i, page := range project.Pages {
+			for j, dep := range page.Panels {
+				if dep.Id == id && dep.ResultMeta.Shape.Kind == "" {
+					ec.refreshResultShape(project, panelRef{i, j})
+				}
+			}
+		}
+	}
+}
+
+func (ec EvalContext) evalPanelsInDependencyOrder(ctx context.Context, project *ProjectState, refs []panelRef) (map[string]PanelEvalOutput, error) {
+	var ids []string
+	byId := map[string]panelRef{}
+	for _, ref := range refs {
+		id := project.Pages[ref.pageIndex].Panels[ref.panelIndex].Id
+		ids = append(ids, id)
+		byId[id] = ref
+	}
+
+	deps := map[string][]string{}
+	for _, id := range ids {
+		ref := byId[id]
+		deps[id] = getPanelDependencies(project, ref.pageIndex, project.Pages[ref.pageIndex].Panels[ref.panelIndex])
+	}
+
+	// Must happen before evaluating concurrently since it modifies
+	// the project.
+	for _, ref := range refs {
+		ec.fillMissingResultShapes(project, ref.pageIndex, project.Pages[ref.pageIndex].Panels[ref.panelIndex])
+	}
+
+	levels, cycle := sortPanelsByDependency(ids, deps)
+	if cycle != nil {
+		var names []string
+		for _, id := range cycle {
+			ref := byId[id]
+			names = append(names, project.Pages[ref.pageIndex].Panels[ref.panelIndex].Name)
+		}
+
+		return nil, makeErrDependencyCycle(names)
+	}
+
+	outputs := map[string]PanelEvalOutput{}
+	workers := make(chan struct{}, runtime.NumCPU())
+	for _, level := range levels {
+		levelOutputs := make([]PanelEvalOutput, len(level))
+		var wg sync.WaitGroup
+
+		for i, id := range level {
+			failed := ""
+			for _, dep := range deps[id] {
+				if out, ok := outputs[dep]; ok && out.Err != nil {
+					depRef := byId[dep]
+					failed = project.Pages[depRef.pageIndex].Panels[depRef.panelIndex].Name
+					break
+				}
+			}
+
+			if failed != "" {
+				levelOutputs[i] = PanelEvalOutput{Err: makeErrInvalidDependentPanel(failed)}
+				continue
+			}
+
+			if ctx.Err() != nil {
+				levelOutputs[i] = PanelEvalOutput{Err: makeErrCancelled()}
+				continue
+			}
+
+			wg.Add(1)
+			go func(i int, ref panelRef) {
+				defer wg.Done()
+				workers <- struct{}{}
+				defer func() { <-workers }()
+
+				panel := project.Pages[ref.pageIndex].Panels[ref.panelIndex]
+				err, stdout := ec.evalPanel(ctx, copyProjectForEval(project), ref.pageIndex, &panel)
+				levelOutputs[i] = PanelEvalOutput{Err: err, Stdout: stdout, Result: panel.ResultMeta}
+			}(i, byId[id])
+		}
+
+		wg.Wait()
+
+		// Only safe to modify the project once nothing is being evaluated.
+		for i, id := range level {
+			outputs[id] = levelOutputs[i]
+			if levelOutputs[i].Err != nil {
+				continue
+			}
+
+			if levelOutputs[i].Result.Shape.Kind != "" {
+				ref := byId[id]
+				project.Pages[ref.pageIndex].Panels[ref.panelIndex].ResultMeta = levelOutputs[i].Result
+			} else {
+				ec.refreshResultShape(project, byId[id])
+			}
+		}
+	}
+
+	return outputs, nil
+}
+
+func pagePanelRefs(project *ProjectState, pageIndex int) []panelRef {
+	var refs []panelRef
+	for i := range project.Pages[pageIndex].Panels {
+		refs = append(refs, panelRef{pageIndex, i})
+	}
+
+	return refs
+}
+
+func projectPanelRefs(project *ProjectState) []panelRef {
+	var refs []panelRef
+	for i := range project.Pages {
+		refs = append(refs, pagePanelRefs(project, i)...)
+	}
+
+	return refs
+}
+
+// Evaluates every panel on a page after the panels it depends on.
+// Results of panels on other pages are used as-is.
+func (ec EvalContext) EvalPage(ctx context.Context, projectId, pageIdOrName string) (map[string]PanelEvalOutput, error) {
+	project, err := ec.getProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	pageIndex, err := getPageIndex(project, pageIdOrName)
+	if err != nil {
+		return nil, err
+	}
+
+	return ec.evalPanelsInDependencyOrder(ctx, project, pagePanelRefs(project, pageIndex))
+}
+
+// Evaluates every panel in a project after the panels it depends on.
+func (ec EvalContext) EvalProject(ctx context.Context, projectId string) (map[string]PanelEvalOutput, error) {
+	project, err := ec.getProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	return ec.evalPanelsInDependencyOrder(ctx, project, projectPanelRefs(project))
+}
@@ -0,0 +1,216 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_dmGetPanelReferences(t *testing.T) {
+	tests := []struct {
+		content string
+		exp     []string
+	}{
+		{"SELECT 1", nil},
+		{"SELECT * FROM DM_getPanel(0) JOIN DM_getPanel(12)", []string{"0", "12"}},
+		{`SELECT * FROM DM_getPanel('my panel'), DM_getPanel("other")`, []string{"my panel", "other"}},
+		{`SELECT * FROM DM_getPanel('my panel', 'a.b')`, []string{"my panel"}},
+		{`SELECT * FROM DM_getPanel("my panel", "a.b")`, []string{"my panel"}},
+		{`SELECT * FROM DM_getPanel(3, 'a.b')`, []string{"3"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.exp, dmGetPanelReferences(test.content), test.content)
+	}
+}
+
+func Test_sortPanelsByDependency(t *testing.T) {
+	tests := []struct {
+		ids       []string
+		deps      map[string][]string
+		expLevels [][]string
+		expCycle  []string
+	}{
+		{
+			[]string{"a", "b", "c"},
+			map[string][]string{},
+			[][]string{{"a", "b", "c"}},
+			nil,
+		},
+		{
+			[]string{"a", "b", "c", "d"},
+			map[string][]string{
+				"c": {"a", "b"},
+				"d": {"c"},
+			},
+			[][]string{{"a", "b"}, {"c"}, {"d"}},
+			nil,
+		},
+		{
+			// Dependencies outside of the graph are ignored
+			[]string{"c", "d"},
+			map[string][]string{
+				"c": {"a"},
+				"d": {"c", "b"},
+			},
+			[][]string{{"c"}, {"d"}},
+			nil,
+		},
+		{
+			[]string{"a", "b", "c", "d"},
+			map[string][]string{
+				"a": {"d"},
+				"b": {"c"},
+				"c": {"b"},
+			},
+			nil,
+			[]string{"b", "c"},
+		},
+		{
+			[]string{"a"},
+			map[string][]string{
+				"a": {"a"},
+			},
+			nil,
+			[]string{"a"},
+		},
+	}
+
+	for _, test := range tests {
+		levels, cycle := sortPanelsByDependency(test.ids, test.deps)
+		assert.Equal(t, test.expLevels, levels)
+		assert.Equal(t, test.expCycle, cycle)
+	}
+}
+
+func Test_getPanelDependencies(t *testing.T) {
+	project := &ProjectState{
+		Pages: []ProjectPage{
+			{
+				Panels: []PanelInfo{
+					{Id: "lit", Name: "Literal", Type: LiteralPanel},
+					{Id: "sql", Name: "SQL", Type: ProgramPanel, Content: "SELECT * FROM DM_getPanel(0) JOIN DM_getPanel('Literal')"},
+					{
+						Id:   "filagg",
+						Type: FilaggPanel,
+						FilaggPanelInfo: &FilaggPanelInfo{
+							Filagg: FilaggPanelInfoFilagg{PanelSource: float64(1)},
+						},
+					},
+					{
+						Id:   "table",
+						Type: TablePanel,
+						TablePanelInfo: &TablePanelInfo{
+							Table: TablePanelInfoTable{PanelSource: "filagg"},
+						},
+					},
+					{
+						Id:   "http",
+						Type: HttpPanel,
+						HttpPanelInfo: &HttpPanelInfo{
+							Http: HttpConnectorInfo{
+								Http: HttpConnectorInfoHttp{
+									Url: "http://localhost/{{ DM_getPanel(\"SQL\")[0].id }}",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string(nil), getPanelDependencies(project, 0, project.Pages[0].Panels[0]))
+	assert.Equal(t, []string{"lit"}, getPanelDependencies(project, 0, project.Pages[0].Panels[1]))
+	assert.Equal(t, []string{"sql"}, getPanelDependencies(project, 0, project.Pages[0].Panels[2]))
+	assert.Equal(t, []string{"filagg"}, getPanelDependencies(project, 0, project.Pages[0].Panels[3]))
+	assert.Equal(t, []string{"sql"}, getPanelDependencies(project, 0, project.Pages[0].Panels[4]))
+}
+
+func Test_evalPanelsInDependencyOrder(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	projectTmp, err := os.CreateTemp("", "dsq-project")
+	assert.Nil(t, err)
+	defer os.Remove(projectTmp.Name())
+
+	literal := func(id, name, content string) PanelInfo {
+		return PanelInfo{
+			Id:      id,
+			Name:    name,
+			Type:    LiteralPanel,
+			Content: content,
+			LiteralPanelInfo: &LiteralPanelInfo{
+				Literal: LiteralPanelInfoLiteral{
+					ContentTypeInfo: ContentTypeInfo{Type: "application/json"},
+				},
+			},
+		}
+	}
+	sql := func(id, name, content string) PanelInfo {
+		p := PanelInfo{
+			Id:               id,
+			Name:             name,
+			Type:             ProgramPanel,
+			Content:          content,
+			ProgramPanelInfo: &ProgramPanelInfo{},
+		}
+		p.Program.Type = SQL
+		return p
+	}
+
+	// Deliberately out of dependency order
+	project := &ProjectState{
+		Id: projectTmp.Name(),
+		Pages: []ProjectPage{
+			{
+				Panels: []PanelInfo{
+					sql(newId(), "total", "SELECT SUM(n) AS total FROM DM_getPanel('joined')"),
+					sql(newId(), "joined", "SELECT a.n + b.n AS n FROM DM_getPanel('a') a JOIN DM_getPanel('b') b ON a.id = b.id"),
+					literal(newId(), "a", `[{"id": 1, "n": 1}, {"id": 2, "n": 2}]`),
+					literal(newId(), "b", `[{"id": 1, "n": 10}, {"id": 2, "n": 20}]`),
+					{
+						Id:   newId(),
+						Name: "table",
+						Type: TablePanel,
+						TablePanelInfo: &TablePanelInfo{
+							Table: TablePanelInfoTable{
+								Columns: []TableColumn{{Field: "total"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	panels := project.Pages[0].Panels
+	panels[4].Table.PanelSource = panels[0].Id
+
+	var refs []panelRef
+	for i := range panels {
+		refs = append(refs, panelRef{0, i})
+	}
+
+	outputs, err := ec.evalPanelsInDependencyOrder(context.Background(), project, refs)
+	assert.Nil(t, err)
+	assert.Equal(t, len(panels), len(outputs))
+	for _, output := range outputs {
+		assert.Nil(t, output.Err)
+	}
+
+	var m []map[string]any
+	err = readJSONFileInto(ec.GetPanelResultsFile(project.Id, panels[4].Id), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"total": float64(33)}}, m)
+
+	// A cycle fails before anything is evaluated
+	panels[2].Content = "SELECT * FROM DM_getPanel('total')"
+	panels[2].Type = ProgramPanel
+	panels[2].ProgramPanelInfo = panels[0].ProgramPanelInfo
+	_, err = ec.evalPanelsInDependencyOrder(context.Background(), project, refs)
+	assert.NotNil(t, err)
+	assert.Equal(t, "DependencyCycleError", err.(*DSError).Name)
+}
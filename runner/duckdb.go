@@ -0,0 +1,233 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// DuckDB is embedded when the runner is built with the duckdb tag,
+// otherwise queries are run by the duckdb CLI (settings.duckdbPath or
+// duckdb on PATH).
+type SQLEngine string
+
+const (
+	SQLiteEngine SQLEngine = "sqlite"
+	DuckDBEngine SQLEngine = "duckdb"
+)
+
+// Program panels can pick their own engine, everything else uses
+// the settings default.
+func (ec EvalContext) sqlEngine(panel *PanelInfo) SQLEngine {
+	if panel.ProgramPanelInfo != nil && panel.Program.Engine != "" {
+		return panel.Program.Engine
+	}
+
+	if ec.settings.SQLEngine != "" {
+		return ec.settings.SQLEngine
+	}
+
+	return SQLiteEngine
+}
+
+func duckdbReadParquet(f string) string {
+	return "read_parquet(" + quote(f, ansiSQLQuote.string) + ")"
+}
+
+// Parquet columns are stored as c0, c1, ... with their names in the
+// footer metadata so they're renamed back here.
+func duckdbReadColumnar(f string, meta columnarMeta) string {
+	if len(meta.Columns) == 0 {
+		return duckdbReadParquet(f)
+	}
+
+	columns := ""
+	for i, c := range meta.Columns {
+		if i > 0 {
+			columns += ", "
+		}
+		columns += quote("c"+strconv.Itoa(i), ansiSQLQuote.identifier) + " AS " + quote(c.Name, ansiSQLQuote.identifier)
+	}
+
+	return "(SELECT " + columns + " FROM " + duckdbReadParquet(f) + ")"
+}
+
+func duckdbReadJSON(f string, format string) string {
+	return "read_json_auto(" + quote(f, ansiSQLQuote.string) + ", format=" + quote(format, ansiSQLQuote.string) + ")"
+}
+
+// DuckDB reads upstream results in place so DM_getPanel tables are
+// views over the results files rather than imported copies.
+func duckdbViewStatement(tableName, source string) string {
+	return "CREATE TEMP VIEW " + quote(tableName, ansiSQLQuote.identifier) + " AS SELECT * FROM " + source + ";"
+}
+
+// Arrays nested within results can't be read by DuckDB directly so
+// they're copied out as newline-delimited JSON first.
+func writeNDJSONFile(rows *RowIterator) (string, error) {
+	defer rows.Close()
+
+	tmp, err := os.CreateTemp("", "duckdb-panel-*.ndjson")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	w := bufio.NewWriter(tmp)
+	for rows.Next() {
+		bs, err := jsonMarshal(rows.Row())
+		if err != nil {
+			os.Remove(tmp.Name())
+			return "", err
+		}
+
+		w.Write(bs)
+		w.WriteByte('\n')
+	}
+
+	if err := rows.Err(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), w.Flush()
+}
+
+// Copies rows into a Parquet file DuckDB can read without its json
+// extension. Returns false when the rows can't be stored as columns
+// and no file when there are no rows.
+func writeColumnarFile(rows *RowIterator) (string, columnarMeta, bool, error) {
+	defer rows.Close()
+
+	tmp, err := os.CreateTemp("", "duckdb-panel-*.parquet")
+	if err != nil {
+		return "", columnarMeta{}, false, err
+	}
+	tmp.Close()
+
+	cw, err := openColumnarResultItemWriter(tmp.Name())
+	if err != nil {
+		return "", columnarMeta{}, false, err
+	}
+
+	written := 0
+	for rows.Next() && !cw.Failed() {
+		if err := cw.WriteRow(rows.Row(), written); err != nil {
+			cw.fail()
+			return "", columnarMeta{}, false, err
+		}
+		written++
+	}
+
+	if err := rows.Err(); err != nil {
+		cw.fail()
+		return "", columnarMeta{}, false, err
+	}
+
+	// Nothing to infer columns from
+	if written == 0 {
+		cw.fail()
+		return "", columnarMeta{}, true, nil
+	}
+
+	if err := cw.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", columnarMeta{}, false, err
+	}
+
+	if cw.Failed() {
+		return "", columnarMeta{}, false, nil
+	}
+
+	return tmp.Name(), cw.meta, true, nil
+}
+
+// Returns the source DuckDB should read a panel's results from,
+// preferring the columnar copy when there is an up-to-date one.
+// Without the json extension results are copied to Parquet instead.
+func (ec EvalContext) duckdbSource(projectId, panelId, path string, readJSON bool) (string, func(), error) {
+	if path == "" {
+		if cr := ec.openColumnarResults(projectId, panelId); cr != nil {
+			cr.Close()
+			return duckdbReadColumnar(ec.getColumnarResultsFile(projectId, panelId), cr.meta), func() {}, nil
+		}
+
+		if readJSON {
+			return duckdbReadJSON(ec.GetPanelResultsFile(projectId, panelId), "array"), func() {}, nil
+		}
+	}
+
+	rows, err := loadJSONArrayFileWithPath(ec.GetPanelResultsFile(projectId, panelId), path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !readJSON {
+		f, meta, ok, err := writeColumnarFile(rows)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if ok && f == "" {
+			return "(SELECT NULL AS " + quote("value", ansiSQLQuote.identifier) + " WHERE false)", func() {}, nil
+		}
+
+		if !ok {
+			return "", nil, edsef("DuckDB's json extension is not installed and the results of panel %s have values that can't be stored as columns. Run INSTALL json; in DuckDB while online to install it.", panelId)
+		}
+
+		return duckdbReadColumnar(f, meta), func() {
+			os.Remove(f)
+		}, nil
+	}
+
+	f, err := writeNDJSONFile(rows)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return duckdbReadJSON(f, "newline_delimited"), func() {
+		os.Remove(f)
+	}, nil
+}
+
+// Only the last statement's results are kept, like go-duckdb does.
+// Earlier statements are run for their effects.
+func duckdbStatements(query string) ([]string, string) {
+	statements := splitSQLStatements(query, SQLiteDatabase)
+	if len(statements) == 0 {
+		return nil, query
+	}
+
+	return statements[:len(statements)-1], statements[len(statements)-1]
+}
+
+// Other statements still return a Count row through go-duckdb
+var duckdbQueryRe = regexp.MustCompile(`(?i)^(SELECT|WITH|VALUES|FROM|TABLE|SHOW|DESCRIBE|SUMMARIZE|PRAGMA|EXPLAIN)\b`)
+
+func duckdbReturnsRows(stmt string) bool {
+	return duckdbQueryRe.MatchString(skipSQLComments(stmt))
+}
+
+func (ec EvalContext) evalDuckDBPanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) error {
+	panelsToImport, query, path, err := transformDM_getPanelCalls(
+		panel.Content,
+		getIdShapeMap(project.Pages[pageIndex]),
+		getIdMap(project.Pages[pageIndex]),
+		true,
+		ansiSQLQuote,
+		SQLiteDatabase,
+		false,
+		// DuckDB keeps nested arrays as lists
+		false,
+	)
+	if err != nil {
+		return err
+	}
+
+	ec.stats.setPhase("Running query")
+	ec.stats.logln(InfoLevel, "Running DuckDB query: %s", query)
+	return ec.runDuckDB(ctx, project.Id, panel.Id, panelsToImport, path, query)
+}
@@ -0,0 +1,120 @@
+//go:build !duckdb
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const duckdbEmbedded = false
+
+// Counts the result sets the CLI prints in JSON mode, each starts
+// a line with [.
+type duckdbResultSetCounter struct {
+	count   int
+	midLine bool
+}
+
+func (c *duckdbResultSetCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if !c.midLine && b == '[' {
+			c.count++
+		}
+		c.midLine = b != '\n'
+	}
+
+	return len(p), nil
+}
+
+func (ec EvalContext) runDuckDB(ctx context.Context, projectId, panelId string, panelsToImport []panelToImport, path, query string) error {
+	exe := "duckdb"
+	if ec.settings.DuckDBPath != "" {
+		exe = strings.TrimSpace(ec.settings.DuckDBPath)
+	}
+
+	exe, err := exec.LookPath(exe)
+	if err != nil {
+		return makeErrUser("DuckDB could not be found. Install the duckdb CLI and add it to your PATH or set the DuckDB path in settings: " + err.Error())
+	}
+
+	var script []string
+	for _, p := range panelsToImport {
+		source, cleanup, err := ec.duckdbSource(projectId, p.id, path, true)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		script = append(script, duckdbViewStatement(p.tableName, source))
+	}
+
+	out, err := os.CreateTemp("", "duckdb-results-")
+	if err != nil {
+		return edse(err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	// Results of earlier statements go to stdout where they're only
+	// counted.
+	earlier, last := duckdbStatements(query)
+	for _, stmt := range earlier {
+		script = append(script, stmt+";")
+	}
+	script = append(script, ".output "+quote(out.Name(), "'"), last+";")
+
+	var stderr bytes.Buffer
+	var dropped duckdbResultSetCounter
+	cmd := exec.CommandContext(ctx, exe, "-json", "-bail", ":memory:")
+	cmd.Stdin = strings.NewReader(strings.Join(script, "\n"))
+	cmd.Stdout = &dropped
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return edsef("DuckDB query failed: %s", msg)
+	}
+
+	if dropped.count > 0 {
+		ec.stats.logln(WarnLevel, "Only the last result set was kept, %d more were returned", dropped.count)
+	}
+
+	w, err := ec.GetResultWriter(ctx, projectId, panelId)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	// Nothing is printed for statements without results
+	fi, err := out.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() == 0 {
+		return nil
+	}
+
+	rows, err := loadJSONArrayFile(out.Name())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := w.WriteRow(rows.Row()); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,28 @@
+//go:build !duckdb
+
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_duckdbResultSetCounter(t *testing.T) {
+	var c duckdbResultSetCounter
+	c.Write([]byte("[{\"a\":1},\n{\"a\":\"[\"}]\n[{"))
+	c.Write([]byte("\"b\":2}]\n"))
+	assert.Equal(t, 2, c.count)
+}
+
+func Test_runDuckDB_missingCLI(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+	ec.settings.DuckDBPath = "/does/not/exist/duckdb"
+
+	err := ec.runDuckDB(context.Background(), newId(), newId(), nil, "", "SELECT 1")
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.(*DSError).Message, "DuckDB could not be found."))
+}
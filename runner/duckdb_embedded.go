@@ -0,0 +1,113 @@
+//go:build duckdb
+
+package runner
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+const duckdbEmbedded = true
+
+// The json extension isn't bundled with go-duckdb. DuckDB installs
+// it into ~/.duckdb the first time, which needs network access.
+func loadDuckDBJSON(ctx context.Context, conn *sqlx.Conn) error {
+	if _, err := conn.ExecContext(ctx, "LOAD json"); err == nil {
+		return nil
+	}
+
+	_, err := conn.ExecContext(ctx, "INSTALL json; LOAD json")
+	return err
+}
+
+func (ec EvalContext) runDuckDB(ctx context.Context, projectId, panelId string, panelsToImport []panelToImport, path, query string) error {
+	db, err := sqlx.Open("duckdb", "")
+	if err != nil {
+		return edse(err)
+	}
+	defer db.Close()
+
+	// Temporary views only exist on the connection that made them
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return edse(err)
+	}
+	defer conn.Close()
+
+	readJSON := true
+	if len(panelsToImport) > 0 {
+		if err := loadDuckDBJSON(ctx, conn); err != nil {
+			ec.stats.logln(InfoLevel, "Could not load DuckDB's json extension, copying results to Parquet instead: %s", err)
+			readJSON = false
+		}
+	}
+
+	for _, p := range panelsToImport {
+		source, cleanup, err := ec.duckdbSource(projectId, p.id, path, readJSON)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		_, err = conn.ExecContext(ctx, duckdbViewStatement(p.tableName, source))
+		if err != nil {
+			return edsef("Could not load panel into DuckDB: %s", err)
+		}
+	}
+
+	earlier, last := duckdbStatements(query)
+	dropped := 0
+	for _, stmt := range earlier {
+		if !duckdbReturnsRows(stmt) {
+			_, err := conn.ExecContext(ctx, stmt)
+			if err != nil {
+				return edsef("DuckDB query failed: %s", err)
+			}
+			continue
+		}
+
+		rows, err := conn.QueryxContext(ctx, stmt)
+		if err != nil {
+			return edsef("DuckDB query failed: %s", err)
+		}
+
+		if rows.Next() {
+			dropped++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return edsef("DuckDB query failed: %s", err)
+		}
+	}
+
+	if dropped > 0 {
+		ec.stats.logln(WarnLevel, "Only the last result set was kept, %d more were returned", dropped)
+	}
+
+	rows, err := conn.QueryxContext(ctx, last)
+	if err != nil {
+		return edsef("DuckDB query failed: %s", err)
+	}
+	defer rows.Close()
+
+	w, err := ec.GetResultWriter(ctx, projectId, panelId)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	wroteFirstRow := false
+	for rows.Next() {
+		err := writeRowFromDatabase(DatabaseConnectorInfoDatabase{}, w, rows, wroteFirstRow)
+		if err != nil {
+			return err
+		}
+
+		wroteFirstRow = true
+	}
+
+	return rows.Err()
+}
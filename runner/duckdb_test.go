@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sqlEngine(t *testing.T) {
+	ec := EvalContext{}
+	panel := &PanelInfo{ProgramPanelInfo: &ProgramPanelInfo{}}
+	assert.Equal(t, SQLiteEngine, ec.sqlEngine(panel))
+
+	ec.settings.SQLEngine = DuckDBEngine
+	assert.Equal(t, DuckDBEngine, ec.sqlEngine(panel))
+	assert.Equal(t, DuckDBEngine, ec.sqlEngine(&PanelInfo{}))
+
+	panel.Program.Engine = SQLiteEngine
+	assert.Equal(t, SQLiteEngine, ec.sqlEngine(panel))
+}
+
+func Test_duckdbViewStatement(t *testing.T) {
+	assert.Equal(t,
+		`CREATE TEMP VIEW "t_0" AS SELECT * FROM read_parquet('/tmp/a''s.parquet');`,
+		duckdbViewStatement("t_0", duckdbReadParquet("/tmp/a's.parquet")))
+	assert.Equal(t,
+		`CREATE TEMP VIEW "t_my panel" AS SELECT * FROM read_json_auto('/tmp/x', format='array');`,
+		duckdbViewStatement("t_my panel", duckdbReadJSON("/tmp/x", "array")))
+}
+
+func Test_duckdbReadColumnar(t *testing.T) {
+	assert.Equal(t,
+		`(SELECT "c0" AS "a", "c1" AS "my ""b""" FROM read_parquet('/tmp/x.parquet'))`,
+		duckdbReadColumnar("/tmp/x.parquet", columnarMeta{Columns: []columnarColumn{{Name: "a"}, {Name: `my "b"`}}}))
+}
+
+func Test_duckdbStatements(t *testing.T) {
+	earlier, last := duckdbStatements("CREATE TABLE x (a INT); -- ok\nSELECT * FROM x;")
+	assert.Equal(t, []string{"CREATE TABLE x (a INT)"}, earlier)
+	assert.Equal(t, "-- ok\nSELECT * FROM x", last)
+	assert.True(t, duckdbReturnsRows(last))
+	assert.False(t, duckdbReturnsRows(earlier[0]))
+
+	earlier, last = duckdbStatements("SELECT 1")
+	assert.Empty(t, earlier)
+	assert.Equal(t, "SELECT 1", last)
+}
+
+func Test_windowExpression(t *testing.T) {
+	assert.Equal(t,
+		`DATETIME(STRFTIME("%s", time) - STRFTIME("%s", time) % 300, "unixepoch")`,
+		windowExpression("time", 300, SQLiteEngine))
+	assert.Equal(t,
+		`TIME_BUCKET(INTERVAL '300 seconds', CAST("time" AS TIMESTAMP))`,
+		windowExpression(`"time"`, 300, DuckDBEngine))
+}
+
+func Test_evalDuckDBPanel(t *testing.T) {
+	if _, err := exec.LookPath("duckdb"); err != nil && !duckdbEmbedded {
+		t.Skip("duckdb is not installed and the runner wasn't built with -tags duckdb")
+	}
+
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	tests := []struct {
+		json      string
+		sql       string
+		expResult []any
+	}{
+		{
+			`[{"a": 1, "b": "x"}, {"a": 2, "b": "y"}, {"a": 3, "b": "x"}]`,
+			"SELECT b, SUM(a) AS total FROM DM_getPanel(0) GROUP BY b ORDER BY b",
+			[]any{
+				map[string]any{"b": "x", "total": float64(4)},
+				map[string]any{"b": "y", "total": float64(2)},
+			},
+		},
+		{
+			`{"data": [{"id": 1}, {"id": 2}]}`,
+			"SELECT id FROM DM_getPanel(0, 'data') WHERE id > 1",
+			[]any{
+				map[string]any{"id": float64(2)},
+			},
+		},
+		{
+			`[{"a": 1}]`,
+			"SELECT a FROM DM_getPanel(0) WHERE a > 1",
+			nil,
+		},
+		{
+			`[{"a": 1}, {"a": 3}]`,
+			"CREATE TEMP TABLE x AS SELECT a FROM DM_getPanel(0); SELECT SUM(a) AS s FROM x",
+			[]any{
+				map[string]any{"s": float64(4)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		project := &ProjectState{Id: newId(), Pages: []ProjectPage{{}}}
+		sourceId := newId()
+		err := os.WriteFile(ec.GetPanelResultsFile(project.Id, sourceId), []byte(test.json), os.ModePerm)
+		assert.Nil(t, err)
+
+		s, err := ShapeFromFile(ec.GetPanelResultsFile(project.Id, sourceId), sourceId, 100)
+		assert.Nil(t, err)
+		project.Pages[0].Panels = []PanelInfo{{
+			Id:         sourceId,
+			Name:       newId(),
+			ResultMeta: PanelResult{Shape: *s},
+		}}
+
+		panel := &PanelInfo{
+			Type:             ProgramPanel,
+			Id:               newId(),
+			Content:          test.sql,
+			ProgramPanelInfo: &ProgramPanelInfo{},
+		}
+		panel.Program.Type = SQL
+		panel.Program.Engine = DuckDBEngine
+
+		err = ec.evalProgramSQLPanel(context.Background(), project, 0, panel)
+		assert.Nil(t, err)
+
+		rows, err := loadJSONArrayFile(ec.GetPanelResultsFile(project.Id, panel.Id))
+		assert.Nil(t, err)
+		var result []any
+		for rows.Next() {
+			result = append(result, rows.Row())
+		}
+		assert.Nil(t, rows.Err())
+		assert.Equal(t, test.expResult, result)
+	}
+}
+
+func Test_evalDuckDBPanel_columnar(t *testing.T) {
+	if _, err := exec.LookPath("duckdb"); err != nil && !duckdbEmbedded {
+		t.Skip("duckdb is not installed and the runner wasn't built with -tags duckdb")
+	}
+
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+	ec.settings.ColumnarResults = true
+
+	project := &ProjectState{Id: newId(), Pages: []ProjectPage{{}}}
+	sourceId := newId()
+	writeColumnarTestResults(t, ec, project.Id, sourceId, []map[string]any{
+		{"name": "x", "total": 1},
+		{"name": "y", "total": 2},
+	})
+	cr := ec.openColumnarResults(project.Id, sourceId)
+	assert.NotNil(t, cr)
+	cr.Close()
+
+	project.Pages[0].Panels = []PanelInfo{{
+		Id:         sourceId,
+		Name:       newId(),
+		ResultMeta: PanelResult{Shape: cr.meta.Shape()},
+	}}
+
+	panel := &PanelInfo{
+		Type:             ProgramPanel,
+		Id:               newId(),
+		Content:          "SELECT name FROM DM_getPanel(0) WHERE total > 1",
+		ProgramPanelInfo: &ProgramPanelInfo{},
+	}
+	panel.Program.Type = SQL
+	panel.Program.Engine = DuckDBEngine
+
+	err := ec.evalProgramSQLPanel(context.Background(), project, 0, panel)
+	assert.Nil(t, err)
+
+	rows, err := loadJSONArrayFile(ec.GetPanelResultsFile(project.Id, panel.Id))
+	assert.Nil(t, err)
+	var result []any
+	for rows.Next() {
+		result = append(result, rows.Row())
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []any{map[string]any{"name": "y"}}, result)
+}
@@ -50,6 +50,44 @@ func makeErrInvalidDependentPanel(id string) *DSError {
 	}
 }
 
+func makeErrDependencyCycle(names []string) *DSError {
+	var escaped []string
+	for _, name := range names {
+		escaped = append(escaped, escapedPanelIdentifier(name))
+	}
+
+	return &DSError{
+		Name:    "DependencyCycleError",
+		Message: fmt.Sprintf("Panels depend on each other in a cycle: %s.", strings.Join(escaped, ", ")),
+		Stack:   string(debug.Stack()),
+	}
+}
+
+func makeErrCancelled() *DSError {
+	return &DSError{
+		Name:    "Cancelled",
+		Message: "Cancelled panel evaluation.",
+		Stack:   string(debug.Stack()),
+	}
+}
+
+func makeErrTimeout(seconds int) *DSError {
+	return &DSError{
+		Name:    "TimeoutError",
+		Message: fmt.Sprintf("Panel evaluation did not finish within %d seconds.", seconds),
+		Stack:   string(debug.Stack()),
+	}
+}
+
+func makeErrResultLimit(t ResultTruncation) *DSError {
+	return &DSError{
+		Name:    "ResultLimitError",
+		Message: fmt.Sprintf("Panel results exceeded the limit of %d %s.", t.Max, t.Limit),
+		Stack:   string(debug.Stack()),
+		Extra:   map[string]any{"truncation": t},
+	}
+}
+
 func makeErrBadTemplate(msg string) *DSError {
 	return &DSError{
 		Name:    "BadTemplateError",
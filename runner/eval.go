@@ -1,10 +1,10 @@
 package runner
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path"
 	"time"
@@ -14,131 +14,207 @@ import (
 	"github.com/flosch/pongo2"
 )
 
-var logPrefixSet = false
-var Verbose = true
-
-func _logln(level, msg string, args ...any) {
-	if !Verbose {
-		return
-	}
-
-	if !logPrefixSet {
-		log.SetFlags(0)
-		logPrefixSet = true
-	}
-	baseMsg := "[" + level + "] " + time.Now().Format(iso8601Format) + " " + msg
-	log.Printf(baseMsg, args...)
-}
-
-func Logln(msg string, args ...any) {
-	_logln("INFO", msg, args...)
-}
-
-func Fatalln(msg string, args ...any) {
-	_logln("FATAL", msg, args...)
-	os.Exit(2)
-}
-
 func (ec EvalContext) panelResultsExist(projectId, panelId string) bool {
 	resultsFile := ec.GetPanelResultsFile(projectId, panelId)
 	_, err := os.Stat(resultsFile)
 	return err == nil
 }
 
-func (ec EvalContext) allImportedPanelResultsExist(project ProjectState, page ProjectPage, panel PanelInfo) (string, bool) {
-	idMap := getIdMap(page)
-	matchesForSubexps := dmGetPanelRe.FindAllStringSubmatch(panel.Content, -1)
+// Returns the name or index of every panel referenced by a
+// DM_getPanel call in content, in order of appearance.
+func dmGetPanelReferences(content string) []string {
+	var refs []string
+	matchesForSubexps := dmGetPanelRe.FindAllStringSubmatch(content, -1)
 	for _, match := range matchesForSubexps {
-		nameOrIndex := ""
 		for i, name := range dmGetPanelRe.SubexpNames() {
+			if match[i] == "" {
+				continue
+			}
+
 			switch name {
 			case "number":
-				nameOrIndex = match[i]
+				refs = append(refs, match[i])
 			case "singlequote", "doublequote":
 				// Remove quotes
-				nameOrIndex = match[i]
-				if nameOrIndex != "" {
-					nameOrIndex = nameOrIndex[1 : len(nameOrIndex)-1]
-				}
-			}
-
-			if nameOrIndex != "" {
-				if !ec.panelResultsExist(project.Id, idMap[nameOrIndex]) {
-					return nameOrIndex, false
-				}
+				refs = append(refs, match[i][1:len(match[i])-1])
 			}
 		}
 	}
 
-	return "", true
+	return refs
 }
 
-func (ec EvalContext) evalMacros(content string, project *ProjectState, pageIndex int) (string, error) {
-	pongoJsonify := func(in *pongo2.Value, _ *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
-		bs, err := json.Marshal(in.Interface())
-		if err != nil {
-			return nil, &pongo2.Error{OrigError: err}
+func (ec EvalContext) allImportedPanelResultsExist(project ProjectState, page ProjectPage, panel PanelInfo) (string, bool) {
+	idMap := getIdMap(page)
+	for _, nameOrIndex := range dmGetPanelReferences(panel.Content) {
+		if !ec.panelResultsExist(project.Id, idMap[nameOrIndex]) {
+			return nameOrIndex, false
 		}
+	}
+
+	return "", true
+}
 
-		return pongo2.AsSafeValue(string(bs)), nil
+func pongoJsonify(in *pongo2.Value, _ *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+	bs, err := json.Marshal(in.Interface())
+	if err != nil {
+		return nil, &pongo2.Error{OrigError: err}
 	}
 
+	return pongo2.AsSafeValue(string(bs)), nil
+}
+
+// Filters are registered globally in pongo2 so do it once up front
+// rather than on every (possibly concurrent) evalMacros call.
+func init() {
+	var err error
 	if pongo2.FilterExists("json") {
-		err := pongo2.ReplaceFilter("json", pongoJsonify)
-		if err != nil {
-			return "", err
-		}
+		err = pongo2.ReplaceFilter("json", pongoJsonify)
 	} else {
-		err := pongo2.RegisterFilter("json", pongoJsonify)
-		if err != nil {
-			return "", err
-		}
+		err = pongo2.RegisterFilter("json", pongoJsonify)
+	}
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (ec EvalContext) evalMacros(content string, project *ProjectState, pageIndex int) (string, error) {
+	out, params, err := ec.evalMacrosWithParams(content, project, pageIndex)
+	if err != nil {
+		return "", err
+	}
+
+	if len(params) > 0 {
+		return "", makeErrUnsupported("DM_param() is only supported in database panels.")
 	}
 
+	return out, nil
+}
+
+// Values passed to DM_param are returned rather than spliced into
+// the output, which gets a marker in their place. See
+// bindMacroParams.
+func (ec EvalContext) evalMacrosWithParams(content string, project *ProjectState, pageIndex int) (string, []any, error) {
 	tpl, err := pongo2.FromString(content)
 	if err != nil {
-		return "", makeErrBadTemplate(err.Error())
+		return "", nil, makeErrBadTemplate(err.Error())
 	}
 
-	errC := make(chan error)
+	vars, err := resolveVariables(project, pageIndex, ec.vars)
+	if err != nil {
+		return "", nil, err
+	}
 
-	getPanel := func(nameOrIndex string) any {
-		panelId := ""
+	// Templates can't return errors from functions so hold onto the
+	// first one.
+	var getPanelErr error
+	findPanel := func(nameOrIndex string) *PanelInfo {
 		for panelIndex, panel := range project.Pages[pageIndex].Panels {
 			if panel.Name == nameOrIndex || fmt.Sprintf("%d", panelIndex) == nameOrIndex {
-				panelId = panel.Id
-				break
+				return &project.Pages[pageIndex].Panels[panelIndex]
 			}
 		}
 
-		if panelId == "" {
-			errC <- makeErrInvalidDependentPanel(nameOrIndex)
+		if getPanelErr == nil {
+			getPanelErr = makeErrInvalidDependentPanel(nameOrIndex)
+		}
+		return nil
+	}
+
+	getPanel := func(nameOrIndex string) any {
+		panel := findPanel(nameOrIndex)
+		if panel == nil {
+			return nil
 		}
 
-		resultsFile := ec.GetPanelResultsFile(project.Id, panelId)
+		resultsFile := ec.GetPanelResultsFile(project.Id, panel.Id)
 		var a any
 		err := readJSONFileInto(resultsFile, &a)
 		if err != nil {
-			errC <- err
+			if getPanelErr == nil {
+				getPanelErr = err
+			}
 			return nil
 		}
 
 		return a
 	}
 
-	select {
-	case err := <-errC:
-		return "", err
-	default:
-		out, err := tpl.Execute(pongo2.Context{"DM_getPanel": getPanel})
-		return out, err
+	// Round-tripped through JSON so templates see the same keys as
+	// the UI.
+	getPanelStats := func(nameOrIndex string) any {
+		panel := findPanel(nameOrIndex)
+		if panel == nil {
+			return nil
+		}
+
+		var a any
+		bs, err := jsonMarshal(panel.ResultMeta.ColumnStats)
+		if err == nil {
+			err = jsonUnmarshal(bs, &a)
+		}
+		if err != nil {
+			if getPanelErr == nil {
+				getPanelErr = err
+			}
+			return nil
+		}
+
+		return a
+	}
+
+	var params []any
+	param := func(v any) *pongo2.Value {
+		// Nested values are bound as JSON
+		if v != nil && !IsScalar(v) {
+			bs, err := jsonMarshal(v)
+			if err != nil {
+				if getPanelErr == nil {
+					getPanelErr = err
+				}
+				return pongo2.AsSafeValue("")
+			}
+			v = string(bs)
+		}
+
+		params = append(params, v)
+		return pongo2.AsSafeValue(macroParamMarker)
 	}
+
+	tplCtx := pongo2.Context{}
+	for name, value := range vars {
+		tplCtx[name] = value
+	}
+	tplCtx["DM_getPanel"] = getPanel
+	tplCtx["DM_getPanelStats"] = getPanelStats
+	tplCtx["DM_param"] = param
+
+	out, err := tpl.Execute(tplCtx)
+	if getPanelErr != nil {
+		return "", nil, getPanelErr
+	}
+
+	return out, params, err
 }
 
 type EvalContext struct {
 	settings Settings
 	fsBase   string
 	path     string
+	// Only set in long-lived runners
+	conns *connectionCache
+	// Replace variable defaults, from --var for example
+	vars map[string]string
+	// Only set while evaluating a panel
+	stats *evalStats
+	// Only set when something reports progress
+	progress *progressTracker
+	// Ignore cached results, from --force for example
+	force bool
+	// Values for the DM_param placeholders in a database panel's
+	// query
+	params []any
 }
 
 func (ec EvalContext) decrypt(e *Encrypt) (string, error) {
@@ -181,50 +257,169 @@ func (ec EvalContext) decrypt(e *Encrypt) (string, error) {
 }
 
 func NewEvalContext(s Settings, fsBase string) EvalContext {
-	return EvalContext{s, fsBase, ""}
+	return EvalContext{settings: s, fsBase: fsBase}
 }
 
-func (ec EvalContext) Eval(projectId, panelId string) (error, string) {
+// Layers vars over any variables already set.
+func (ec EvalContext) WithVariables(vars map[string]string) EvalContext {
+	merged := map[string]string{}
+	for name, value := range ec.vars {
+		merged[name] = value
+	}
+	for name, value := range vars {
+		merged[name] = value
+	}
+
+	ec.vars = merged
+	return ec
+}
+
+// Ignores cached results when force is set.
+func (ec EvalContext) WithForce(force bool) EvalContext {
+	ec.force = force
+	return ec
+}
+
+// The result has stdout and logs even when evaluating fails.
+// Everything else is only filled in on success.
+func (ec EvalContext) Eval(ctx context.Context, projectId, panelId string) (error, PanelResult) {
 	project, pageIndex, panel, err := ec.getProjectPanel(projectId, panelId)
 	if err != nil {
-		return err, ""
+		return err, PanelResult{}
 	}
 
+	err, _ = ec.evalPanel(ctx, project, pageIndex, panel)
+	return err, panel.ResultMeta
+}
+
+func (ec EvalContext) evalPanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) (error, string) {
+	if ec.settings.PanelTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(ec.settings.PanelTimeout)*time.Second)
+		defer cancel()
+	}
+
+	start := time.Now()
+	ec.stats = &evalStats{
+		projectId: project.Id,
+		panelId:   panel.Id,
+		panelName: panel.Name,
+		panelType: panel.Type,
+		started:   start,
+		limits:    ec.panelResultLimits(*panel),
+	}
+	ec.progress.start(ec.stats)
+	defer ec.progress.done(ec.stats)
+
+	err, stdout := ec.evalPanelByType(ctx, project, pageIndex, panel)
+	if rw := ec.stats.writer; rw != nil && ctx.Err() == nil {
+		err = rw.limitError(err)
+	}
+	if err == nil && ec.stats.cachedResult != nil {
+		result := *ec.stats.cachedResult
+		result.Cached = true
+		result.Logs = ec.stats.getLogs()
+		panel.ResultMeta = result
+		return nil, result.Stdout
+	}
+
+	if err == nil {
+		result, err := ec.getPanelResult(project.Id, panel.Id, time.Since(start))
+		if err != nil {
+			ec.stats.logln(WarnLevel, "Could not get result metadata: %s", err)
+			result = PanelResult{}
+		}
+
+		result.Stdout = stdout
+		if ec.stats.fingerprint != "" && result.Elapsed != nil {
+			err := ec.storeCachedResult(project.Id, panel.Id, ec.stats.fingerprint, result)
+			if err != nil {
+				ec.stats.logln(WarnLevel, "Could not cache results: %s", err)
+			}
+		}
+
+		result.Logs = ec.stats.getLogs()
+		panel.ResultMeta = result
+		return nil, stdout
+	}
+
+	if ctx.Err() != nil {
+		// Whatever was written before stopping is incomplete.
+		rmErr := os.Remove(ec.GetPanelResultsFile(project.Id, panel.Id))
+		if rmErr != nil && !os.IsNotExist(rmErr) {
+			ec.stats.logln(WarnLevel, "Could not remove partial results: %s", rmErr)
+		}
+
+		if ctx.Err() == context.DeadlineExceeded && ec.settings.PanelTimeout > 0 {
+			err = makeErrTimeout(ec.settings.PanelTimeout)
+		} else {
+			err = makeErrCancelled()
+		}
+	}
+
+	// Logs are most interesting when something went wrong
+	ec.stats.logln(ErrorLevel, "Failed to eval: %s", err)
+	panel.ResultMeta = PanelResult{Stdout: stdout, Logs: ec.stats.getLogs()}
+	return err, stdout
+}
+
+func (ec EvalContext) evalPanelByType(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) (error, string) {
+	ec.fillMissingResultShapes(project, pageIndex, *panel)
+
 	panelId, ok := ec.allImportedPanelResultsExist(*project, project.Pages[pageIndex], *panel)
 	if !ok {
 		return makeErrInvalidDependentPanel(panelId), ""
 	}
 
-	panel.Content, err = ec.evalMacros(panel.Content, project, pageIndex)
+	var err error
+	if panel.Type == DatabasePanel {
+		panel.Content, ec.params, err = ec.evalMacrosWithParams(panel.Content, project, pageIndex)
+	} else {
+		panel.Content, err = ec.evalMacros(panel.Content, project, pageIndex)
+	}
 	if err != nil {
 		return err, ""
 	}
 
+	if ttl := ec.panelCacheTTL(*panel); ttl > 0 {
+		fingerprint, err := ec.panelFingerprint(project, pageIndex, *panel)
+		if err != nil {
+			ec.stats.logln(WarnLevel, "Could not fingerprint panel, not caching: %s", err)
+		} else if cached, ok := ec.getCachedResult(project.Id, panel.Id, fingerprint, ttl); ok {
+			ec.stats.logln(InfoLevel, "Reusing cached results of panel: %s", panel.Name)
+			ec.stats.cachedResult = cached
+			return nil, cached.Stdout
+		} else {
+			ec.stats.fingerprint = fingerprint
+		}
+	}
+
+	ec.stats.setPhase("Evaluating %s panel", panel.Type)
 	switch panel.Type {
 	case FilePanel:
-		Logln("Evaling file panel: " + panel.Name)
-		return ec.evalFilePanel(project, pageIndex, panel), ""
+		ec.stats.logln(InfoLevel, "Evaling file panel: %s", panel.Name)
+		return ec.evalFilePanel(ctx, project, pageIndex, panel), ""
 	case HttpPanel:
-		Logln("Evaling http panel: " + panel.Name)
-		return ec.evalHTTPPanel(project, pageIndex, panel), ""
+		ec.stats.logln(InfoLevel, "Evaling http panel: %s", panel.Name)
+		return ec.evalHTTPPanel(ctx, project, pageIndex, panel), ""
 	case LiteralPanel:
-		Logln("Evaling literal panel: " + panel.Name)
-		return ec.evalLiteralPanel(project, pageIndex, panel), ""
+		ec.stats.logln(InfoLevel, "Evaling literal panel: %s", panel.Name)
+		return ec.evalLiteralPanel(ctx, project, pageIndex, panel), ""
 	case ProgramPanel:
-		Logln("Evaling program panel: " + panel.Name)
-		return ec.evalProgramPanel(project, pageIndex, panel)
+		ec.stats.logln(InfoLevel, "Evaling program panel: %s", panel.Name)
+		return ec.evalProgramPanel(ctx, project, pageIndex, panel)
 	case DatabasePanel:
-		Logln("Evaling database panel: " + panel.Name)
-		return ec.EvalDatabasePanel(project, pageIndex, panel, nil, *DefaultCacheSettings), ""
+		ec.stats.logln(InfoLevel, "Evaling database panel: %s", panel.Name)
+		return ec.EvalDatabasePanel(ctx, project, pageIndex, panel, nil, *DefaultCacheSettings), ""
 	case FilaggPanel:
-		Logln("Evaling filagg panel: " + panel.Name)
-		return ec.evalFilaggPanel(project, pageIndex, panel), ""
+		ec.stats.logln(InfoLevel, "Evaling filagg panel: %s", panel.Name)
+		return ec.evalFilaggPanel(ctx, project, pageIndex, panel), ""
 	case TablePanel:
-		Logln("Evaling table panel: " + panel.Name)
-		return ec.evalTablePanel(project, pageIndex, panel), ""
+		ec.stats.logln(InfoLevel, "Evaling table panel: %s", panel.Name)
+		return ec.evalTablePanel(ctx, project, pageIndex, panel), ""
 	case GraphPanel:
-		Logln("Evaling graph panel: " + panel.Name)
-		return ec.evalGraphPanel(project, pageIndex, panel), ""
+		ec.stats.logln(InfoLevel, "Evaling graph panel: %s", panel.Name)
+		return ec.evalGraphPanel(ctx, project, pageIndex, panel), ""
 	}
 
 	return makeErrUnsupported("Unsupported panel type " + string(panel.Type) + " in Go runner"), ""
@@ -0,0 +1,189 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_evalPanel_timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Needs sleep")
+	}
+
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+	ec.settings.PanelTimeout = 1
+
+	panel := PanelInfo{
+		Id:               newId(),
+		Name:             "slow",
+		Type:             ProgramPanel,
+		ProgramPanelInfo: &ProgramPanelInfo{},
+	}
+	panel.Program.Type = CustomProgram
+	panel.Program.CustomExe = "sleep 10"
+	project := &ProjectState{
+		Id:    "timeout-test",
+		Pages: []ProjectPage{{Panels: []PanelInfo{panel}}},
+	}
+
+	// Pretend the program got partway through writing results
+	resultsFile := ec.GetPanelResultsFile(project.Id, panel.Id)
+	err := os.WriteFile(resultsFile, []byte(`[{"a": `), os.ModePerm)
+	assert.Nil(t, err)
+
+	start := time.Now()
+	err, _ = ec.evalPanel(context.Background(), project, 0, &panel)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.NotNil(t, err)
+	assert.Equal(t, "TimeoutError", err.(*DSError).Name)
+
+	_, err = os.Stat(resultsFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_evalPanel_cancelled(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	panel := PanelInfo{
+		Id:      newId(),
+		Name:    "literal",
+		Type:    LiteralPanel,
+		Content: `[{"a": 1}, {"a": 2}]`,
+		LiteralPanelInfo: &LiteralPanelInfo{
+			Literal: LiteralPanelInfoLiteral{
+				ContentTypeInfo: ContentTypeInfo{Type: "application/json"},
+			},
+		},
+	}
+	project := &ProjectState{
+		Id:    "cancel-test",
+		Pages: []ProjectPage{{Panels: []PanelInfo{panel}}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err, _ := ec.evalPanel(ctx, project, 0, &panel)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Cancelled", err.(*DSError).Name)
+
+	_, err = os.Stat(ec.GetPanelResultsFile(project.Id, panel.Id))
+	assert.True(t, os.IsNotExist(err))
+
+	// The same panel evaluates fine without cancellation
+	err, _ = ec.evalPanel(context.Background(), project, 0, &panel)
+	assert.Nil(t, err)
+
+	var m []map[string]any
+	err = readJSONFileInto(ec.GetPanelResultsFile(project.Id, panel.Id), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"a": float64(1)}, {"a": float64(2)}}, m)
+}
+
+func Test_evalPanel_resultMeta(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	literal := func(contentType, content string) PanelInfo {
+		return PanelInfo{
+			Id:      newId(),
+			Name:    contentType,
+			Type:    LiteralPanel,
+			Content: content,
+			LiteralPanelInfo: &LiteralPanelInfo{
+				Literal: LiteralPanelInfoLiteral{
+					ContentTypeInfo: ContentTypeInfo{Type: contentType},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		panel      PanelInfo
+		arrayCount *float64
+	}{
+		{literal("text/csv", "a,b\n1,2\n3,4\n5,6"), &[]float64{3}[0]},
+		// JSON is copied to the results file as-is, rows aren't counted
+		{literal("application/json", `[{"a": 1}, {"a": 2}]`), nil},
+	}
+
+	for _, test := range tests {
+		project := &ProjectState{
+			Id:    "result-meta-test",
+			Pages: []ProjectPage{{Panels: []PanelInfo{test.panel}}},
+		}
+
+		err, _ := ec.evalPanel(context.Background(), project, 0, &test.panel)
+		assert.Nil(t, err)
+
+		fi, err := os.Stat(ec.GetPanelResultsFile(project.Id, test.panel.Id))
+		assert.Nil(t, err)
+
+		result := test.panel.ResultMeta
+		assert.Equal(t, test.arrayCount, result.ArrayCount, test.panel.Name)
+		assert.Equal(t, float64(fi.Size()), *result.Size)
+		assert.Equal(t, ArrayKind, result.Shape.Kind)
+		assert.Equal(t, "application/json", result.ContentType)
+		assert.NotNil(t, result.Elapsed)
+		assert.NotNil(t, result.Timings)
+	}
+}
+
+func Test_evalPanel_macroParams(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	connector, err := MakeTmpSQLiteConnector()
+	assert.Nil(t, err)
+
+	panel := PanelInfo{
+		Id:      newId(),
+		Name:    "query",
+		Type:    DatabasePanel,
+		Content: `SELECT {{ DM_param(name) }} AS name, {{ DM_param(DM_getPanel("0")) }} AS nested`,
+		DatabasePanelInfo: &DatabasePanelInfo{
+			Database: DatabasePanelInfoDatabase{ConnectorId: connector.Id},
+		},
+	}
+	source := PanelInfo{
+		Id:   newId(),
+		Name: "source",
+		Type: LiteralPanel,
+		// Quotes would break the query if they were spliced in
+		Content: `[{"a": "it's"}]`,
+		LiteralPanelInfo: &LiteralPanelInfo{
+			Literal: LiteralPanelInfoLiteral{
+				ContentTypeInfo: ContentTypeInfo{Type: "application/json"},
+			},
+		},
+	}
+	project := &ProjectState{
+		Id:         "macro-params-test",
+		Connectors: []ConnectorInfo{*connector},
+		Pages: []ProjectPage{{
+			Panels:    []PanelInfo{source, panel},
+			Variables: []Variable{{Name: "name", Default: "O'Brien"}},
+		}},
+	}
+
+	err, _ = ec.evalPanel(context.Background(), project, 0, &project.Pages[0].Panels[0])
+	assert.Nil(t, err)
+	err, _ = ec.evalPanel(context.Background(), project, 0, &panel)
+	assert.Nil(t, err)
+
+	var m []map[string]any
+	err = readJSONFileInto(ec.GetPanelResultsFile(project.Id, panel.Id), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"name": "O'Brien", "nested": `[{"a":"it's"}]`}}, m)
+
+	// Only database panels can bind values
+	_, err = ec.evalMacros(`{{ DM_param(name) }}`, project, 0)
+	assert.NotNil(t, err)
+}
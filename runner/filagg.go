@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -136,9 +137,27 @@ func quoteTime(t time.Time, qt quoteType) string {
 	return quote(t.Format("2006-01-02 15:04:05"), qt.string)
 }
 
-func (ec EvalContext) evalFilaggPanel(project *ProjectState, pageIndex int, panel *PanelInfo) error {
+func datetimeExpression(field string, engine SQLEngine) string {
+	if engine == DuckDBEngine {
+		return fmt.Sprintf("CAST(%s AS TIMESTAMP)", field)
+	}
+
+	return fmt.Sprintf("DATETIME(%s)", field)
+}
+
+// Truncates timestamps to the start of their window
+func windowExpression(field string, intervalSeconds int, engine SQLEngine) string {
+	if engine == DuckDBEngine {
+		return fmt.Sprintf("TIME_BUCKET(INTERVAL '%d seconds', %s)", intervalSeconds, datetimeExpression(field, engine))
+	}
+
+	return fmt.Sprintf(`DATETIME(STRFTIME("%%s", %s) - STRFTIME("%%s", %s) %% %d, "unixepoch")`, field, field, intervalSeconds)
+}
+
+func (ec EvalContext) evalFilaggPanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) error {
 	qt := ansiSQLQuote
 	fg := panel.Filagg
+	engine := ec.sqlEngine(panel)
 
 	_, panelIndex, err := getDependentPanel(project.Pages[pageIndex], fg.GetPanelSource())
 	if err != nil {
@@ -154,9 +173,12 @@ func (ec EvalContext) evalFilaggPanel(project *ProjectState, pageIndex int, pane
 
 		groupExpression := quote(fg.GroupBy, qt.identifier)
 		if interval, err := strconv.Atoi(fg.WindowInterval); err == nil && interval > 0 {
-			intervalSeconds := fmt.Sprintf("%d", interval*60)
-			groupExpression = `DATETIME(STRFTIME("%s", ` + fg.GroupBy + `) - STRFTIME("%s", ` + fg.GroupBy + `) % ` + intervalSeconds + `, "unixepoch")`
-			groupColumn = groupExpression + " " + fg.GroupBy
+			field := fg.GroupBy
+			if engine == DuckDBEngine {
+				field = quote(fg.GroupBy, qt.identifier)
+			}
+			groupExpression = windowExpression(field, interval*60, engine)
+			groupColumn = groupExpression + " " + quote(fg.GroupBy, qt.identifier)
 		}
 
 		on := "1"
@@ -172,6 +194,11 @@ func (ec EvalContext) evalFilaggPanel(project *ProjectState, pageIndex int, pane
 		groupByClause = "GROUP BY " + groupExpression
 	}
 
+	fg.Filter, err = ec.evalMacros(fg.Filter, project, pageIndex)
+	if err != nil {
+		return err
+	}
+
 	whereClause := ""
 	if fg.Filter != "" {
 		whereClause = "WHERE " + fg.Filter
@@ -184,10 +211,11 @@ func (ec EvalContext) evalFilaggPanel(project *ProjectState, pageIndex int, pane
 		}
 
 		if !allTime {
-			timeFilter := fmt.Sprintf("DATETIME(%s) > %s AND DATETIME(%s) < %s",
-				quote(fg.Range.Field, qt.identifier),
+			field := datetimeExpression(quote(fg.Range.Field, qt.identifier), engine)
+			timeFilter := fmt.Sprintf("%s > %s AND %s < %s",
+				field,
 				quoteTime(begin, qt),
-				quote(fg.Range.Field, qt.identifier),
+				field,
 				quoteTime(end, qt))
 
 			if fg.Filter != "" {
@@ -226,13 +254,16 @@ func (ec EvalContext) evalFilaggPanel(project *ProjectState, pageIndex int, pane
 		orderByClause,
 		fg.Limit)
 
-	Logln("filagg query: %s", query)
+	ec.stats.logln(InfoLevel, "filagg query: %s", query)
 
 	fakepanel := &PanelInfo{
-		Content: query,
-		Type:    ProgramPanel,
-		Id:      panel.Id,
+		Content:          query,
+		Type:             ProgramPanel,
+		Id:               panel.Id,
+		ProgramPanelInfo: &ProgramPanelInfo{},
 	}
+	fakepanel.Program.Type = SQL
+	fakepanel.Program.Engine = engine
 
-	return ec.evalProgramSQLPanel(project, pageIndex, fakepanel)
+	return ec.evalProgramSQLPanel(ctx, project, pageIndex, fakepanel)
 }
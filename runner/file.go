@@ -2,6 +2,7 @@ package runner
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"hash/maphash"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"unicode"
 
 	"github.com/multiprocessio/go-openoffice"
 	"gopkg.in/yaml.v3"
@@ -96,11 +98,84 @@ func transformCSVFile(in string, out *ResultWriter, delimiter rune, convertNumbe
 	return transformCSV(r, out, delimiter, convertNumbers)
 }
 
+// Stops reading once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cr.r.Read(p)
+}
+
+// Skips whitespace, returns the next byte without consuming it.
+func peekJSONToken(in *bufio.Reader) (byte, error) {
+	for {
+		b, err := in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		if !unicode.IsSpace(rune(b)) {
+			return b, in.UnreadByte()
+		}
+	}
+}
+
+// Raw copies can't be cut off at a row so arrays go through WriteRow
+// when there are limits to honor.
+func transformJSONArray(in io.Reader, out *ResultWriter) error {
+	dec := jsonNewDecoder(in)
+	// Opening [
+	_, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	for dec.More() {
+		var row any
+		err := dec.Decode(&row)
+		if err != nil {
+			return err
+		}
+
+		err = out.WriteRow(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Closing ]
+	_, err = dec.Token()
+	return err
+}
+
 func transformJSON(in *bufio.Reader, out *ResultWriter) error {
+	if out.limits.MaxRows > 0 || out.limits.MaxBytes > 0 {
+		b, err := peekJSONToken(in)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if b == '[' {
+			return transformJSONArray(contextReader{out.ctx, in}, out)
+		}
+
+		// Anything else can only be cut off by failing
+		if out.limits.MaxBytes > 0 {
+			out.stats.logln(WarnLevel, "Results are not an array so they will fail rather than be truncated at %d bytes", out.limits.MaxBytes)
+		}
+	}
+
 	jw := out.w.(*JSONResultItemWriter)
 	jw.raw = true
 	o := jw.bfd
-	_, err := io.Copy(o, in)
+	// Doesn't go through WriteRow so cancellation must be checked here
+	_, err := io.Copy(o, contextReader{out.ctx, in})
 	if err == io.EOF {
 		err = nil
 	}
@@ -641,7 +716,7 @@ func GetMimeType(fileName string, ct ContentTypeInfo) MimeType {
 func TransformFile(fileName string, cti ContentTypeInfo, out *ResultWriter) error {
 	assumedType := GetMimeType(fileName, cti)
 
-	Logln("Assumed '%s' from '%s' given '%s' when loading file", assumedType, cti.Type, fileName)
+	out.stats.logln(InfoLevel, "Assumed '%s' from '%s' given '%s' when loading file", assumedType, cti.Type, fileName)
 	switch assumedType {
 	case JSONMimeType:
 		return transformJSONFile(fileName, out)
@@ -701,7 +776,7 @@ func getServer(project *ProjectState, serverId string) (*ServerInfo, error) {
 	return nil, edsef("Unknown server: %d" + serverId)
 }
 
-func (ec EvalContext) evalFilePanel(project *ProjectState, pageIndex int, panel *PanelInfo) error {
+func (ec EvalContext) evalFilePanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) error {
 	cti := panel.File.ContentTypeInfo
 	fileName := panel.File.Name
 	server, err := getServer(project, panel.ServerId)
@@ -709,7 +784,7 @@ func (ec EvalContext) evalFilePanel(project *ProjectState, pageIndex int, panel
 		return err
 	}
 
-	rw, err := ec.GetResultWriter(project.Id, panel.Id)
+	rw, err := ec.GetResultWriter(ctx, project.Id, panel.Id)
 	if err != nil {
 		return err
 	}
@@ -722,7 +797,7 @@ func (ec EvalContext) evalFilePanel(project *ProjectState, pageIndex int, panel
 			fileName = path.Join("/home", server.Username, fileName[2:])
 		}
 
-		return ec.remoteFileReader(*server, fileName, func(r *bufio.Reader) error {
+		return ec.remoteFileReader(ctx, *server, fileName, func(r *bufio.Reader) error {
 			return TransformReader(r, fileName, cti, rw)
 		})
 	}
@@ -578,7 +578,7 @@ func transformTestFile(filename string, transformFile func(string, *ResultWriter
 	defer outTmp.Close()
 	defer os.Remove(outTmp.Name())
 
-	jw, err := openJSONResultItemWriter(outTmp.Name(), nil)
+	jw, err := openJSONResultItemWriter(outTmp.Name())
 	if err != nil {
 		return nil, err
 	}
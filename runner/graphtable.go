@@ -1,6 +1,10 @@
 package runner
 
-func (ec EvalContext) getResultColumns(project *ProjectState, thisId, panelSourceId string, columns []string, page, pageSize int) error {
+import "context"
+
+// Tables and graphs can only read arrays of objects, at path if
+// there is one.
+func getResultSource(project *ProjectState, panelSourceId, path string) (*PanelInfo, error) {
 	var panelSource *PanelInfo
 outer:
 	for _, page := range project.Pages {
@@ -14,22 +18,54 @@ outer:
 	}
 
 	if panelSource == nil {
-		return makeErrInvalidDependentPanel(panelSourceId)
+		return nil, makeErrInvalidDependentPanel(panelSourceId)
 	}
 
-	if !ShapeIsObjectArray(panelSource.ResultMeta.Shape) {
-		return makeErrNotAnArrayOfObjects(panelSource.Name)
+	shape := &panelSource.ResultMeta.Shape
+	if path != "" {
+		var err error
+		shape, err = shapeAtPath(*shape, path)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	resultFile := ec.GetPanelResultsFile(project.Id, panelSourceId)
+	if !ShapeIsObjectArray(*shape) {
+		return nil, makeErrNotAnArrayOfObjects(panelSource.Name)
+	}
 
-	rows, err := loadJSONArrayFile(resultFile)
-	if err != nil {
+	return panelSource, nil
+}
+
+func (ec EvalContext) getResultColumns(ctx context.Context, project *ProjectState, thisId, panelSourceId, panelSourcePath string, columns []string, page, pageSize int) error {
+	if _, err := getResultSource(project, panelSourceId, panelSourcePath); err != nil {
 		return err
 	}
+
+	// Only the page is read from a columnar copy of the results or
+	// through the row index. Those only cover the top-level array.
 	i := 0
+	ok := false
+	var rows *RowIterator
+	if panelSourcePath == "" {
+		rows, ok = ec.loadColumnarPanel(project.Id, panelSourceId, columns, page*pageSize, pageSize)
+		if !ok {
+			rows, ok = ec.loadIndexedPanel(project.Id, panelSourceId, page*pageSize, pageSize)
+		}
+	}
+	if ok {
+		i = page * pageSize
+	} else {
+		var err error
+		rows, err = loadJSONArrayFileWithPath(ec.GetPanelResultsFile(project.Id, panelSourceId), panelSourcePath)
+		if err != nil {
+			return err
+		}
+	}
 
-	rw, err := ec.GetResultWriter(project.Id, thisId)
+	defer rows.Close()
+
+	rw, err := ec.GetResultWriter(ctx, project.Id, thisId)
 	if err != nil {
 		return err
 	}
@@ -37,12 +73,7 @@ outer:
 
 	rowRequestedColumnsOnly := map[string]any{}
 
-	for {
-		rawRow, ok := <-rows
-		if !ok || rawRow == nil {
-			return nil
-		}
-
+	for rows.Next() {
 		if i >= page*pageSize {
 			if i == (page+1)*pageSize {
 				// Break as soon as possible
@@ -50,7 +81,7 @@ outer:
 			}
 
 			for _, c := range columns {
-				rowRequestedColumnsOnly[c] = GetObjectAtPath(rawRow, c)
+				rowRequestedColumnsOnly[c] = GetObjectAtPath(rows.Row(), c)
 			}
 			err := rw.WriteRow(rowRequestedColumnsOnly)
 			if err != nil {
@@ -60,9 +91,11 @@ outer:
 
 		i++
 	}
+
+	return rows.Err()
 }
 
-func (ec EvalContext) evalTablePanel(project *ProjectState, pageIndex int, panel *PanelInfo) error {
+func (ec EvalContext) evalTablePanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) error {
 	var columns []string
 	for _, col := range panel.Table.Columns {
 		columns = append(columns, col.Field)
@@ -71,10 +104,14 @@ func (ec EvalContext) evalTablePanel(project *ProjectState, pageIndex int, panel
 	if panel.PageSize == 0 {
 		panel.PageSize = 15
 	}
-	return ec.getResultColumns(project, panel.Id, panel.Table.PanelSource, columns, panel.Page, panel.PageSize)
+
+	if len(panel.Table.Sort) > 0 || len(panel.Table.Filters) > 0 {
+		return ec.getSortedResultColumns(ctx, project, panel.Id, panel.Table, columns, panel.Page, panel.PageSize)
+	}
+	return ec.getResultColumns(ctx, project, panel.Id, panel.Table.PanelSource, panel.Table.PanelSourcePath, columns, panel.Page, panel.PageSize)
 }
 
-func (ec EvalContext) evalGraphPanel(project *ProjectState, pageIndex int, panel *PanelInfo) error {
+func (ec EvalContext) evalGraphPanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) error {
 	columns := []string{panel.Graph.X}
 	for _, col := range panel.Graph.Ys {
 		columns = append(columns, col.Field)
@@ -83,5 +120,5 @@ func (ec EvalContext) evalGraphPanel(project *ProjectState, pageIndex int, panel
 	if panel.PageSize == 0 {
 		panel.PageSize = 10_000
 	}
-	return ec.getResultColumns(project, panel.Id, panel.Graph.PanelSource, columns, panel.Page, panel.PageSize)
+	return ec.getResultColumns(ctx, project, panel.Id, panel.Graph.PanelSource, panel.Graph.PanelSourcePath, columns, panel.Page, panel.PageSize)
 }
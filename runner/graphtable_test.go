@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -78,6 +79,62 @@ elsa,12`,
 		{
 			`name,age
 ted,10
+elsa,12
+anna,12
+bob,9`,
+			PanelInfo{
+				Id:   newId(),
+				Type: TablePanel,
+				TablePanelInfo: &TablePanelInfo{
+					Table: TablePanelInfoTable{
+						Columns: []TableColumn{
+							{Field: "name"},
+						},
+						Sort: []TableSortKey{
+							{Field: "age", Desc: true},
+							{Field: "name"},
+						},
+						Filters: []TableFilter{
+							{Field: "age", Operator: NotEqualFilter, Value: "9"},
+						},
+					},
+				},
+			},
+			[]map[string]any{
+				{"name": "anna"},
+				{"name": "elsa"},
+				{"name": "ted"},
+			},
+		},
+		{
+			`name,age
+ted,10
+elsa,12
+anna,12
+bob,9`,
+			PanelInfo{
+				Id:   newId(),
+				Type: TablePanel,
+				TablePanelInfo: &TablePanelInfo{
+					Table: TablePanelInfoTable{
+						Columns: []TableColumn{
+							{Field: "name"},
+						},
+						Filters: []TableFilter{
+							{Field: "name", Operator: ContainsFilter, Value: "e"},
+						},
+					},
+				},
+				Page:     1,
+				PageSize: 1,
+			},
+			[]map[string]any{
+				{"name": "elsa"},
+			},
+		},
+		{
+			`name,age
+ted,10
 elsa,12`,
 			PanelInfo{
 				Id:   newId(),
@@ -131,7 +188,7 @@ elsa,12`,
 		project.Pages[0].Panels[0].Content = test.in
 		project.Pages[0].Panels[1] = test.tableOrGraph
 
-		err = ec.evalLiteralPanel(project, 0, &project.Pages[0].Panels[0])
+		err = ec.evalLiteralPanel(context.Background(), project, 0, &project.Pages[0].Panels[0])
 		assert.Nil(t, err)
 
 		project.Pages[0].Panels[0].ResultMeta.Shape = Shape{
@@ -157,10 +214,10 @@ elsa,12`,
 
 		if test.tableOrGraph.Type == TablePanel {
 			test.tableOrGraph.Table.PanelSource = project.Pages[0].Panels[0].Id
-			err = ec.evalTablePanel(project, 0, &project.Pages[0].Panels[1])
+			err = ec.evalTablePanel(context.Background(), project, 0, &project.Pages[0].Panels[1])
 		} else {
 			test.tableOrGraph.Graph.PanelSource = project.Pages[0].Panels[0].Id
-			err = ec.evalGraphPanel(project, 0, &project.Pages[0].Panels[1])
+			err = ec.evalGraphPanel(context.Background(), project, 0, &project.Pages[0].Panels[1])
 		}
 		assert.Nil(t, err)
 
@@ -174,3 +231,42 @@ elsa,12`,
 		assert.Equal(t, test.out, m)
 	}
 }
+
+func Test_getResultColumns_panelSourcePath(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	sourceId := newId()
+	project := &ProjectState{
+		Id: "graphtable-path",
+		Pages: []ProjectPage{{Panels: []PanelInfo{{
+			Id: sourceId,
+			ResultMeta: PanelResult{
+				Shape: Shape{Kind: ObjectKind, ObjectShape: &ObjectShape{Children: map[string]Shape{
+					"a.b": {Kind: ArrayKind, ArrayShape: &ArrayShape{
+						Children: Shape{Kind: ObjectKind, ObjectShape: &ObjectShape{}},
+					}},
+				}}},
+			},
+		}}}},
+	}
+	sourceFile := ec.GetPanelResultsFile(project.Id, sourceId)
+	defer os.Remove(sourceFile)
+	assert.Nil(t, WriteJSONFile(sourceFile, map[string]any{
+		"a.b": []any{map[string]any{"i": 1}, map[string]any{"i": 2}},
+	}))
+
+	tableId := newId()
+	defer os.Remove(ec.GetPanelResultsFile(project.Id, tableId))
+	defer os.Remove(ec.getRowIndexFile(project.Id, tableId))
+	err := ec.getResultColumns(context.Background(), project, tableId, sourceId, `a\.b`, []string{"i"}, 1, 1)
+	assert.Nil(t, err)
+
+	var got []map[string]any
+	assert.Nil(t, readJSONFileInto(ec.GetPanelResultsFile(project.Id, tableId), &got))
+	assert.Equal(t, []map[string]any{{"i": float64(2)}}, got)
+
+	// Tables still need an array of objects
+	err = ec.getResultColumns(context.Background(), project, tableId, sourceId, "", []string{"i"}, 0, 1)
+	assert.NotNil(t, err)
+}
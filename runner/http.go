@@ -3,6 +3,7 @@ package runner
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"io"
@@ -146,15 +147,18 @@ func getTransport(customCaCerts []string) (*http.Transport, error) {
 	return &http.Transport{TLSClientConfig: config}, nil
 }
 
-func makeHTTPRequest(hr httpRequest) (*http.Response, error) {
+// Whole request deadline used when the context has none
+var defaultHTTPTimeout = time.Second * 15
+
+func makeHTTPRequest(ctx context.Context, hr httpRequest) (*http.Response, error) {
 	var req *http.Request
 	var err error
 	// Convoluted logic to not pass in a typed nil
 	// https://github.com/golang/go/issues/32897
 	if hr.sendBody {
-		req, err = http.NewRequest(hr.method, hr.url, bytes.NewBuffer(hr.body))
+		req, err = http.NewRequestWithContext(ctx, hr.method, hr.url, bytes.NewBuffer(hr.body))
 	} else {
-		req, err = http.NewRequest(hr.method, hr.url, nil)
+		req, err = http.NewRequestWithContext(ctx, hr.method, hr.url, nil)
 	}
 	if err != nil {
 		return nil, err
@@ -171,11 +175,19 @@ func makeHTTPRequest(hr httpRequest) (*http.Response, error) {
 	if hr.allowInsecure {
 		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
-	c := http.Client{Timeout: time.Second * 15, Transport: tr}
+	// Only wait a bounded time for the server to start responding. How
+	// long reading the body may take is up to the panel timeout, or
+	// the default when there's none.
+	tr.DialContext = (&net.Dialer{Timeout: time.Second * 15}).DialContext
+	tr.ResponseHeaderTimeout = time.Second * 15
+	c := http.Client{Transport: tr}
+	if _, ok := ctx.Deadline(); !ok {
+		c.Timeout = defaultHTTPTimeout
+	}
 	return c.Do(req)
 }
 
-func (ec EvalContext) evalHTTPPanel(project *ProjectState, pageIndex int, panel *PanelInfo) error {
+func (ec EvalContext) evalHTTPPanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) error {
 	server, err := getServer(project, panel.ServerId)
 	if err != nil {
 		return err
@@ -188,8 +200,10 @@ func (ec EvalContext) evalHTTPPanel(project *ProjectState, pageIndex int, panel
 		return err
 	}
 
-	for _, header := range h.Headers {
-		header.Value, err = ec.evalMacros(header.Value, project, pageIndex)
+	// Don't modify the panel's own headers
+	h.Headers = append([]HttpConnectorInfoHeader(nil), h.Headers...)
+	for i := range h.Headers {
+		h.Headers[i].Value, err = ec.evalMacros(h.Headers[i].Value, project, pageIndex)
 		if err != nil {
 			return err
 		}
@@ -205,9 +219,9 @@ func (ec EvalContext) evalHTTPPanel(project *ProjectState, pageIndex int, panel
 		customCaCerts = append(customCaCerts, caCert.File)
 	}
 
-	return ec.withRemoteConnection(server, host, port, func(proxyHost, proxyPort string) error {
+	return ec.withRemoteConnection(ctx, server, host, port, func(proxyHost, proxyPort string) error {
 		url := makeHTTPUrl(tls, proxyHost, proxyPort, rest)
-		rsp, err := makeHTTPRequest(httpRequest{
+		rsp, err := makeHTTPRequest(ctx, httpRequest{
 			allowInsecure: h.AllowInsecure,
 			url:           url,
 			headers:       h.Headers,
@@ -232,7 +246,7 @@ func (ec EvalContext) evalHTTPPanel(project *ProjectState, pageIndex int, panel
 
 		}
 
-		rw, err := ec.GetResultWriter(project.Id, panel.Id)
+		rw, err := ec.GetResultWriter(ctx, project.Id, panel.Id)
 		if err != nil {
 			return err
 		}
@@ -246,7 +260,7 @@ func (ec EvalContext) evalHTTPPanel(project *ProjectState, pageIndex int, panel
 
 func TransformReader(r *bufio.Reader, fileName string, cti ContentTypeInfo, out *ResultWriter) error {
 	assumedType := GetMimeType(fileName, cti)
-	Logln("Assumed '%s' from '%s' given '%s'", assumedType, cti.Type, fileName)
+	out.stats.logln(InfoLevel, "Assumed '%s' from '%s' given '%s'", assumedType, cti.Type, fileName)
 
 	switch assumedType {
 	case JSONMimeType:
@@ -325,6 +339,6 @@ func TransformReader(r *bufio.Reader, fileName string, cti ContentTypeInfo, out
 		return transformRegexp(r, out, re)
 	}
 
-	Logln("Unknown format '%s' from '%s' given '%s', transforming as string", assumedType, cti.Type, fileName)
+	out.stats.logln(WarnLevel, "Unknown format '%s' from '%s' given '%s', transforming as string", assumedType, cti.Type, fileName)
 	return transformGeneric(r, out)
 }
@@ -1,7 +1,12 @@
 package runner
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -94,3 +99,39 @@ func Test_getHTTPHostPort(t *testing.T) {
 		assert.Equal(t, ts.expErr, err, ts.url)
 	}
 }
+
+func Test_makeHTTPRequest_defaultTimeout(t *testing.T) {
+	defer func(d time.Duration) { defaultHTTPTimeout = d }(defaultHTTPTimeout)
+	defaultHTTPTimeout = time.Millisecond * 100
+
+	done := make(chan struct{})
+	defer close(done)
+	// Headers come back right away but the body takes a while
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(time.Millisecond * 300):
+			w.Write([]byte("ok"))
+		case <-done:
+		}
+	}))
+	defer server.Close()
+
+	// No deadline, the body stalls past the default
+	rsp, err := makeHTTPRequest(context.Background(), httpRequest{url: server.URL, method: "GET"})
+	assert.Nil(t, err)
+	_, err = io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	assert.NotNil(t, err)
+
+	// The panel timeout replaces the default
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	rsp, err = makeHTTPRequest(ctx, httpRequest{url: server.URL, method: "GET"})
+	assert.Nil(t, err)
+	body, err := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", string(body))
+}
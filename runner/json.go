@@ -1,11 +1,14 @@
 package runner
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
+	"unicode"
 
 	goccy_json "github.com/goccy/go-json"
 	"github.com/tidwall/gjson"
@@ -76,57 +79,221 @@ func WriteJSONFile(file string, value any) error {
 	return encoder.Encode(value)
 }
 
-func loadJSONArrayFileWithPath(f, path string) (chan map[string]any, error) {
-	out := make(chan map[string]any, 1000)
+// Splits on periods not preceded by a backslash.
+func splitJSONPath(path string) []string {
+	var parts []string
+	var part []rune
+	for _, c := range path {
+		if c == '.' {
+			if len(part) > 0 && part[len(part)-1] == '\\' {
+				part[len(part)-1] = '.'
+				continue
+			}
 
-	fd, err := os.Open(f)
+			parts = append(parts, string(part))
+			part = nil
+			continue
+		}
+
+		part = append(part, c)
+	}
+
+	return append(parts, string(part))
+}
+
+// Reads past one complete value without keeping it around.
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if d, ok := t.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// Paths with gjson syntax beyond dots and \. escapes: #, modifiers,
+// wildcards, queries, multipaths.
+func isPlainJSONPath(path string) bool {
+	return !strings.ContainsAny(strings.ReplaceAll(path, `\.`, ""), `#@*?|!\[]{}`)
+}
+
+// gjson needs the whole document in memory so it's only used for
+// paths the streaming walker can't follow.
+func gjsonArrayAtPath(r io.Reader, path string) (io.Reader, error) {
+	data, err := io.ReadAll(r)
 	if err != nil {
-		return nil, err
+		return nil, edse(err)
 	}
 
-	var reader io.Reader = fd
-	if path != "" {
-		data, err := ioutil.ReadAll(fd)
+	value := gjson.GetBytes(data, path)
+	if !value.Exists() {
+		return nil, makeErrUser(fmt.Sprintf("Path does not exist: %s", path))
+	}
+
+	if !value.IsArray() {
+		return nil, makeErrUser(fmt.Sprintf("Value at path is not an array: %s", path))
+	}
+
+	return strings.NewReader(strings.TrimSpace(value.Raw)[1:]), nil
+}
+
+// Walks the token stream of r to the array at path, skipping
+// everything else. Object keys are matched exactly, numbers index
+// into arrays. Other gjson paths are handed to gjson. Returns a
+// reader positioned just after the array's opening [.
+func seekJSONArrayAtPath(r io.Reader, path string) (io.Reader, error) {
+	if !isPlainJSONPath(path) {
+		return gjsonArrayAtPath(r, path)
+	}
+
+	dec := json.NewDecoder(r)
+	for _, part := range splitJSONPath(path) {
+		t, err := dec.Token()
 		if err != nil {
-			return nil, err
+			return nil, edse(err)
+		}
+
+		found := false
+		switch t {
+		case json.Delim('{'):
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, edse(err)
+				}
+
+				if key == part {
+					found = true
+					break
+				}
+
+				if err := skipJSONValue(dec); err != nil {
+					return nil, edse(err)
+				}
+			}
+		case json.Delim('['):
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				break
+			}
+
+			for i := 0; dec.More(); i++ {
+				if i == n {
+					found = true
+					break
+				}
+
+				if err := skipJSONValue(dec); err != nil {
+					return nil, edse(err)
+				}
+			}
+		}
+
+		if !found {
+			return nil, makeErrUser(fmt.Sprintf("Path does not exist: %s", path))
 		}
-		value := gjson.GetBytes(data, path)
-		reader = strings.NewReader(value.String())
 	}
 
-	bs := make([]byte, 1)
-	for {
-		_, err := reader.Read(bs)
+	t, err := dec.Token()
+	if err != nil {
+		return nil, edse(err)
+	}
+
+	if t != json.Delim('[') {
+		return nil, makeErrUser(fmt.Sprintf("Value at path is not an array: %s", path))
+	}
+
+	return io.MultiReader(dec.Buffered(), r), nil
+}
+
+// Streams the elements of the array at path (or the top-level array)
+// without reading the rest of the file into memory. Elements that
+// aren't objects are wrapped by rowFromValue.
+func loadJSONArrayFileWithPath(f, path string) (*RowIterator, error) {
+	fd, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = fd
+	if path != "" {
+		reader, err = seekJSONArrayAtPath(newBufferedReader(fd), path)
 		if err != nil {
+			fd.Close()
 			return nil, err
 		}
+	} else {
+		bs := make([]byte, 1)
+		for {
+			_, err := reader.Read(bs)
+			if err != nil {
+				fd.Close()
+				return nil, err
+			}
 
-		if bs[0] == '[' {
-			break
+			if bs[0] == '[' {
+				break
+			}
 		}
 	}
 
-	go func() {
+	return newRowIterator(func(emit func(map[string]any) bool) error {
 		defer fd.Close()
-		defer close(out)
 
-		var r io.Reader = reader
+		rowErr := func(i int, err error) error {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return edsef("Failed to read row %d of %s: %s", i, f, err)
+		}
 
-		// Stream all JSON objects
+		bs := make([]byte, 1)
+		r := reader
+
+		// Empty arrays have nothing to decode
 		for {
+			_, err := r.Read(bs)
+			if err != nil {
+				return rowErr(0, err)
+			}
+
+			if bs[0] == ']' {
+				return nil
+			}
+
+			if !unicode.IsSpace(rune(bs[0])) {
+				r = io.MultiReader(bytes.NewReader([]byte{bs[0]}), r)
+				break
+			}
+		}
+
+		// Stream all JSON values
+		for i := 0; ; i++ {
 			// Needs to be recreated each time because of buffered data
 			dec := jsonNewDecoder(r)
 
-			var obj map[string]any
-			err := dec.Decode(&obj)
-			if err == io.EOF {
-				return
-			}
+			var v any
+			err := dec.Decode(&v)
 			if err != nil {
-				panic(err)
+				return rowErr(i, err)
 			}
 
-			out <- obj
+			if !emit(rowFromValue(v)) {
+				return nil
+			}
 
 			// Line up all buffered bytes into a new reader
 			r = io.MultiReader(dec.Buffered(), r)
@@ -135,7 +302,7 @@ func loadJSONArrayFileWithPath(f, path string) (chan map[string]any, error) {
 			for {
 				_, err := r.Read(bs)
 				if err != nil {
-					panic(err)
+					return rowErr(i+1, err)
 				}
 
 				if bs[0] == ',' {
@@ -144,15 +311,17 @@ func loadJSONArrayFileWithPath(f, path string) (chan map[string]any, error) {
 
 				// Done processing
 				if bs[0] == ']' {
-					return
+					return nil
+				}
+
+				if !unicode.IsSpace(rune(bs[0])) {
+					return rowErr(i+1, fmt.Errorf("unexpected %q after row", bs[0]))
 				}
 			}
 		}
-	}()
-
-	return out, nil
+	}), nil
 }
 
-func loadJSONArrayFile(f string) (chan map[string]any, error) {
+func loadJSONArrayFile(f string) (*RowIterator, error) {
 	return loadJSONArrayFileWithPath(f, "")
 }
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_splitJSONPath(t *testing.T) {
+	tests := []struct {
+		path string
+		exp  []string
+	}{
+		{"a", []string{"a"}},
+		{"a.b.0", []string{"a", "b", "0"}},
+		{`a\.b.c`, []string{"a.b", "c"}},
+		{`a.b\.c\.d`, []string{"a", "b.c.d"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.exp, splitJSONPath(test.path))
+	}
+}
+
+func Test_isPlainJSONPath(t *testing.T) {
+	assert.True(t, isPlainJSONPath("a.b.0"))
+	assert.True(t, isPlainJSONPath(`a\.b.rows`))
+	assert.False(t, isPlainJSONPath("a.#.b"))
+	assert.False(t, isPlainJSONPath("a|@reverse"))
+	assert.False(t, isPlainJSONPath("a*"))
+	assert.False(t, isPlainJSONPath(`a\*`))
+}
+
+func Test_loadJSONArrayFileWithPath(t *testing.T) {
+	tmp, err := os.CreateTemp("", "json-path")
+	assert.Nil(t, err)
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(`{
+  "skip": {"nested": [1, [2, {"x": "]"}]], "s": "{"},
+  "a.b": {"rows": [{"i": 1}, {"i": 2}]},
+  "empty": [ ],
+  "outer": [[{"i": 3}], [{"i": 4}, {"i": 5}]],
+  "notarray": {"i": 6}
+}`)
+	assert.Nil(t, err)
+	tmp.Close()
+
+	tests := []struct {
+		path   string
+		exp    []map[string]any
+		errMsg string
+	}{
+		{`a\.b.rows`, []map[string]any{{"i": float64(1)}, {"i": float64(2)}}, ""},
+		{"empty", nil, ""},
+		{"outer.1", []map[string]any{{"i": float64(4)}, {"i": float64(5)}}, ""},
+		{"a.b.rows", nil, "Path does not exist: a.b.rows"},
+		{"outer.2", nil, "Path does not exist: outer.2"},
+		{"notarray", nil, "Value at path is not an array: notarray"},
+		// Anything beyond dotted keys still goes through gjson
+		{"outer.#", nil, "Value at path is not an array: outer.#"},
+		{`a\.b.rows|@reverse`, []map[string]any{{"i": float64(2)}, {"i": float64(1)}}, ""},
+		{"a\\.b.rows.#.i", []map[string]any{{"value": float64(1)}, {"value": float64(2)}}, ""},
+		{`a\.b.rows.#(i>1)#`, []map[string]any{{"i": float64(2)}}, ""},
+		{"out*.0", []map[string]any{{"i": float64(3)}}, ""},
+		{"missing.#.i", nil, "Path does not exist: missing.#.i"},
+		{"nope*", nil, "Path does not exist: nope*"},
+	}
+
+	for _, test := range tests {
+		rows, err := loadJSONArrayFileWithPath(tmp.Name(), test.path)
+		if test.errMsg != "" {
+			assert.NotNil(t, err)
+			assert.Equal(t, test.errMsg, err.(*DSError).Message)
+			continue
+		}
+		assert.Nil(t, err)
+
+		var got []map[string]any
+		for rows.Next() {
+			got = append(got, rows.Row())
+		}
+		assert.Nil(t, rows.Err())
+		assert.Equal(t, test.exp, got, test.path)
+	}
+}
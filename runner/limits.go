@@ -0,0 +1,76 @@
+package runner
+
+import "errors"
+
+// Returned by WriteRow once a limit is hit so evaluators stop
+// producing rows. evalPanel turns it into success or a
+// ResultLimitError depending on the panel's OnLimit.
+var errResultTruncated = errors.New("Panel results were truncated")
+
+// Returned by the JSON writer when a write would go past MaxBytes.
+var errResultBytesLimit = errors.New("Panel results exceeded the byte limit")
+
+func (ec EvalContext) panelResultLimits(panel PanelInfo) ResultLimits {
+	limits := panel.ResultLimits
+	defaults := ec.settings.ResultLimits
+	if limits.MaxRows == 0 {
+		limits.MaxRows = defaults.MaxRows
+	}
+	if limits.MaxBytes == 0 {
+		limits.MaxBytes = defaults.MaxBytes
+	}
+	if limits.OnLimit == "" {
+		limits.OnLimit = defaults.OnLimit
+	}
+	if limits.OnLimit == "" {
+		limits.OnLimit = TruncateOnLimit
+	}
+
+	return limits
+}
+
+func (rw *ResultWriter) setLimits(limits ResultLimits) {
+	rw.limits = limits
+	if jw, ok := rw.w.(*JSONResultItemWriter); ok && limits.MaxBytes > 0 {
+		jw.maxBytes = limits.MaxBytes
+		jw.flushed.max = limits.MaxBytes
+	}
+}
+
+func (rw *ResultWriter) cutOff(limit string, max int64) error {
+	rw.truncation = &ResultTruncation{
+		Limit:    limit,
+		Max:      max,
+		RowsSeen: int64(rw.written) + 1,
+	}
+	rw.stats.logln(WarnLevel, "Cutting off results at %d %s", max, limit)
+	return errResultTruncated
+}
+
+// Returns how the results were cut off, if they were. Results
+// written around the ResultWriter can't be cut off cleanly.
+func (rw *ResultWriter) getTruncation() (*ResultTruncation, bool) {
+	if rw.truncation != nil {
+		return rw.truncation, true
+	}
+
+	if jw, ok := rw.w.(*JSONResultItemWriter); ok && jw.flushed.exceeded {
+		return &ResultTruncation{Limit: "bytes", Max: rw.limits.MaxBytes}, false
+	}
+
+	return nil, false
+}
+
+// A clean cut-off isn't an error unless the panel asks for it to be.
+func (rw *ResultWriter) limitError(err error) error {
+	t, clean := rw.getTruncation()
+	if t == nil {
+		return err
+	}
+
+	if !clean || rw.limits.OnLimit == ErrorOnLimit {
+		return makeErrResultLimit(*t)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_panelResultLimits(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+	ec.settings.ResultLimits = ResultLimits{MaxRows: 10, MaxBytes: 100, OnLimit: ErrorOnLimit}
+
+	limits := ec.panelResultLimits(PanelInfo{ResultLimits: ResultLimits{MaxRows: 5}})
+	assert.Equal(t, ResultLimits{MaxRows: 5, MaxBytes: 100, OnLimit: ErrorOnLimit}, limits)
+
+	ec.settings.ResultLimits = ResultLimits{}
+	limits = ec.panelResultLimits(PanelInfo{})
+	assert.Equal(t, ResultLimits{OnLimit: TruncateOnLimit}, limits)
+}
+
+func Test_evalPanel_resultLimits(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	var csv strings.Builder
+	csv.WriteString("a,b\n")
+	for i := 0; i < 100; i++ {
+		csv.WriteString("1,hello\n")
+	}
+
+	literal := func(contentType, content string, limits ResultLimits) PanelInfo {
+		return PanelInfo{
+			Id:           newId(),
+			Name:         contentType,
+			Type:         LiteralPanel,
+			Content:      content,
+			ResultLimits: limits,
+			LiteralPanelInfo: &LiteralPanelInfo{
+				Literal: LiteralPanelInfoLiteral{
+					ContentTypeInfo: ContentTypeInfo{Type: contentType},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		panel      PanelInfo
+		err        bool
+		rows       int
+		truncation *ResultTruncation
+	}{
+		{literal("text/csv", csv.String(), ResultLimits{}), false, 100, nil},
+		{literal("text/csv", csv.String(), ResultLimits{MaxRows: 10}), false, 10, &ResultTruncation{Limit: "rows", Max: 10, RowsSeen: 11}},
+		{literal("text/csv", csv.String(), ResultLimits{MaxBytes: 100}), false, 4, &ResultTruncation{Limit: "bytes", Max: 100, RowsSeen: 5}},
+		{literal("text/csv", csv.String(), ResultLimits{MaxRows: 10, OnLimit: ErrorOnLimit}), true, 0, nil},
+		// JSON arrays are cut off at a row like everything else
+		{literal("application/json", `[{"a": 1}, {"a": 2}, {"a": 3}]`, ResultLimits{MaxRows: 2}), false, 2, &ResultTruncation{Limit: "rows", Max: 2, RowsSeen: 3}},
+		{literal("application/json", ` [{"a": 1}, {"a": 2}, {"a": 3}]`, ResultLimits{MaxBytes: 20}), false, 2, &ResultTruncation{Limit: "bytes", Max: 20, RowsSeen: 3}},
+		// Other JSON is copied as-is so it can't be cut off cleanly
+		{literal("application/json", `{"a": [1, 2, 3]}`, ResultLimits{MaxBytes: 5}), true, 0, nil},
+	}
+
+	for _, test := range tests {
+		project := &ProjectState{
+			Id:    "result-limits-test",
+			Pages: []ProjectPage{{Panels: []PanelInfo{test.panel}}},
+		}
+
+		err, _ := ec.evalPanel(context.Background(), project, 0, &test.panel)
+		resultsFile := ec.GetPanelResultsFile(project.Id, test.panel.Id)
+		defer os.Remove(resultsFile)
+		defer os.Remove(ec.getRowIndexFile(project.Id, test.panel.Id))
+
+		if test.err {
+			assert.NotNil(t, err)
+			assert.Equal(t, "ResultLimitError", err.(*DSError).Name)
+			continue
+		}
+		assert.Nil(t, err)
+
+		result := test.panel.ResultMeta
+		assert.Equal(t, test.truncation != nil, result.Truncated)
+		assert.Equal(t, test.truncation, result.Truncation)
+		assert.Equal(t, float64(test.rows), *result.ArrayCount)
+
+		// Cut off results are still valid
+		var rows []map[string]any
+		assert.Nil(t, readJSONFileInto(resultsFile, &rows))
+		assert.Equal(t, test.rows, len(rows))
+
+		if test.panel.ResultLimits.MaxBytes > 0 {
+			fi, err := os.Stat(resultsFile)
+			assert.Nil(t, err)
+			assert.LessOrEqual(t, fi.Size(), test.panel.ResultLimits.MaxBytes)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package runner
 
-import "bytes"
+import (
+	"bytes"
+	"context"
+)
 
-func (ec EvalContext) evalLiteralPanel(project *ProjectState, pageIndex int, panel *PanelInfo) error {
+func (ec EvalContext) evalLiteralPanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) error {
 	cti := panel.Literal.ContentTypeInfo
 
-	rw, err := ec.GetResultWriter(project.Id, panel.Id)
+	rw, err := ec.GetResultWriter(ctx, project.Id, panel.Id)
 	if err != nil {
 		return err
 	}
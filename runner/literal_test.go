@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -52,7 +53,7 @@ elsa,12`,
 	for _, test := range tests {
 		project.Pages[0].Panels[0].Content = test.in
 
-		err = ec.evalLiteralPanel(project, 0, &project.Pages[0].Panels[0])
+		err = ec.evalLiteralPanel(context.Background(), project, 0, &project.Pages[0].Panels[0])
 		assert.Nil(t, err)
 
 		var m []map[string]any
@@ -0,0 +1,207 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+)
+
+type LogLevel int
+
+const (
+	DebugLevel LogLevel = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+)
+
+var logLevelNames = []string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+func (l LogLevel) String() string {
+	if l < DebugLevel || l > FatalLevel {
+		return "UNKNOWN"
+	}
+
+	return logLevelNames[l]
+}
+
+func ParseLogLevel(s string) (LogLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return LogLevel(i), nil
+		}
+	}
+
+	return InfoLevel, edsef("Unknown log level: %s", s)
+}
+
+var logPrefixOnce sync.Once
+
+var (
+	Verbose = true
+	// Records below this aren't printed. Panels still capture them.
+	MinLogLevel = InfoLevel
+	// One JSON object per line instead of text
+	LogJSON = false
+)
+
+type LogRecord struct {
+	Time   time.Time      `json:"time"`
+	Level  string         `json:"level"`
+	Msg    string         `json:"msg"`
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+func makeLogRecord(level LogLevel, fields map[string]any, msg string, args ...any) LogRecord {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+
+	return LogRecord{
+		Time:   time.Now(),
+		Level:  level.String(),
+		Msg:    msg,
+		Fields: fields,
+	}
+}
+
+func formatLogRecord(r LogRecord) string {
+	if LogJSON {
+		// Fields sit next to time, level and msg
+		m := map[string]any{}
+		for k, v := range r.Fields {
+			m[k] = v
+		}
+		m["time"] = r.Time.Format(time.RFC3339Nano)
+		m["level"] = r.Level
+		m["msg"] = r.Msg
+
+		bs, err := jsonMarshal(m)
+		if err == nil {
+			return string(bs)
+		}
+	}
+
+	line := "[" + r.Level + "] " + r.Time.Format(iso8601Format) + " " + r.Msg
+	if len(r.Fields) == 0 {
+		return line
+	}
+
+	var keys []string
+	for k := range r.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var keyvals []any
+	for _, k := range keys {
+		keyvals = append(keyvals, k, r.Fields[k])
+	}
+
+	bs, err := logfmt.MarshalKeyvals(keyvals...)
+	if err != nil {
+		return line
+	}
+
+	return line + " " + string(bs)
+}
+
+func writeLogRecord(level LogLevel, r LogRecord) {
+	if !Verbose || level < MinLogLevel {
+		return
+	}
+
+	logPrefixOnce.Do(func() {
+		log.SetFlags(0)
+	})
+	log.Print(formatLogRecord(r))
+}
+
+func _logln(level LogLevel, msg string, args ...any) {
+	writeLogRecord(level, makeLogRecord(level, nil, msg, args...))
+}
+
+func Logln(msg string, args ...any) {
+	_logln(InfoLevel, msg, args...)
+}
+
+func Warnln(msg string, args ...any) {
+	_logln(WarnLevel, msg, args...)
+}
+
+func Fatalln(msg string, args ...any) {
+	_logln(FatalLevel, msg, args...)
+	os.Exit(2)
+}
+
+// Records past this are dropped from the panel result but are
+// still printed.
+const maxPanelLogs = 1_000
+
+func (s *evalStats) setConnectorType(t DatabaseConnectorInfoType) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.connectorType = string(t)
+	s.mu.Unlock()
+}
+
+func (s *evalStats) logFields() map[string]any {
+	fields := map[string]any{
+		"projectId": s.projectId,
+		"panelId":   s.panelId,
+		"panelType": string(s.panelType),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connectorType != "" {
+		fields["connectorType"] = s.connectorType
+	}
+	if s.phase != "" {
+		fields["phase"] = s.phase
+	}
+
+	return fields
+}
+
+// Logs with the panel's fields and keeps the record for the panel
+// result. Outside of evaluating a panel this is a plain log.
+func (s *evalStats) logln(level LogLevel, msg string, args ...any) {
+	if s == nil {
+		_logln(level, msg, args...)
+		return
+	}
+
+	r := makeLogRecord(level, s.logFields(), msg, args...)
+	writeLogRecord(level, r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.logs) < maxPanelLogs {
+		s.logs = append(s.logs, r)
+	} else {
+		s.logsDropped++
+	}
+}
+
+func (s *evalStats) getLogs() []LogRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	logs := append([]LogRecord(nil), s.logs...)
+	if s.logsDropped > 0 {
+		logs = append(logs, makeLogRecord(WarnLevel, nil, "Dropped %d more log records", s.logsDropped))
+	}
+
+	return logs
+}
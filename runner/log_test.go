@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseLogLevel(t *testing.T) {
+	for _, test := range []struct {
+		in  string
+		exp LogLevel
+	}{
+		{"debug", DebugLevel},
+		{"INFO", InfoLevel},
+		{"Warn", WarnLevel},
+		{"error", ErrorLevel},
+	} {
+		level, err := ParseLogLevel(test.in)
+		assert.Nil(t, err)
+		assert.Equal(t, test.exp, level)
+	}
+
+	_, err := ParseLogLevel("loud")
+	assert.NotNil(t, err)
+}
+
+func Test_formatLogRecord(t *testing.T) {
+	defer func() { LogJSON = false }()
+
+	r := LogRecord{
+		Time:   time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+		Level:  "WARN",
+		Msg:    "Skipping unknown type: GEOMETRY",
+		Fields: map[string]any{"panelId": "abc", "phase": "Running query"},
+	}
+
+	LogJSON = false
+	assert.Equal(t, `[WARN] 2022-03-01T10:00:00 Skipping unknown type: GEOMETRY panelId=abc phase="Running query"`, formatLogRecord(r))
+
+	LogJSON = true
+	var m map[string]any
+	assert.Nil(t, jsonUnmarshal([]byte(formatLogRecord(r)), &m))
+	assert.Equal(t, map[string]any{
+		"time":    "2022-03-01T10:00:00Z",
+		"level":   "WARN",
+		"msg":     "Skipping unknown type: GEOMETRY",
+		"panelId": "abc",
+		"phase":   "Running query",
+	}, m)
+}
+
+func Test_evalStats_logln(t *testing.T) {
+	verbose := Verbose
+	Verbose = false
+	defer func() { Verbose = verbose }()
+
+	s := &evalStats{projectId: "p", panelId: "abc", panelType: DatabasePanel}
+	s.setConnectorType(PostgresDatabase)
+	s.setPhase("Running query")
+	s.logln(DebugLevel, "Captured even though it isn't printed: %d", 1)
+
+	logs := s.getLogs()
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, "DEBUG", logs[0].Level)
+	assert.Equal(t, "Captured even though it isn't printed: 1", logs[0].Msg)
+	assert.Equal(t, map[string]any{
+		"projectId":     "p",
+		"panelId":       "abc",
+		"panelType":     "database",
+		"connectorType": "postgres",
+		"phase":         "Running query",
+	}, logs[0].Fields)
+
+	for i := 0; i < maxPanelLogs+10; i++ {
+		s.logln(InfoLevel, "row")
+	}
+	logs = s.getLogs()
+	assert.Equal(t, maxPanelLogs+1, len(logs))
+	assert.Equal(t, "Dropped 11 more log records", logs[maxPanelLogs].Msg)
+}
+
+func Test_evalPanel_logs(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	panel := PanelInfo{
+		Id:      newId(),
+		Name:    "csv",
+		Type:    LiteralPanel,
+		Content: "a,b\n1,2",
+		LiteralPanelInfo: &LiteralPanelInfo{
+			Literal: LiteralPanelInfoLiteral{
+				ContentTypeInfo: ContentTypeInfo{Type: "text/csv"},
+			},
+		},
+	}
+	project := &ProjectState{
+		Id:    "logs-test",
+		Pages: []ProjectPage{{Panels: []PanelInfo{panel}}},
+	}
+
+	err, _ := ec.evalPanel(context.Background(), project, 0, &panel)
+	assert.Nil(t, err)
+
+	var msgs []string
+	for _, r := range panel.ResultMeta.Logs {
+		assert.Equal(t, panel.Id, r.Fields["panelId"])
+		msgs = append(msgs, r.Msg)
+	}
+	assert.Contains(t, strings.Join(msgs, "\n"), "Assumed 'text/csv'")
+}
@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -57,14 +58,18 @@ func MakeTmpSQLiteConnector() (*ConnectorInfo, error) {
 	return connector, nil
 }
 
-func (ec EvalContext) evalProgramSQLPanel(project *ProjectState, pageIndex int, panel *PanelInfo) error {
+func (ec EvalContext) evalProgramSQLPanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) error {
+	if ec.sqlEngine(panel) == DuckDBEngine {
+		return ec.evalDuckDBPanel(ctx, project, pageIndex, panel)
+	}
+
 	connector, err := MakeTmpSQLiteConnector()
 	if err != nil {
 		return err
 	}
 	project.Connectors = append(project.Connectors, *connector)
 
-	return ec.EvalDatabasePanel(project, pageIndex, &PanelInfo{
+	return ec.EvalDatabasePanel(ctx, project, pageIndex, &PanelInfo{
 		Type:    DatabasePanel,
 		Id:      panel.Id,
 		Content: panel.Content,
@@ -76,9 +81,9 @@ func (ec EvalContext) evalProgramSQLPanel(project *ProjectState, pageIndex int,
 	}, nil, *DefaultCacheSettings)
 }
 
-func (ec EvalContext) evalProgramPanel(project *ProjectState, pageIndex int, panel *PanelInfo) (error, string) {
+func (ec EvalContext) evalProgramPanel(ctx context.Context, project *ProjectState, pageIndex int, panel *PanelInfo) (error, string) {
 	if panel.Program.Type == SQL {
-		return ec.evalProgramSQLPanel(project, pageIndex, panel), ""
+		return ec.evalProgramSQLPanel(ctx, project, pageIndex, panel), ""
 	}
 
 	var p ProgramEvalInfo
@@ -132,8 +137,8 @@ func (ec EvalContext) evalProgramPanel(project *ProjectState, pageIndex int, pan
 		}
 	}
 
-	Logln("Running program: %s %v", path, args)
-	combined, err := exec.Command(path, args...).CombinedOutput()
+	ec.stats.logln(InfoLevel, "Running program: %s %v", path, args)
+	combined, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
 	maxSize := 100_000
 	if ec.settings.StdoutMaxSize > 0 {
 		maxSize = ec.settings.StdoutMaxSize
@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// What a panel is doing right now. Published while evaluating so
+// long evaluations can show progress and stuck queries stand out.
+type PanelProgress struct {
+	PanelId   string `json:"panelId"`
+	PanelName string `json:"panelName"`
+	// Importing DM_getPanel table t_X, Running query, etc.
+	Phase string `json:"phase"`
+	// Only counts rows written through a ResultWriter
+	Rows int64 `json:"rows"`
+	// Bytes of results flushed to disk so far
+	Bytes int64 `json:"bytes"`
+	// Milliseconds since the panel started evaluating
+	Elapsed float64 `json:"elapsed"`
+}
+
+// Panels currently being evaluated. Shared by copies of an
+// EvalContext.
+type progressTracker struct {
+	mu     sync.Mutex
+	panels map[string]*evalStats
+}
+
+func (ec EvalContext) WithProgress() EvalContext {
+	ec.progress = &progressTracker{panels: map[string]*evalStats{}}
+	return ec
+}
+
+func (p *progressTracker) start(s *evalStats) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.panels[s.panelId] = s
+}
+
+func (p *progressTracker) done(s *evalStats) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.panels[s.panelId] == s {
+		delete(p.panels, s.panelId)
+	}
+}
+
+func (s *evalStats) setPhase(format string, args ...any) {
+	if s == nil {
+		return
+	}
+
+	phase := fmt.Sprintf(format, args...)
+	s.mu.Lock()
+	s.phase = phase
+	s.mu.Unlock()
+}
+
+func (s *evalStats) getPhase() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.phase
+}
+
+// Progress of every panel being evaluated, oldest first.
+func (ec EvalContext) Progress() []PanelProgress {
+	progress := []PanelProgress{}
+	if ec.progress == nil {
+		return progress
+	}
+
+	ec.progress.mu.Lock()
+	var stats []*evalStats
+	for _, s := range ec.progress.panels {
+		stats = append(stats, s)
+	}
+	ec.progress.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].started.Before(stats[j].started)
+	})
+
+	for _, s := range stats {
+		var size int64
+		if fi, err := os.Stat(ec.GetPanelResultsFile(s.projectId, s.panelId)); err == nil {
+			size = fi.Size()
+		}
+
+		progress = append(progress, PanelProgress{
+			PanelId:   s.panelId,
+			PanelName: s.panelName,
+			Phase:     s.getPhase(),
+			Rows:      atomic.LoadInt64(&s.rows),
+			Bytes:     size,
+			Elapsed:   toMilliseconds(time.Since(s.started)),
+		})
+	}
+
+	return progress
+}
+
+func (ec EvalContext) writeProgressFile(file string) error {
+	tmp := file + ".tmp"
+	err := WriteJSONFile(tmp, map[string]any{
+		"updated": time.Now(),
+		"panels":  ec.Progress(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Readers never see a partially written file
+	return os.Rename(tmp, file)
+}
+
+// Rewrites file with the current progress every interval until ctx
+// is done, then removes it. Needs an EvalContext from WithProgress.
+func (ec EvalContext) WriteProgressFile(ctx context.Context, file string, interval time.Duration) {
+	defer func() {
+		err := os.Remove(file)
+		if err != nil && !os.IsNotExist(err) {
+			Logln("Could not remove progress file: %s", err)
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := ec.writeProgressFile(file)
+			if err != nil {
+				Logln("Could not write progress file: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Progress(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Needs sleep")
+	}
+
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+	ec = ec.WithProgress()
+
+	panel := PanelInfo{
+		Id:               newId(),
+		Name:             "slow",
+		Type:             ProgramPanel,
+		ProgramPanelInfo: &ProgramPanelInfo{},
+	}
+	panel.Program.Type = CustomProgram
+	panel.Program.CustomExe = "sleep 10"
+	project := &ProjectState{
+		Id:    "progress-test",
+		Pages: []ProjectPage{{Panels: []PanelInfo{panel}}},
+	}
+
+	progressFile := path.Join(ec.fsBase, "progress-test.progress")
+	ctx, cancel := context.WithCancel(context.Background())
+	progressDone := make(chan struct{})
+	go func() {
+		ec.WriteProgressFile(ctx, progressFile, 10*time.Millisecond)
+		close(progressDone)
+	}()
+
+	evalDone := make(chan struct{})
+	go func() {
+		ec.evalPanel(ctx, project, 0, &panel)
+		close(evalDone)
+	}()
+
+	var written struct {
+		Panels []PanelProgress `json:"panels"`
+	}
+	assert.Eventually(t, func() bool {
+		err := readJSONFileInto(progressFile, &written)
+		return err == nil && len(written.Panels) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, panel.Id, written.Panels[0].PanelId)
+	assert.Equal(t, "slow", written.Panels[0].PanelName)
+	assert.Equal(t, "Evaluating program panel", written.Panels[0].Phase)
+
+	cancel()
+	<-evalDone
+	<-progressDone
+
+	assert.Equal(t, []PanelProgress{}, ec.Progress())
+	_, err := os.Stat(progressFile)
+	assert.True(t, os.IsNotExist(err))
+}
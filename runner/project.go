@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -187,7 +188,24 @@ func (ec EvalContext) getServersFromDatabase(db *sql.DB) ([]ServerInfo, error) {
 	return servers, nil
 }
 
-func (ec EvalContext) getProjectPanel(projectId, panelId string) (*ProjectState, int, *PanelInfo, error) {
+// Project-level variables are stored as a JSON array in the metadata
+// table.
+func (ec EvalContext) getVariablesFromDatabase(db *sql.DB) ([]Variable, error) {
+	var j []byte
+	err := db.QueryRow(`SELECT value FROM "ds_metadata" WHERE key = 'variables'`).Scan(&j)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var variables []Variable
+	err = jsonUnmarshal(j, &variables)
+	return variables, err
+}
+
+func (ec EvalContext) getProject(projectId string) (*ProjectState, error) {
 	file := ec.getProjectFile(projectId)
 
 	var project ProjectState
@@ -195,57 +213,98 @@ func (ec EvalContext) getProjectPanel(projectId, panelId string) (*ProjectState,
 
 	db, err := sql.Open("sqlite3", file)
 	if err != nil {
-		return nil, 0, nil, err
+		return nil, err
 	}
+	defer db.Close()
 
 	project.Pages, err = ec.getPagesFromDatabase(db)
 	if err != nil {
-		return nil, 0, nil, err
+		return nil, err
 	}
 
 	project.Servers, err = ec.getServersFromDatabase(db)
 	if err != nil {
-		return nil, 0, nil, err
+		return nil, err
 	}
 
 	project.Connectors, err = ec.getConnectorsFromDatabase(db)
 	if err != nil {
-		return nil, 0, nil, err
+		return nil, err
+	}
+
+	project.Variables, err = ec.getVariablesFromDatabase(db)
+	if err != nil {
+		return nil, err
 	}
 
 	panels, err := ec.getPanelsFromDatabase(db)
 	if err != nil {
-		return nil, 0, nil, err
+		return nil, err
 	}
 
 	results, err := ec.getResultsFromDatabase(db)
 	if err != nil {
-		return nil, 0, nil, err
+		return nil, err
 	}
 
-	thisPage := -1
-	var thisPanel PanelInfo
 	for i, page := range project.Pages {
 		// Need to assign directly, not to the copy
 		project.Pages[i].Panels = panels[page.Id]
-		page = project.Pages[i]
 
-		for j, panel := range page.Panels {
+		for j, panel := range project.Pages[i].Panels {
 			// Need to assign directly, not to the copy
 			project.Pages[i].Panels[j].ResultMeta = results[panel.Id]
+		}
+	}
+
+	return &project, nil
+}
+
+func (ec EvalContext) getProjectPanel(projectId, panelId string) (*ProjectState, int, *PanelInfo, error) {
+	project, err := ec.getProject(projectId)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	pageIndex, panel, err := findPanel(project, panelId)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	return project, pageIndex, panel, nil
+}
 
+// Returns a copy of the panel so callers can modify it freely.
+func findPanel(project *ProjectState, panelId string) (int, *PanelInfo, error) {
+	for i, page := range project.Pages {
+		for _, panel := range page.Panels {
 			if panel.Id == panelId {
-				thisPanel = panel
-				thisPage = i
+				thisPanel := panel
+				return i, &thisPanel, nil
 			}
 		}
 	}
 
-	if thisPage == -1 {
-		return nil, 0, nil, makeErrNoSuchPanel(panelId)
+	return 0, nil, makeErrNoSuchPanel(panelId)
+}
+
+func makeErrNoSuchPage(pageId string) error {
+	return edsef("Page not found: " + pageId)
+}
+
+// Pages can be referred to by id, name or index.
+func getPageIndex(project *ProjectState, pageIdOrName string) (int, error) {
+	for i, page := range project.Pages {
+		if page.Id == pageIdOrName || page.Name == pageIdOrName {
+			return i, nil
+		}
+	}
+
+	if i, err := strconv.Atoi(pageIdOrName); err == nil && i >= 0 && i < len(project.Pages) {
+		return i, nil
 	}
 
-	return &project, thisPage, &thisPanel, nil
+	return 0, makeErrNoSuchPage(pageIdOrName)
 }
 
 func (ec EvalContext) getProjectResultsFile(projectId string) string {
@@ -2,10 +2,15 @@ package runner
 
 import (
 	"bufio"
-	"math/rand"
+	"context"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	jsonutil "github.com/multiprocessio/go-json"
 
@@ -69,17 +74,29 @@ func recordToMap[T any](row map[string]any, fields *[]string, record []T, conver
 type ResultItemWriter interface {
 	WriteRow(any, int) error
 	SetNamespace(ns string) error
-	Shape(string, int, int) (*Shape, error)
+	Shape(string, int) (*Shape, error)
 	Close() error
 }
 
 type ResultWriter struct {
 	w ResultItemWriter
+	// Optional columnar copy of the rows written to w
+	columnar *ColumnarResultItemWriter
+	// Writing fails once this is done so that evaluators stop
+	// producing rows for a cancelled or timed out panel.
+	ctx   context.Context
+	stats *evalStats
 
 	// Internal state
 
 	// Number of rows written
 	written int
+	limits  ResultLimits
+	// Set once a limit cut the results off
+	truncation *ResultTruncation
+	// Rows aren't a count of anything once namespaced
+	namespaced  bool
+	columnStats *columnStatsCollector
 	// Reusable map for converting records to maps
 	rowCache map[string]any
 	// Used only by record
@@ -87,15 +104,56 @@ type ResultWriter struct {
 }
 
 func NewResultWriter(w ResultItemWriter) *ResultWriter {
-	return &ResultWriter{w: w, rowCache: map[string]any{}}
+	return &ResultWriter{
+		w:           w,
+		ctx:         context.Background(),
+		rowCache:    map[string]any{},
+		columnStats: newColumnStatsCollector(),
+	}
 }
 
 func (rw *ResultWriter) WriteRow(r any) error {
+	if err := rw.ctx.Err(); err != nil {
+		return err
+	}
+
+	if rw.truncation != nil {
+		rw.truncation.RowsSeen++
+		return errResultTruncated
+	}
+
+	if rw.limits.MaxRows > 0 && int64(rw.written) >= rw.limits.MaxRows {
+		return rw.cutOff("rows", rw.limits.MaxRows)
+	}
+
+	defer rw.stats.time(writePhase)()
+	err := rw.w.WriteRow(r, rw.written)
+	if err == errResultBytesLimit {
+		return rw.cutOff("bytes", rw.limits.MaxBytes)
+	}
+	if err != nil {
+		return err
+	}
+
+	if rw.stats != nil {
+		atomic.AddInt64(&rw.stats.rows, 1)
+	}
 	rw.written++
-	return rw.w.WriteRow(r, rw.written-1)
+	if !rw.namespaced {
+		rw.columnStats.add(r)
+	}
+	if rw.columnar == nil {
+		return nil
+	}
+
+	return rw.columnar.WriteRow(r, rw.written-1)
 }
 
 func (rw *ResultWriter) SetNamespace(ns string) error {
+	rw.namespaced = true
+	if rw.columnar != nil {
+		rw.columnar.SetNamespace(ns)
+	}
 	return rw.w.SetNamespace(ns)
 }
 
@@ -124,73 +182,172 @@ func (rw *ResultWriter) WriteBigQueryRecord(r []bigquery.Value, convertNumbers b
 	return rw.WriteRow(rw.rowCache)
 }
 
-func (rw *ResultWriter) Shape(id string, maxBytesToRead, sampleSize int) (*Shape, error) {
-	return rw.w.Shape(id, maxBytesToRead, sampleSize)
+func (rw *ResultWriter) Shape(id string, sampleSize int) (*Shape, error) {
+	return rw.w.Shape(id, sampleSize)
 }
 
 func (rw *ResultWriter) Close() error {
-	return rw.w.Close()
+	defer rw.stats.time(writePhase)()
+	err := rw.w.Close()
+	if err != nil || rw.columnar == nil {
+		return err
+	}
+
+	rw.closeColumnar()
+	return nil
+}
+
+// The columnar copy is only an optimization for readers so failing
+// to write it doesn't fail the panel.
+func (rw *ResultWriter) closeColumnar() {
+	jw, ok := rw.w.(*JSONResultItemWriter)
+	if !ok || jw.raw {
+		// Rows written around the ResultWriter never made it to
+		// the copy
+		rw.columnar.fail()
+		return
+	}
+
+	err := rw.columnar.setResultsFile(jw.fileName)
+	if err == nil {
+		err = rw.columnar.Close()
+	}
+	if err != nil {
+		rw.stats.logln(WarnLevel, "Could not store columnar results: %s", err)
+		rw.columnar.fail()
+	}
+}
+
+// Only known when every row went through WriteRow.
+func (rw *ResultWriter) getColumnStats() map[string]ColumnStats {
+	if _, ok := rw.rowCount(); !ok {
+		return nil
+	}
+
+	return rw.columnStats.stats()
 }
 
-type JSONResultItemWriterOptions struct {
-	sampleMinimum int
-	sampleFreq    int
+// Only known when every row went through WriteRow.
+func (rw *ResultWriter) rowCount() (int, bool) {
+	if jw, ok := rw.w.(*JSONResultItemWriter); ok && jw.raw {
+		return 0, false
+	}
+
+	return rw.written, !rw.namespaced
 }
 
 type JSONResultItemWriter struct {
 	fileName string
 	fd       *os.File
 	bfd      *bufio.Writer
-	opts     JSONResultItemWriterOptions
+	// Optional, records where each row of an array starts
+	index *rowIndexWriter
+	// Rows that would make the file bigger than this aren't written
+	maxBytes int64
 
 	// External state
 	// If writing directly to the bufio, set this to true
 	raw bool
 
 	// Internal state
-	encoder  *jsonutil.StreamEncoder
+	// Bytes flushed from bfd to fd
+	flushed *countingWriter
+	encoder *jsonutil.StreamEncoder
+	// Set once the array of rows has been opened. Arrays are written
+	// directly rather than through encoder so row offsets are known.
+	isArray  bool
 	isObject bool
-	// Sampled rows
-	sample []any
+	// Shape of the rows written so far
+	sampler *shapeSampler
 }
 
-func openJSONResultItemWriter(f string, opts *JSONResultItemWriterOptions) (ResultItemWriter, error) {
+func openJSONResultItemWriter(f string) (ResultItemWriter, error) {
 	var jw JSONResultItemWriter
 	jw.fileName = f
 
-	if opts == nil {
-		jw.opts = JSONResultItemWriterOptions{
-			sampleMinimum: 10_000,
-			sampleFreq:    1_000,
-		}
-	} else {
-		jw.opts = *opts
-	}
-
 	var err error
 	jw.fd, err = openTruncate(f)
 	if err != nil {
 		return nil, err
 	}
 
-	jw.bfd = newBufferedWriter(jw.fd)
+	jw.flushed = &countingWriter{w: jw.fd}
+	jw.bfd = newBufferedWriter(jw.flushed)
 	return &jw, err
 }
 
-func (jw *JSONResultItemWriter) WriteRow(m any, written int) error {
-	if !jw.isObject {
-		if written < jw.opts.sampleMinimum {
-			jw.sample = append(jw.sample, m)
-		} else if rand.Intn(jw.opts.sampleFreq*10) < 10 {
-			jw.sample = append(jw.sample, m)
+type countingWriter struct {
+	w io.Writer
+	n int64
+	// Writes past this fail, when set
+	max      int64
+	exceeded bool
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.max > 0 && cw.n+int64(len(p)) > cw.max {
+		cw.exceeded = true
+		return 0, errResultBytesLimit
+	}
+
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func (jw *JSONResultItemWriter) offset() int64 {
+	return jw.flushed.n + int64(jw.bfd.Buffered())
+}
+
+func (jw *JSONResultItemWriter) writeArrayRow(m any) error {
+	sep := []byte(",\n")
+	if !jw.isArray {
+		sep = []byte("[")
+	}
+
+	bs, err := jsonMarshal(m)
+	if err != nil {
+		return err
+	}
+
+	// Leave room for the closing ]
+	if jw.maxBytes > 0 && jw.offset()+int64(len(sep)+len(bs)+1) > jw.maxBytes {
+		return errResultBytesLimit
+	}
+
+	jw.isArray = true
+	_, err = jw.bfd.Write(sep)
+	if err != nil {
+		return err
+	}
+
+	if jw.index != nil {
+		err = jw.index.add(jw.offset())
+		if err != nil {
+			return err
 		}
 	}
 
-	// Lazily initialize because this starts writing JSON immediately.
-	if jw.encoder == nil {
-		jw.encoder = jsonutil.NewGenericStreamEncoder(jw.bfd, jsonMarshal, true)
+	_, err = jw.bfd.Write(bs)
+	return err
+}
+
+func (jw *JSONResultItemWriter) WriteRow(m any, written int) error {
+	if jw.isObject {
+		return jw.encoder.EncodeRow(m)
+	}
+
+	err := jw.writeArrayRow(m)
+	if err != nil {
+		return err
+	}
+
+	if jw.sampler == nil {
+		jw.sampler = newShapeSampler("", 100)
 	}
-	return jw.encoder.EncodeRow(m)
+	jw.sampler.addValue(m)
+
+	return nil
 }
 
 func (jw *JSONResultItemWriter) SetNamespace(key string) error {
@@ -228,6 +385,30 @@ func (jw *JSONResultItemWriter) SetNamespace(key string) error {
 }
 
 func (jw *JSONResultItemWriter) Close() error {
+	// Only an array of rows written through WriteRow can be indexed
+	index := jw.index
+	if index != nil && (jw.raw || jw.isObject) {
+		index.abandon()
+		index = nil
+	}
+	jw.index = nil
+
+	err := jw.close()
+	if err != nil {
+		if index != nil {
+			index.abandon()
+		}
+		return err
+	}
+
+	if index == nil {
+		return nil
+	}
+
+	return index.close(jw.flushed.n)
+}
+
+func (jw *JSONResultItemWriter) close() error {
 	if jw.encoder != nil {
 		err := jw.encoder.Close()
 		if err != nil {
@@ -240,7 +421,12 @@ func (jw *JSONResultItemWriter) Close() error {
 		if err != nil {
 			return err
 		}
-	} else if jw.encoder == nil && !jw.raw {
+	} else if jw.isArray {
+		err := jw.bfd.WriteByte(']')
+		if err != nil {
+			return err
+		}
+	} else if !jw.raw {
 		// Nothing has been written so enter an empty object
 		_, err := jw.bfd.WriteString("[]")
 		if err != nil {
@@ -256,21 +442,244 @@ func (jw *JSONResultItemWriter) Close() error {
 	return jw.fd.Close()
 }
 
-func (jw *JSONResultItemWriter) Shape(id string, maxBytesToRead, sampleSize int) (*Shape, error) {
-	if len(jw.sample) > 0 {
-		s := GetShape(id, jw.sample, sampleSize)
+func (jw *JSONResultItemWriter) Shape(id string, sampleSize int) (*Shape, error) {
+	if jw.sampler != nil {
+		s := jw.sampler.shape()
 		return &s, nil
 	}
 
-	return ShapeFromFile(jw.fileName, id, maxBytesToRead, sampleSize)
+	return ShapeFromFile(jw.fileName, id, sampleSize)
 }
 
-func (ec EvalContext) GetResultWriter(projectId, panelId string) (*ResultWriter, error) {
+func (ec EvalContext) GetResultWriter(ctx context.Context, projectId, panelId string) (*ResultWriter, error) {
 	out := ec.GetPanelResultsFile(projectId, panelId)
-	jw, err := openJSONResultItemWriter(out, nil)
+	jw, err := openJSONResultItemWriter(out)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewResultWriter(jw), nil
+	jw.(*JSONResultItemWriter).index, err = openRowIndexWriter(ec.getRowIndexFile(projectId, panelId))
+	if err != nil {
+		return nil, err
+	}
+
+	rw := NewResultWriter(jw)
+	rw.ctx = ctx
+	if ec.stats != nil {
+		rw.setLimits(ec.stats.limits)
+	} else {
+		rw.setLimits(ec.panelResultLimits(PanelInfo{}))
+	}
+
+	cw, err := openColumnarResultItemWriter(ec.getColumnarResultsFile(projectId, panelId))
+	if err != nil {
+		return nil, err
+	}
+	if ec.settings.ColumnarResults {
+		rw.columnar = cw
+	}
+
+	if ec.stats != nil {
+		rw.stats = ec.stats
+		ec.stats.writer = rw
+	}
+	return rw, nil
+}
+
+type evalPhase int
+
+const (
+	connectPhase evalPhase = iota
+	importPhase
+	writePhase
+)
+
+// Collected while evaluating a single panel.
+type evalStats struct {
+	projectId string
+	panelId   string
+	panelName string
+	panelType PanelInfoType
+	started   time.Time
+
+	// Nanoseconds spent in each phase
+	phases [3]int64
+	// Rows written so far, read concurrently by progress reports
+	rows int64
+	// The last writer opened for the panel's results
+	writer *ResultWriter
+	// Set when the panel's results can be cached
+	fingerprint string
+	// Set when the panel's results were reused
+	cachedResult *PanelResult
+	limits       ResultLimits
+
+	mu sync.Mutex
+	// Human readable description of what is happening now
+	phase         string
+	connectorType string
+	logs          []LogRecord
+	logsDropped   int
+}
+
+// Use as defer ec.stats.time(connectPhase)(). Does nothing when
+// stats aren't being collected.
+func (s *evalStats) time(p evalPhase) func() {
+	if s == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		atomic.AddInt64(&s.phases[p], int64(time.Since(start)))
+	}
+}
+
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Fills in what is known about the results without reparsing them
+// where possible. Results written by something other than a
+// ResultWriter (program panels for example) are read just far
+// enough to get their shape.
+// Enough of the results to show without reading them all.
+var resultPreviewBytes = 1_000
+
+func previewResultsFile(f string) (string, error) {
+	fd, err := os.Open(f)
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+
+	buf := make([]byte, resultPreviewBytes+1)
+	n, err := io.ReadFull(fd, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	if n <= resultPreviewBytes {
+		return string(buf[:n]), nil
+	}
+
+	// Don't cut a character in half
+	end := resultPreviewBytes
+	for end > 0 && !utf8.RuneStart(buf[end]) {
+		end--
+	}
+
+	return string(buf[:end]) + "...", nil
+}
+
+// Shape of a panel's results saved next to them so readers don't
+// have to scan the results again.
+type resultsShape struct {
+	Shape Shape `json:"shape"`
+	// The results this is the shape of
+	ResultsSize    int64     `json:"resultsSize"`
+	ResultsModTime time.Time `json:"resultsModTime"`
+}
+
+func (ec EvalContext) getResultsShapeFile(projectId, panelId string) string {
+	return ec.GetPanelResultsFile(projectId, panelId) + ".shape"
+}
+
+// Only an optimization for readers so failing to save it doesn't
+// fail the panel.
+func (ec EvalContext) storeResultsShape(projectId, panelId string, shape Shape) {
+	fi, err := os.Stat(ec.GetPanelResultsFile(projectId, panelId))
+	if err == nil {
+		err = WriteJSONFile(ec.getResultsShapeFile(projectId, panelId), &resultsShape{
+			Shape:          shape,
+			ResultsSize:    fi.Size(),
+			ResultsModTime: fi.ModTime(),
+		})
+	}
+	if err != nil {
+		ec.stats.logln(WarnLevel, "Could not store results shape: %s", err)
+	}
+}
+
+// Returns nil when there's no saved shape or it's for older results.
+func (ec EvalContext) loadResultsShape(projectId, panelId string) *Shape {
+	fi, err := os.Stat(ec.GetPanelResultsFile(projectId, panelId))
+	if err != nil {
+		return nil
+	}
+
+	var saved resultsShape
+	err = readJSONFileInto(ec.getResultsShapeFile(projectId, panelId), &saved)
+	if err != nil ||
+		saved.ResultsSize != fi.Size() ||
+		!saved.ResultsModTime.Equal(fi.ModTime()) ||
+		saved.Shape.Kind == "" {
+		return nil
+	}
+
+	return &saved.Shape
+}
+
+func (ec EvalContext) getPanelResult(projectId, panelId string, elapsed time.Duration) (PanelResult, error) {
+	resultsFile := ec.GetPanelResultsFile(projectId, panelId)
+	result := PanelResult{ContentType: "application/json"}
+
+	fi, err := os.Stat(resultsFile)
+	if err != nil {
+		return result, err
+	}
+	size := float64(fi.Size())
+	result.Size = &size
+
+	result.Preview, err = previewResultsFile(resultsFile)
+	if err != nil {
+		return result, err
+	}
+
+	var shape *Shape
+	if rw := ec.stats.writer; rw != nil {
+		shape, err = rw.Shape(panelId, 100)
+		if n, ok := rw.rowCount(); ok {
+			count := float64(n)
+			result.ArrayCount = &count
+		}
+		if t, _ := rw.getTruncation(); t != nil {
+			result.Truncated = true
+			result.Truncation = t
+		}
+		result.ColumnStats = rw.getColumnStats()
+		if err == nil {
+			ec.storeResultsShape(projectId, panelId, *shape)
+		}
+	} else {
+		shape, err = ec.shapeFromResults(projectId, panelId)
+		if ri := ec.openRowIndex(projectId, panelId); ri != nil {
+			count := float64(ri.rows)
+			result.ArrayCount = &count
+			ri.Close()
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Shape = *shape
+
+	ms := toMilliseconds(elapsed)
+	result.Elapsed = &ms
+
+	phase := func(p evalPhase) float64 {
+		return toMilliseconds(time.Duration(atomic.LoadInt64(&ec.stats.phases[p])))
+	}
+	t := &PanelTimings{
+		Connect: phase(connectPhase),
+		Import:  phase(importPhase),
+		Write:   phase(writePhase),
+	}
+	t.Query = ms - t.Connect - t.Import - t.Write
+	if t.Query < 0 {
+		t.Query = 0
+	}
+	result.Timings = t
+
+	return result, nil
 }
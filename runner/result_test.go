@@ -1,7 +1,9 @@
 package runner
 
 import (
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -107,3 +109,66 @@ func Test_recordToMap_convertNumbers(t *testing.T) {
 		assert.Equal(t, test.expect, m)
 	}
 }
+
+func Test_previewResultsFile(t *testing.T) {
+	defer func(n int) { resultPreviewBytes = n }(resultPreviewBytes)
+	resultPreviewBytes = 10
+
+	tests := []struct {
+		contents string
+		preview  string
+	}{
+		{`[1, 2]`, `[1, 2]`},
+		{`[1, 2, 34]`, `[1, 2, 34]`},
+		{`[1, 2, 3, 4, 5]`, `[1, 2, 3, ...`},
+		// Multi-byte characters aren't cut in half
+		{`["aaaaaaaé"]`, `["aaaaaaa...`},
+	}
+
+	for _, test := range tests {
+		tmp, err := os.CreateTemp("", "")
+		assert.Nil(t, err)
+		defer os.Remove(tmp.Name())
+		_, err = tmp.WriteString(test.contents)
+		assert.Nil(t, err)
+		tmp.Close()
+
+		preview, err := previewResultsFile(tmp.Name())
+		assert.Nil(t, err)
+		assert.Equal(t, test.preview, preview)
+	}
+}
+
+func Test_shapeFromResults_saved(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	projectId, panelId := "saved-shape", newId()
+	results := ec.GetPanelResultsFile(projectId, panelId)
+	defer os.Remove(results)
+	defer os.Remove(ec.getResultsShapeFile(projectId, panelId))
+	assert.Nil(t, os.WriteFile(results, []byte(`[{"a": 1}]`), os.ModePerm))
+
+	scanned, err := ec.shapeFromResults(projectId, panelId)
+	assert.Nil(t, err)
+	assert.Nil(t, ec.loadResultsShape(projectId, panelId))
+
+	// Different from the results so it's clear they weren't scanned
+	saved := Shape{Kind: ArrayKind, ArrayShape: &ArrayShape{Children: Shape{
+		Kind: ObjectKind,
+		ObjectShape: &ObjectShape{Children: map[string]Shape{
+			"b": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
+		}},
+	}}}
+	ec.storeResultsShape(projectId, panelId, saved)
+	s, err := ec.shapeFromResults(projectId, panelId)
+	assert.Nil(t, err)
+	assert.Equal(t, saved, *s)
+
+	// Out of date once the results change
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, os.WriteFile(results, []byte(`[{"a": 2}]`), os.ModePerm))
+	s, err = ec.shapeFromResults(projectId, panelId)
+	assert.Nil(t, err)
+	assert.Equal(t, *scanned, *s)
+}
@@ -0,0 +1,208 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// The row index of a results file holds the byte offset of every row
+// in the results array followed by the size of the results file it
+// was written with. Any row can be found without decoding the rows
+// before it.
+const rowIndexEntrySize = 8
+
+type rowIndexWriter struct {
+	fileName string
+	fd       *os.File
+	bfd      *bufio.Writer
+	entry    [rowIndexEntrySize]byte
+}
+
+func (ec EvalContext) getRowIndexFile(projectId, panelId string) string {
+	return ec.GetPanelResultsFile(projectId, panelId) + ".index"
+}
+
+func openRowIndexWriter(f string) (*rowIndexWriter, error) {
+	fd, err := openTruncate(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rowIndexWriter{fileName: f, fd: fd, bfd: newBufferedWriter(fd)}, nil
+}
+
+func (iw *rowIndexWriter) add(offset int64) error {
+	binary.LittleEndian.PutUint64(iw.entry[:], uint64(offset))
+	_, err := iw.bfd.Write(iw.entry[:])
+	return err
+}
+
+// Must be called after the results file is closed.
+func (iw *rowIndexWriter) close(resultsSize int64) error {
+	err := iw.add(resultsSize)
+	if err != nil {
+		iw.abandon()
+		return err
+	}
+
+	err = iw.bfd.Flush()
+	if err != nil {
+		iw.abandon()
+		return err
+	}
+
+	return iw.fd.Close()
+}
+
+// For results that aren't an array of rows written one at a time.
+func (iw *rowIndexWriter) abandon() {
+	iw.fd.Close()
+	os.Remove(iw.fileName)
+}
+
+type rowIndex struct {
+	resultsFile string
+	results     *os.File
+	index       *os.File
+	resultsSize int64
+	rows        int64
+}
+
+// Returns nil when the results have no index or the index is for an
+// older version of the results.
+func (ec EvalContext) openRowIndex(projectId, panelId string) *rowIndex {
+	resultsFile := ec.GetPanelResultsFile(projectId, panelId)
+	rfi, err := os.Stat(resultsFile)
+	if err != nil {
+		return nil
+	}
+
+	index, err := os.Open(ec.getRowIndexFile(projectId, panelId))
+	if err != nil {
+		return nil
+	}
+
+	ifi, err := index.Stat()
+	if err != nil ||
+		ifi.Size() < rowIndexEntrySize ||
+		ifi.Size()%rowIndexEntrySize != 0 ||
+		ifi.ModTime().Before(rfi.ModTime()) {
+		index.Close()
+		return nil
+	}
+
+	ri := &rowIndex{
+		resultsFile: resultsFile,
+		index:       index,
+		rows:        ifi.Size()/rowIndexEntrySize - 1,
+	}
+
+	ri.resultsSize, err = ri.entry(ri.rows)
+	if err != nil || ri.resultsSize != rfi.Size() {
+		index.Close()
+		return nil
+	}
+
+	ri.results, err = os.Open(resultsFile)
+	if err != nil {
+		index.Close()
+		return nil
+	}
+
+	return ri
+}
+
+func (ri *rowIndex) Close() {
+	ri.index.Close()
+	ri.results.Close()
+}
+
+func (ri *rowIndex) entry(i int64) (int64, error) {
+	var entry [rowIndexEntrySize]byte
+	_, err := ri.index.ReadAt(entry[:], i*rowIndexEntrySize)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(binary.LittleEndian.Uint64(entry[:])), nil
+}
+
+// Reads the raw JSON of row i.
+func (ri *rowIndex) rowBytes(i int64) ([]byte, error) {
+	if i < 0 || i >= ri.rows {
+		return nil, io.EOF
+	}
+
+	start, err := ri.entry(i)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last row ends before the closing ]
+	end := ri.resultsSize - 1
+	if i+1 < ri.rows {
+		end, err = ri.entry(i + 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if end < start {
+		return nil, edsef("Corrupt row index for %s", ri.resultsFile)
+	}
+
+	bs := make([]byte, end-start)
+	_, err = ri.results.ReadAt(bs, start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the separator before the next row
+	return bytes.TrimRight(bs, ",\n\r\t "), nil
+}
+
+// Streams rows [offset, offset+limit) of the results. A negative
+// limit reads every row after offset.
+func (ri *rowIndex) objects(offset, limit int64) *RowIterator {
+	end := ri.rows
+	if limit >= 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	return newRowIterator(func(emit func(map[string]any) bool) error {
+		defer ri.Close()
+
+		for i := offset; i < end; i++ {
+			bs, err := ri.rowBytes(i)
+			if err != nil {
+				return edsef("Failed to read row %d of %s: %s", i, ri.resultsFile, err)
+			}
+
+			var v any
+			err = jsonUnmarshal(bs, &v)
+			if err != nil {
+				return edsef("Failed to read row %d of %s: %s", i, ri.resultsFile, err)
+			}
+
+			if !emit(rowFromValue(v)) {
+				return nil
+			}
+		}
+
+		return nil
+	})
+}
+
+// Reads a page of rows without decoding the rows before it when the
+// results have an up-to-date row index. Returns false otherwise.
+func (ec EvalContext) loadIndexedPanel(projectId, panelId string, offset, limit int) (*RowIterator, bool) {
+	ri := ec.openRowIndex(projectId, panelId)
+	if ri == nil {
+		return nil, false
+	}
+
+	return ri.objects(int64(offset), int64(limit)), true
+}
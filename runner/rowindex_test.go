@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_rowIndex(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	projectId, panelId := "rowindex", newId()
+	defer os.Remove(ec.GetPanelResultsFile(projectId, panelId))
+	defer os.Remove(ec.getRowIndexFile(projectId, panelId))
+
+	rows := []map[string]any{
+		{"a": "x,\n", "b": []any{1.0, []any{2.0}}},
+		{"a": "]"},
+		{"a": nil, "b": map[string]any{"c": "}"}},
+	}
+	rw, err := ec.GetResultWriter(context.Background(), projectId, panelId)
+	assert.Nil(t, err)
+	for _, row := range rows {
+		assert.Nil(t, rw.WriteRow(row))
+	}
+	assert.Nil(t, rw.Close())
+
+	// The results are still plain JSON
+	var all []map[string]any
+	assert.Nil(t, readJSONFileInto(ec.GetPanelResultsFile(projectId, panelId), &all))
+	assert.Equal(t, rows, all)
+
+	ri := ec.openRowIndex(projectId, panelId)
+	assert.NotNil(t, ri)
+	assert.Equal(t, int64(3), ri.rows)
+	for i, row := range rows {
+		bs, err := ri.rowBytes(int64(i))
+		assert.Nil(t, err)
+
+		var got map[string]any
+		assert.Nil(t, jsonUnmarshal(bs, &got))
+		assert.Equal(t, row, got)
+	}
+	ri.Close()
+
+	page, ok := ec.loadIndexedPanel(projectId, panelId, 1, 5)
+	assert.True(t, ok)
+	var got []map[string]any
+	for page.Next() {
+		got = append(got, page.Row())
+	}
+	assert.Nil(t, page.Err())
+	assert.Equal(t, rows[1:], got)
+
+	// Out of date once the results change
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, os.WriteFile(ec.GetPanelResultsFile(projectId, panelId), []byte(`[{"a": 1}]`), os.ModePerm))
+	_, ok = ec.loadIndexedPanel(projectId, panelId, 0, 1)
+	assert.False(t, ok)
+}
+
+func Test_rowIndex_notIndexed(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	tests := []func(rw *ResultWriter) error{
+		func(rw *ResultWriter) error {
+			err := rw.SetNamespace("a")
+			if err != nil {
+				return err
+			}
+
+			return rw.WriteRow(map[string]any{"a": 1})
+		},
+		func(rw *ResultWriter) error {
+			jw := rw.w.(*JSONResultItemWriter)
+			jw.raw = true
+			_, err := jw.bfd.WriteString(`[{"a": 1}]`)
+			return err
+		},
+	}
+
+	for _, write := range tests {
+		projectId, panelId := "rowindex", newId()
+		rw, err := ec.GetResultWriter(context.Background(), projectId, panelId)
+		assert.Nil(t, err)
+		assert.Nil(t, write(rw))
+		assert.Nil(t, rw.Close())
+
+		_, err = os.Stat(ec.getRowIndexFile(projectId, panelId))
+		assert.True(t, os.IsNotExist(err))
+		assert.Nil(t, ec.openRowIndex(projectId, panelId))
+		os.Remove(ec.GetPanelResultsFile(projectId, panelId))
+	}
+}
+
+func Test_getResultColumns_indexed(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	sourceId := newId()
+	project := &ProjectState{
+		Id: "rowindex",
+		Pages: []ProjectPage{
+			{
+				Panels: []PanelInfo{
+					{
+						Id: sourceId,
+						ResultMeta: PanelResult{
+							Shape: Shape{
+								Kind: ArrayKind,
+								ArrayShape: &ArrayShape{
+									Children: Shape{Kind: ObjectKind, ObjectShape: &ObjectShape{}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	defer os.Remove(ec.GetPanelResultsFile(project.Id, sourceId))
+	defer os.Remove(ec.getRowIndexFile(project.Id, sourceId))
+
+	rw, err := ec.GetResultWriter(context.Background(), project.Id, sourceId)
+	assert.Nil(t, err)
+	for i := 0; i < 1_000; i++ {
+		assert.Nil(t, rw.WriteRow(map[string]any{"i": i, "nested": map[string]any{"j": i * 2}}))
+	}
+	assert.Nil(t, rw.Close())
+
+	tableId := newId()
+	defer os.Remove(ec.GetPanelResultsFile(project.Id, tableId))
+	defer os.Remove(ec.getRowIndexFile(project.Id, tableId))
+	err = ec.getResultColumns(context.Background(), project, tableId, sourceId, "", []string{"i", "nested.j"}, 99, 10)
+	assert.Nil(t, err)
+
+	var got []map[string]any
+	assert.Nil(t, readJSONFileInto(ec.GetPanelResultsFile(project.Id, tableId), &got))
+	assert.Equal(t, 10, len(got))
+	assert.Equal(t, map[string]any{"i": float64(990), "nested.j": float64(1_980)}, got[0])
+	assert.Equal(t, map[string]any{"i": float64(999), "nested.j": float64(1_998)}, got[9])
+}
@@ -0,0 +1,99 @@
+package runner
+
+// Iterates over the rows of panel results that are read in the
+// background. Next returns false once the rows run out or reading
+// fails, check Err after. Close must be called if the rows aren't
+// read to the end so the reader can stop.
+type RowIterator struct {
+	rows chan rowOrError
+	done chan struct{}
+
+	row      map[string]any
+	err      error
+	finished bool
+	closed   bool
+}
+
+type rowOrError struct {
+	row map[string]any
+	err error
+}
+
+// produce calls emit for every row and stops as soon as emit returns
+// false, which happens when the iterator is closed early.
+func newRowIterator(produce func(emit func(map[string]any) bool) error) *RowIterator {
+	it := &RowIterator{
+		rows: make(chan rowOrError, 1000),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(it.rows)
+
+		err := produce(func(row map[string]any) bool {
+			select {
+			case it.rows <- rowOrError{row: row}:
+				return true
+			case <-it.done:
+				return false
+			}
+		})
+		if err != nil {
+			select {
+			case it.rows <- rowOrError{err: err}:
+			case <-it.done:
+			}
+		}
+	}()
+
+	return it
+}
+
+func (it *RowIterator) Next() bool {
+	if it.finished {
+		return false
+	}
+
+	r, ok := <-it.rows
+	if !ok || r.err != nil {
+		it.finished = true
+		it.row = nil
+		it.err = r.err
+		return false
+	}
+
+	it.row = r.row
+	return true
+}
+
+func (it *RowIterator) Row() map[string]any {
+	return it.row
+}
+
+func (it *RowIterator) Err() error {
+	return it.err
+}
+
+// Stops the reader and waits for it to finish. Safe to call more
+// than once.
+func (it *RowIterator) Close() {
+	if it.closed {
+		return
+	}
+
+	it.closed = true
+	it.finished = true
+	close(it.done)
+	for range it.rows {
+	}
+}
+
+// Wraps array elements that aren't objects so they can be read as
+// rows.
+func rowFromValue(v any) map[string]any {
+	if row, ok := v.(map[string]any); ok {
+		return row
+	}
+
+	return map[string]any{"value": v}
+}
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rowIteratorFromSlice(rows []map[string]any) *RowIterator {
+	return newRowIterator(func(emit func(map[string]any) bool) error {
+		for _, row := range rows {
+			if !emit(row) {
+				return nil
+			}
+		}
+
+		return nil
+	})
+}
+
+func Test_loadJSONArrayFile_errors(t *testing.T) {
+	tests := []struct {
+		in     string
+		exp    []map[string]any
+		errMsg string
+	}{
+		{`[1, "a", null, {"b": 2}]`, []map[string]any{{"value": float64(1)}, {"value": "a"}, {"value": nil}, {"b": float64(2)}}, ""},
+		{`[{"a": 1}, {"a": }]`, []map[string]any{{"a": float64(1)}}, "Failed to read row 1 of"},
+		{`[{"a": 1} {"a": 2}]`, []map[string]any{{"a": float64(1)}}, "Failed to read row 1 of"},
+		{`[{"a": 1},`, []map[string]any{{"a": float64(1)}}, "Failed to read row 1 of"},
+	}
+
+	for _, test := range tests {
+		tmp, err := os.CreateTemp("", "rows")
+		assert.Nil(t, err)
+		defer os.Remove(tmp.Name())
+		tmp.WriteString(test.in)
+		tmp.Close()
+
+		rows, err := loadJSONArrayFile(tmp.Name())
+		assert.Nil(t, err)
+
+		var got []map[string]any
+		for rows.Next() {
+			got = append(got, rows.Row())
+		}
+		assert.Equal(t, test.exp, got, test.in)
+		if test.errMsg == "" {
+			assert.Nil(t, rows.Err())
+		} else {
+			assert.Contains(t, rows.Err().Error(), test.errMsg)
+		}
+
+		// Done iterators stay done
+		assert.False(t, rows.Next())
+		rows.Close()
+	}
+}
+
+func Test_RowIterator_earlyClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	in := make([]map[string]any, 10_000)
+	for i := range in {
+		in[i] = map[string]any{"i": i}
+	}
+
+	for i := 0; i < 10; i++ {
+		rows := rowIteratorFromSlice(in)
+		assert.True(t, rows.Next())
+		rows.Close()
+		rows.Close()
+		assert.False(t, rows.Next())
+		assert.Nil(t, rows.Err())
+	}
+
+	// Closed readers have all exited
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
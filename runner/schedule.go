@@ -0,0 +1,331 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Schedule struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled"`
+	// Five field cron expression or an alias like @daily
+	Cron string `json:"cron"`
+	// In seconds, only used when there is no cron expression
+	Interval int `json:"interval"`
+	// IANA name like America/New_York, defaults to local time
+	TimeZone string `json:"timeZone"`
+	// Older projects stored scheduled exports with a period of day,
+	// week or month.
+	Period string `json:"period"`
+}
+
+var schedulePeriods = map[string]string{
+	"day":   "@daily",
+	"week":  "@weekly",
+	"month": "@monthly",
+}
+
+// Returns the first time the schedule should run after t. The zero
+// time means it never runs again.
+func (s Schedule) next(t time.Time) (time.Time, error) {
+	loc := time.Local
+	if s.TimeZone != "" {
+		var err error
+		loc, err = time.LoadLocation(s.TimeZone)
+		if err != nil {
+			return time.Time{}, edsef("Invalid time zone %s: %s", s.TimeZone, err)
+		}
+	}
+
+	expr := s.Cron
+	if expr == "" {
+		expr = schedulePeriods[s.Period]
+	}
+
+	if expr == "" {
+		if s.Interval <= 0 {
+			return time.Time{}, edsef("Schedule needs a cron expression or a positive interval")
+		}
+
+		return t.Add(time.Duration(s.Interval) * time.Second), nil
+	}
+
+	c, err := parseCron(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return c.next(t.In(loc)), nil
+}
+
+// The page refresh period is treated as one more interval schedule.
+func pageSchedules(page ProjectPage) []Schedule {
+	var schedules []Schedule
+	for _, s := range page.Schedules {
+		if !s.Disabled {
+			schedules = append(schedules, s)
+		}
+	}
+
+	if page.RefreshPeriod > 0 {
+		schedules = append(schedules, Schedule{
+			Id:       "refreshPeriod",
+			Name:     "Refresh period",
+			Interval: page.RefreshPeriod,
+		})
+	}
+
+	return schedules
+}
+
+type ScheduleRun struct {
+	PageId       string              `json:"pageId"`
+	PageName     string              `json:"pageName"`
+	ScheduleIds  []string            `json:"scheduleIds"`
+	ScheduledFor time.Time           `json:"scheduledFor"`
+	Started      time.Time           `json:"started"`
+	Finished     time.Time           `json:"finished"`
+	Exception    *DSError            `json:"exception"`
+	Panels       map[string]*DSError `json:"panels"`
+}
+
+func (ec EvalContext) getScheduleRunsFile(projectId string) string {
+	project := strings.TrimSuffix(filepath.Base(projectId), ".dsproj")
+	return strings.ReplaceAll(path.Join(ec.fsBase, "."+project+".scheduleruns"), "\\", "/")
+}
+
+type Scheduler struct {
+	ec        EvalContext
+	projectId string
+	now       func() time.Time
+
+	// When each schedule last ran, keyed by scheduleKey
+	lastRuns map[string]time.Time
+	warned   map[string]bool
+}
+
+func scheduleKey(pageId, scheduleId string) string {
+	return pageId + "\x00" + scheduleId
+}
+
+// Runs are recorded one JSON object per line so the scheduler can
+// pick up where it left off after a restart.
+func NewScheduler(ec EvalContext, projectId string) (*Scheduler, error) {
+	s := &Scheduler{
+		ec:        ec,
+		projectId: projectId,
+		now:       time.Now,
+		lastRuns:  map[string]time.Time{},
+		warned:    map[string]bool{},
+	}
+
+	f, err := os.Open(ec.getScheduleRunsFile(projectId))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, edse(err)
+	}
+	defer f.Close()
+
+	scanner := newLargeLineScanner(newBufferedReader(f))
+	for scanner.Scan() {
+		var run ScheduleRun
+		err := jsonUnmarshal(scanner.Bytes(), &run)
+		if err != nil {
+			Logln("Skipping invalid schedule run record: %s", err)
+			continue
+		}
+
+		for _, id := range run.ScheduleIds {
+			key := scheduleKey(run.PageId, id)
+			if run.Started.After(s.lastRuns[key]) {
+				s.lastRuns[key] = run.Started
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, edse(err)
+	}
+
+	return s, nil
+}
+
+func (s *Scheduler) recordRun(run ScheduleRun) error {
+	f, err := os.OpenFile(s.ec.getScheduleRunsFile(s.projectId), os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bs, err := jsonMarshal(run)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(bs, '\n'))
+	return err
+}
+
+type dueRun struct {
+	pageId       string
+	scheduleIds  []string
+	scheduledFor time.Time
+}
+
+// Returns the pages with at least one schedule due and when the next
+// schedule after that is due. Schedules missed while nothing was
+// running are due immediately but only run once.
+func (s *Scheduler) dueRuns(project *ProjectState, now time.Time) ([]dueRun, time.Time) {
+	var due []dueRun
+	var next time.Time
+
+	for _, page := range project.Pages {
+		run := dueRun{pageId: page.Id}
+		for _, schedule := range pageSchedules(page) {
+			key := scheduleKey(page.Id, schedule.Id)
+			last, ok := s.lastRuns[key]
+			if !ok {
+				// Never ran, start counting from now
+				last = now
+				s.lastRuns[key] = now
+			}
+
+			scheduledFor, err := schedule.next(last)
+			if err != nil {
+				if !s.warned[key] {
+					Logln("Skipping schedule %s on page %s: %s", schedule.Id, page.Name, err)
+					s.warned[key] = true
+				}
+				continue
+			}
+
+			if scheduledFor.IsZero() {
+				continue
+			}
+
+			if !scheduledFor.After(now) {
+				run.scheduleIds = append(run.scheduleIds, schedule.Id)
+				if run.scheduledFor.IsZero() || scheduledFor.Before(run.scheduledFor) {
+					run.scheduledFor = scheduledFor
+				}
+				continue
+			}
+
+			if next.IsZero() || scheduledFor.Before(next) {
+				next = scheduledFor
+			}
+		}
+
+		if len(run.scheduleIds) > 0 {
+			due = append(due, run)
+		}
+	}
+
+	return due, next
+}
+
+// Evaluates every page that is due, one at a time. Returns when the
+// next schedule is due.
+func (s *Scheduler) runDue(ctx context.Context) (time.Time, error) {
+	project, err := s.ec.getProject(s.projectId)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	due, next := s.dueRuns(project, s.now())
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return next, nil
+		}
+
+		// Each page gets a fresh copy since evaluating updates
+		// result shapes in the project.
+		project, err := s.ec.getProject(s.projectId)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		pageIndex, err := getPageIndex(project, d.pageId)
+		if err != nil {
+			// Deleted since checking what is due
+			continue
+		}
+
+		page := project.Pages[pageIndex]
+		Logln("Running scheduled evaluation of page %s", page.Name)
+		run := ScheduleRun{
+			PageId:       page.Id,
+			PageName:     page.Name,
+			ScheduleIds:  d.scheduleIds,
+			ScheduledFor: d.scheduledFor,
+			Started:      s.now(),
+			Panels:       map[string]*DSError{},
+		}
+
+		outputs, err := s.ec.evalPanelsInDependencyOrder(ctx, project, pagePanelRefs(project, pageIndex))
+		run.Finished = s.now()
+		run.Exception = makeErrException(err)
+		for panelId, output := range outputs {
+			run.Panels[panelId] = makeErrException(output.Err)
+			if output.Err != nil {
+				Logln("Scheduled evaluation of panel %s failed: %s", panelId, output.Err)
+			}
+		}
+
+		for _, id := range d.scheduleIds {
+			s.lastRuns[scheduleKey(page.Id, id)] = run.Started
+		}
+
+		if err := s.recordRun(run); err != nil {
+			Logln("Could not record schedule run: %s", err)
+		}
+	}
+
+	if len(due) > 0 {
+		// Evaluating took time, something else may be due already
+		return s.now(), nil
+	}
+
+	return next, nil
+}
+
+// Project files are reread at least this often to pick up schedule
+// changes.
+var schedulePollInterval = time.Minute
+
+// Evaluates scheduled pages until ctx is done. Evaluations never
+// overlap.
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		next, err := s.runDue(ctx)
+		if err != nil {
+			return err
+		}
+
+		wait := schedulePollInterval
+		if !next.IsZero() {
+			if untilNext := next.Sub(s.now()); untilNext < wait {
+				wait = untilNext
+			}
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Schedule_next(t *testing.T) {
+	from := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	ny, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		schedule Schedule
+		exp      time.Time
+		expErr   bool
+	}{
+		{Schedule{Interval: 90}, from.Add(90 * time.Second), false},
+		{Schedule{Cron: "0 * * * *", TimeZone: "UTC"}, from.Add(time.Hour), false},
+		{Schedule{Cron: "0 9 * * *", TimeZone: "America/New_York"}, time.Date(2022, 3, 1, 9, 0, 0, 0, ny), false},
+		{Schedule{Period: "day", TimeZone: "UTC"}, time.Date(2022, 3, 2, 0, 0, 0, 0, time.UTC), false},
+		{Schedule{}, time.Time{}, true},
+		{Schedule{Cron: "* * * * *", TimeZone: "Nowhere/Special"}, time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		next, err := test.schedule.next(from)
+		if test.expErr {
+			assert.NotNil(t, err)
+			continue
+		}
+
+		assert.Nil(t, err)
+		assert.True(t, test.exp.Equal(next), "%s != %s", test.exp, next)
+	}
+}
+
+func Test_Scheduler(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	literal := PanelInfo{
+		Id:      newId(),
+		Name:    "numbers",
+		Type:    LiteralPanel,
+		Content: `[{"n": 1}]`,
+		LiteralPanelInfo: &LiteralPanelInfo{
+			Literal: LiteralPanelInfoLiteral{
+				ContentTypeInfo: ContentTypeInfo{Type: "application/json"},
+			},
+		},
+	}
+	project := &ProjectState{
+		Id: "schedule-test",
+		Pages: []ProjectPage{
+			{
+				Id:     newId(),
+				Name:   "nightly",
+				Panels: []PanelInfo{literal},
+				Schedules: []Schedule{
+					{Id: "hourly", Cron: "0 * * * *", TimeZone: "UTC"},
+					{Id: "off", Interval: 1, Disabled: true},
+				},
+			},
+			{Id: newId(), Name: "unscheduled"},
+		},
+	}
+	writeTestProjectFile(t, ec.fsBase, project)
+	defer os.Remove(ec.getScheduleRunsFile(project.Id))
+
+	now := time.Date(2022, 3, 1, 10, 30, 0, 0, time.UTC)
+	s, err := NewScheduler(ec, project.Id)
+	assert.Nil(t, err)
+	s.now = func() time.Time { return now }
+
+	// Nothing has run yet so nothing is due until the next hour
+	next, err := s.runDue(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2022, 3, 1, 11, 0, 0, 0, time.UTC), next)
+	assert.False(t, ec.panelResultsExist(project.Id, literal.Id))
+
+	// Several hours were missed, they're caught up with one run
+	now = time.Date(2022, 3, 1, 13, 5, 0, 0, time.UTC)
+	_, err = s.runDue(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, ec.panelResultsExist(project.Id, literal.Id))
+
+	next, err = s.runDue(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2022, 3, 1, 14, 0, 0, 0, time.UTC), next)
+
+	// A new scheduler picks up from the recorded run
+	s, err = NewScheduler(ec, project.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, now, s.lastRuns[scheduleKey(project.Pages[0].Id, "hourly")])
+
+	f, err := os.Open(ec.getScheduleRunsFile(project.Id))
+	assert.Nil(t, err)
+	defer f.Close()
+
+	var runs []ScheduleRun
+	scanner := newLargeLineScanner(newBufferedReader(f))
+	for scanner.Scan() {
+		var run ScheduleRun
+		assert.Nil(t, jsonUnmarshal(scanner.Bytes(), &run))
+		runs = append(runs, run)
+	}
+	assert.Equal(t, 1, len(runs))
+	assert.Equal(t, []string{"hourly"}, runs[0].ScheduleIds)
+	assert.Equal(t, time.Date(2022, 3, 1, 11, 0, 0, 0, time.UTC), runs[0].ScheduledFor.UTC())
+	assert.Nil(t, runs[0].Exception)
+	assert.Nil(t, runs[0].Panels[literal.Id])
+}
@@ -0,0 +1,445 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The long-lived runner speaks JSON-RPC 2.0 over HTTP.
+// See https://www.jsonrpc.org/specification.
+
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+var DEFAULT_SERVE_ADDRESS = "localhost:8777"
+
+// Clients send the token in this file as "Authorization: Bearer
+// <token>". Only the user can read it.
+var DEFAULT_SERVE_TOKEN_FILE = path.Join(CONFIG_FS_BASE, ".runner-token")
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      any             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string    `json:"jsonrpc"`
+	Id      any       `json:"id"`
+	Result  any       `json:"result,omitempty"`
+	Error   *rpcError `json:"error,omitempty"`
+}
+
+type rpcEvalParams struct {
+	ProjectId string            `json:"projectId"`
+	PanelId   string            `json:"panelId"`
+	PageId    string            `json:"pageId"`
+	Variables map[string]string `json:"variables"`
+	// Ignore cached results
+	Force bool `json:"force"`
+}
+
+type rpcCancelParams struct {
+	JobId   string `json:"jobId"`
+	PanelId string `json:"panelId"`
+}
+
+type evalJob struct {
+	Id        string    `json:"id"`
+	Method    string    `json:"method"`
+	ProjectId string    `json:"projectId"`
+	PanelId   string    `json:"panelId,omitempty"`
+	PageId    string    `json:"pageId,omitempty"`
+	Started   time.Time `json:"started"`
+	Cancelled bool      `json:"cancelled"`
+	// Panels of this job currently being evaluated
+	Progress []PanelProgress `json:"progress"`
+
+	ec     EvalContext
+	cancel context.CancelFunc
+}
+
+type cachedProject struct {
+	modTime time.Time
+	project *ProjectState
+}
+
+type EvalServer struct {
+	ec      EvalContext
+	started time.Time
+	token   string
+
+	projectsMu sync.Mutex
+	projects   map[string]cachedProject
+
+	jobsMu sync.Mutex
+	jobs   map[string]*evalJob
+}
+
+func NewEvalServer(s Settings, fsBase, token string) *EvalServer {
+	ec := NewEvalContext(s, fsBase)
+	ec.conns = newConnectionCache()
+
+	return &EvalServer{
+		ec:       ec,
+		started:  time.Now(),
+		token:    token,
+		projects: map[string]cachedProject{},
+		jobs:     map[string]*evalJob{},
+	}
+}
+
+// Reads the token clients need to send, creating the file with a
+// random token if it doesn't exist yet.
+func LoadServeToken(file string) (string, error) {
+	bs, err := os.ReadFile(file)
+	if err == nil {
+		token := strings.TrimSpace(string(bs))
+		if token == "" {
+			return "", edsef("Token file is empty: %s", file)
+		}
+		return token, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	random := make([]byte, 32)
+	_, err = rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+
+	err = os.MkdirAll(path.Dir(file), 0700)
+	if err != nil {
+		return "", err
+	}
+
+	return token, os.WriteFile(file, []byte(token), 0600)
+}
+
+// Accepts host:port or unix:/path/to/socket.
+func ListenRPC(address string) (net.Listener, error) {
+	if strings.HasPrefix(address, "unix:") {
+		return net.Listen("unix", strings.TrimPrefix(address, "unix:"))
+	}
+
+	return net.Listen("tcp", address)
+}
+
+func (es *EvalServer) Close() error {
+	es.jobsMu.Lock()
+	for _, job := range es.jobs {
+		job.cancel()
+	}
+	es.jobsMu.Unlock()
+
+	return es.ec.conns.Close()
+}
+
+// Projects are SQLite databases in WAL mode so a write may only touch
+// the -wal file.
+func projectModTime(file string) (time.Time, error) {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	modTime := stat.ModTime()
+	if walStat, err := os.Stat(file + "-wal"); err == nil && walStat.ModTime().After(modTime) {
+		modTime = walStat.ModTime()
+	}
+
+	return modTime, nil
+}
+
+// Evals modify the project they're given so each one gets its own copy
+// of the cached project.
+func cloneProject(project *ProjectState) *ProjectState {
+	cp := copyProjectForEval(project)
+	cp.Pages = append([]ProjectPage(nil), project.Pages...)
+	for i := range cp.Pages {
+		cp.Pages[i].Panels = append([]PanelInfo(nil), project.Pages[i].Panels...)
+	}
+
+	return cp
+}
+
+func (es *EvalServer) getProject(projectId string) (*ProjectState, error) {
+	file := es.ec.getProjectFile(projectId)
+	modTime, err := projectModTime(file)
+	if err != nil {
+		return nil, edse(err)
+	}
+
+	es.projectsMu.Lock()
+	defer es.projectsMu.Unlock()
+
+	if cached, ok := es.projects[file]; ok && cached.modTime.Equal(modTime) {
+		return cloneProject(cached.project), nil
+	}
+
+	project, err := es.ec.getProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	es.projects[file] = cachedProject{modTime, project}
+	return cloneProject(project), nil
+}
+
+func (es *EvalServer) startJob(ctx context.Context, job *evalJob) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	job.Id = newId()
+	job.Started = time.Now()
+	job.cancel = cancel
+
+	es.jobsMu.Lock()
+	es.jobs[job.Id] = job
+	es.jobsMu.Unlock()
+
+	return ctx, func() {
+		cancel()
+
+		es.jobsMu.Lock()
+		delete(es.jobs, job.Id)
+		es.jobsMu.Unlock()
+	}
+}
+
+// Successful evals include the result metadata like the runner's
+// metaFile does.
+func panelOutputJSON(output PanelEvalOutput) any {
+	if output.Err != nil || output.Result.Elapsed == nil {
+		return map[string]any{
+			"exception": makeErrException(output.Err),
+			"stdout":    output.Stdout,
+			"logs":      output.Result.Logs,
+		}
+	}
+
+	result := output.Result
+	result.Stdout = output.Stdout
+	return &result
+}
+
+func (es *EvalServer) eval(ctx context.Context, ec EvalContext, params rpcEvalParams) any {
+	project, err := es.getProject(params.ProjectId)
+	if err != nil {
+		return panelOutputJSON(PanelEvalOutput{Err: err})
+	}
+
+	pageIndex, panel, err := findPanel(project, params.PanelId)
+	if err != nil {
+		return panelOutputJSON(PanelEvalOutput{Err: err})
+	}
+
+	err, stdout := ec.evalPanel(ctx, project, pageIndex, panel)
+	return panelOutputJSON(PanelEvalOutput{Err: err, Stdout: stdout, Result: panel.ResultMeta})
+}
+
+func panelOutputsJSON(outputs map[string]PanelEvalOutput, err error) map[string]any {
+	panels := map[string]any{}
+	for panelId, output := range outputs {
+		panels[panelId] = panelOutputJSON(output)
+	}
+
+	return map[string]any{
+		"exception": makeErrException(err),
+		"panels":    panels,
+	}
+}
+
+func (es *EvalServer) evalPage(ctx context.Context, ec EvalContext, params rpcEvalParams) any {
+	project, err := es.getProject(params.ProjectId)
+	if err != nil {
+		return panelOutputsJSON(nil, err)
+	}
+
+	pageIndex, err := getPageIndex(project, params.PageId)
+	if err != nil {
+		return panelOutputsJSON(nil, err)
+	}
+
+	outputs, err := ec.evalPanelsInDependencyOrder(ctx, project, pagePanelRefs(project, pageIndex))
+	return panelOutputsJSON(outputs, err)
+}
+
+func (es *EvalServer) evalProject(ctx context.Context, ec EvalContext, params rpcEvalParams) any {
+	project, err := es.getProject(params.ProjectId)
+	if err != nil {
+		return panelOutputsJSON(nil, err)
+	}
+
+	outputs, err := ec.evalPanelsInDependencyOrder(ctx, project, projectPanelRefs(project))
+	return panelOutputsJSON(outputs, err)
+}
+
+func (es *EvalServer) cancel(params rpcCancelParams) any {
+	es.jobsMu.Lock()
+	defer es.jobsMu.Unlock()
+
+	cancelled := 0
+	for _, job := range es.jobs {
+		if job.Id == params.JobId || (params.PanelId != "" && job.PanelId == params.PanelId) {
+			job.cancel()
+			job.Cancelled = true
+			cancelled++
+		}
+	}
+
+	return map[string]any{"cancelled": cancelled}
+}
+
+func (es *EvalServer) status() any {
+	es.jobsMu.Lock()
+	defer es.jobsMu.Unlock()
+
+	jobs := []evalJob{}
+	for _, job := range es.jobs {
+		j := *job
+		j.Progress = job.ec.Progress()
+		jobs = append(jobs, j)
+	}
+
+	return map[string]any{
+		"started": es.started,
+		"jobs":    jobs,
+	}
+}
+
+func (es *EvalServer) handle(ctx context.Context, req rpcRequest) (any, *rpcError) {
+	switch req.Method {
+	case "eval", "evalPage", "evalProject":
+		var params rpcEvalParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+
+		if params.ProjectId == "" {
+			return nil, &rpcError{rpcInvalidParams, "Missing projectId"}
+		}
+
+		job := &evalJob{
+			Method:    req.Method,
+			ProjectId: params.ProjectId,
+			PanelId:   params.PanelId,
+			PageId:    params.PageId,
+			ec:        es.ec.WithVariables(params.Variables).WithForce(params.Force).WithProgress(),
+		}
+		ctx, done := es.startJob(ctx, job)
+		defer done()
+
+		switch req.Method {
+		case "eval":
+			if params.PanelId == "" {
+				return nil, &rpcError{rpcInvalidParams, "Missing panelId"}
+			}
+			return es.eval(ctx, job.ec, params), nil
+		case "evalPage":
+			if params.PageId == "" {
+				return nil, &rpcError{rpcInvalidParams, "Missing pageId"}
+			}
+			return es.evalPage(ctx, job.ec, params), nil
+		default:
+			return es.evalProject(ctx, job.ec, params), nil
+		}
+	case "cancel":
+		var params rpcCancelParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+
+		if params.JobId == "" && params.PanelId == "" {
+			return nil, &rpcError{rpcInvalidParams, "Missing jobId or panelId"}
+		}
+
+		return es.cancel(params), nil
+	case "status":
+		return es.status(), nil
+	}
+
+	return nil, &rpcError{rpcMethodNotFound, "Unknown method: " + req.Method}
+}
+
+// Browsers can send simple cross-site POSTs to localhost, so
+// requests must not come from a page, must be JSON (which needs a
+// preflight cross-site) and must carry the token.
+func (es *EvalServer) authorized(r *http.Request) (int, bool) {
+	if r.Header.Get("Origin") != "" {
+		return http.StatusForbidden, false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return http.StatusUnsupportedMediaType, false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if es.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(es.token)) != 1 {
+		return http.StatusUnauthorized, false
+	}
+
+	return http.StatusOK, true
+}
+
+func (es *EvalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if status, ok := es.authorized(r); !ok {
+		w.WriteHeader(status)
+		return
+	}
+
+	var rsp rpcResponse
+	rsp.JSONRPC = "2.0"
+
+	var req rpcRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		rsp.Error = &rpcError{rpcParseError, err.Error()}
+	} else if req.JSONRPC != "2.0" || req.Method == "" {
+		rsp.Id = req.Id
+		rsp.Error = &rpcError{rpcInvalidRequest, "Expected a JSON-RPC 2.0 request"}
+	} else {
+		if len(req.Params) == 0 {
+			req.Params = json.RawMessage("{}")
+		}
+
+		rsp.Id = req.Id
+		Logln("Handling %s request", req.Method)
+		rsp.Result, rsp.Error = es.handle(r.Context(), req)
+	}
+
+	w.Header().Set("content-type", "application/json")
+	err = jsonNewEncoder(w).Encode(rsp)
+	if err != nil {
+		Logln("Could not write response: %s", err)
+	}
+}
@@ -0,0 +1,212 @@
+package runner
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes just enough of a .dsproj file for the runner to read it.
+func writeTestProjectFile(t *testing.T, fsBase string, project *ProjectState) {
+	db, err := sql.Open("sqlite3", path.Join(fsBase, project.Id+".dsproj"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	for _, table := range []string{"ds_server", "ds_connector", "ds_page"} {
+		_, err = db.Exec("CREATE TABLE " + table + "(id TEXT PRIMARY KEY, position INTEGER NOT NULL, data_json TEXT NOT NULL)")
+		assert.Nil(t, err)
+	}
+	_, err = db.Exec("CREATE TABLE ds_panel(id TEXT PRIMARY KEY, position INTEGER NOT NULL, data_json TEXT NOT NULL, page_id TEXT NOT NULL)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE ds_result(panel_id TEXT NOT NULL, created_at INTEGER NOT NULL, data_json TEXT NOT NULL)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE ds_metadata(key TEXT PRIMARY KEY, value TEXT NOT NULL)")
+	assert.Nil(t, err)
+
+	if project.Variables != nil {
+		bs, err := json.Marshal(project.Variables)
+		assert.Nil(t, err)
+		_, err = db.Exec("INSERT INTO ds_metadata VALUES ('variables', ?)", string(bs))
+		assert.Nil(t, err)
+	}
+
+	insert := func(table, id string, position int, v any) {
+		bs, err := json.Marshal(v)
+		assert.Nil(t, err)
+		_, err = db.Exec("INSERT INTO "+table+" VALUES (?, ?, ?)", id, position, string(bs))
+		assert.Nil(t, err)
+	}
+
+	for i, server := range project.Servers {
+		insert("ds_server", server.Id, i, server)
+	}
+
+	for i, connector := range project.Connectors {
+		insert("ds_connector", connector.Id, i, connector)
+	}
+
+	for i, page := range project.Pages {
+		panels := page.Panels
+		page.Panels = nil
+		insert("ds_page", page.Id, i, page)
+
+		for j, panel := range panels {
+			panel.PageId = page.Id
+			bs, err := json.Marshal(panel)
+			assert.Nil(t, err)
+			_, err = db.Exec("INSERT INTO ds_panel VALUES (?, ?, ?, ?)", panel.Id, j, string(bs), page.Id)
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func makeTestRPCRequest(t *testing.T, es *EvalServer, method string, params any) map[string]any {
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+es.token)
+	rec := httptest.NewRecorder()
+	es.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var rsp map[string]any
+	err = json.Unmarshal(rec.Body.Bytes(), &rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, "2.0", rsp["jsonrpc"])
+	assert.Equal(t, float64(1), rsp["id"])
+	return rsp
+}
+
+func Test_EvalServer(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	literal := PanelInfo{
+		Id:      newId(),
+		Name:    "numbers",
+		Type:    LiteralPanel,
+		Content: `[{"n": 1}, {"n": 2}]`,
+		LiteralPanelInfo: &LiteralPanelInfo{
+			Literal: LiteralPanelInfoLiteral{
+				ContentTypeInfo: ContentTypeInfo{Type: "application/json"},
+			},
+		},
+	}
+	sum := PanelInfo{
+		Id:               newId(),
+		Name:             "sum",
+		Type:             ProgramPanel,
+		Content:          "SELECT SUM(n) AS total FROM DM_getPanel('numbers')",
+		ProgramPanelInfo: &ProgramPanelInfo{},
+	}
+	sum.Program.Type = SQL
+
+	project := &ProjectState{
+		Id: "serve-test",
+		Pages: []ProjectPage{
+			{Id: newId(), Name: "main", Panels: []PanelInfo{sum, literal}},
+		},
+	}
+	writeTestProjectFile(t, ec.fsBase, project)
+
+	es := NewEvalServer(ec.settings, ec.fsBase, "test-token")
+	defer es.Close()
+
+	rsp := makeTestRPCRequest(t, es, "status", nil)
+	assert.Equal(t, []any{}, rsp["result"].(map[string]any)["jobs"])
+
+	rsp = makeTestRPCRequest(t, es, "evalPage", map[string]any{"projectId": project.Id, "pageId": "main"})
+	assert.Nil(t, rsp["error"])
+	result := rsp["result"].(map[string]any)
+	assert.Nil(t, result["exception"])
+	for _, panel := range project.Pages[0].Panels {
+		assert.Nil(t, result["panels"].(map[string]any)[panel.Id].(map[string]any)["exception"])
+	}
+
+	var m []map[string]any
+	err := readJSONFileInto(ec.GetPanelResultsFile(project.Id, sum.Id), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"total": float64(3)}}, m)
+
+	// Now that the literal has results, the sum can be evaluated alone
+	rsp = makeTestRPCRequest(t, es, "eval", map[string]any{"projectId": project.Id, "panelId": sum.Id})
+	assert.Nil(t, rsp["error"])
+	assert.Nil(t, rsp["result"].(map[string]any)["exception"])
+
+	rsp = makeTestRPCRequest(t, es, "eval", map[string]any{"projectId": project.Id, "panelId": "no such panel"})
+	assert.Equal(t, "Error", rsp["result"].(map[string]any)["exception"].(map[string]any)["name"])
+
+	rsp = makeTestRPCRequest(t, es, "cancel", map[string]any{})
+	assert.Equal(t, float64(rpcInvalidParams), rsp["error"].(map[string]any)["code"])
+
+	rsp = makeTestRPCRequest(t, es, "cancel", map[string]any{"panelId": sum.Id})
+	assert.Equal(t, float64(0), rsp["result"].(map[string]any)["cancelled"])
+
+	rsp = makeTestRPCRequest(t, es, "nope", nil)
+	assert.Equal(t, float64(rpcMethodNotFound), rsp["error"].(map[string]any)["code"])
+}
+
+func Test_EvalServer_unauthorized(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	es := NewEvalServer(ec.settings, ec.fsBase, "test-token")
+	defer es.Close()
+
+	body := `{"jsonrpc": "2.0", "id": 1, "method": "status"}`
+	tests := []struct {
+		headers map[string]string
+		status  int
+	}{
+		// What a cross-site form or fetch without preflight sends
+		{map[string]string{"Content-Type": "text/plain", "Authorization": "Bearer test-token"}, http.StatusUnsupportedMediaType},
+		{map[string]string{"Content-Type": "application/json", "Authorization": "Bearer test-token", "Origin": "https://example.com"}, http.StatusForbidden},
+		{map[string]string{"Content-Type": "application/json"}, http.StatusUnauthorized},
+		{map[string]string{"Content-Type": "application/json", "Authorization": "Bearer wrong"}, http.StatusUnauthorized},
+		{map[string]string{"Content-Type": "application/json; charset=utf-8", "Authorization": "Bearer test-token"}, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		es.ServeHTTP(rec, req)
+		assert.Equal(t, test.status, rec.Code, test.headers)
+	}
+}
+
+func Test_LoadServeToken(t *testing.T) {
+	dir, err := os.MkdirTemp("", "serve-token")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "sub", ".runner-token")
+	token, err := LoadServeToken(file)
+	assert.Nil(t, err)
+	assert.Len(t, token, 64)
+
+	fi, err := os.Stat(file)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	again, err := LoadServeToken(file)
+	assert.Nil(t, err)
+	assert.Equal(t, token, again)
+}
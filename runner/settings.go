@@ -21,8 +21,22 @@ type Settings struct {
 	Languages     map[SupportedLanguages]LanguageSettings `json:"languages"`
 	File          string                                  `json:"file"`
 	StdoutMaxSize int                                     `json:"stdoutMaxSize"`
-	Theme         Theme                                   `json:"theme"`
-	CaCerts       []struct {
+	PanelTimeout  int                                     `json:"panelTimeout"` // In seconds, 0 means no timeout
+	// In seconds, 0 means results aren't reused unless a panel sets
+	// its own TTL
+	ResultCacheTTL int `json:"resultCacheTtl"`
+	// Also store results that are arrays of flat objects as Parquet
+	// so downstream panels can read only the columns they need
+	ColumnarResults bool `json:"columnarResults"`
+	// Defaults for panels that don't set their own
+	ResultLimits ResultLimits `json:"resultLimits"`
+	// Engine for SQL program panels and filagg panels, empty means
+	// SQLite
+	SQLEngine SQLEngine `json:"sqlEngine"`
+	// Looked up on PATH when empty
+	DuckDBPath string `json:"duckdbPath"`
+	Theme      Theme  `json:"theme"`
+	CaCerts    []struct {
 		File string `json:"file"`
 	} `json:"caCerts"`
 }
@@ -1,15 +1,16 @@
 package runner
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
+	"math"
 	"math/rand"
 	"os"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 )
 
 type ShapeKind string
@@ -84,99 +85,35 @@ func (s *Shape) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func (s *Shape) MarshalJSON() ([]byte, error) {
-	if s == nil {
-		return nil, nil
-	}
-
+// Written the way UnmarshalJSON and the UI's shape library read it.
+// The embedded shapes can't be marshaled as-is since their children
+// fields collide. A value receiver so that shapes in maps and slices
+// go through this too.
+func (s Shape) MarshalJSON() ([]byte, error) {
 	switch s.Kind {
 	case ScalarKind:
-		return []byte(`{"kind": "scalar", "scalar": "` + s.ScalarShape.Name + `"}`), nil
-	case UnknownKind:
-		return []byte(`{"kind": "unknown"}`), nil
+		return json.Marshal(struct {
+			Kind ShapeKind `json:"kind"`
+			*ScalarShape
+		}{s.Kind, s.ScalarShape})
+	case UnknownKind, "":
+		// The zero Shape reads back as unknown anyway
+		return []byte(`{"kind":"unknown"}`), nil
 	case ObjectKind:
-		buf := bytes.NewBufferString(`{"kind": "object", "object": {`)
-		first := true
-		for child, childShape := range s.ObjectShape.Children {
-			if !first {
-				err := buf.WriteByte(',')
-				if err != nil {
-					return nil, err
-				}
-			}
-			first = false
-
-			err := buf.WriteByte('"')
-			if err != nil {
-				return nil, err
-			}
-
-			_, err = buf.WriteString(child)
-			if err != nil {
-				return nil, err
-			}
-
-			_, err = buf.WriteString(`": `)
-			if err != nil {
-				return nil, err
-			}
-
-			bs, err := childShape.MarshalJSON()
-			if err != nil {
-				return nil, err
-			}
-
-			_, err = buf.Write(bs)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		_, err := buf.WriteString("}}")
-		if err != nil {
-			return nil, err
-		}
-
-		return buf.Bytes(), nil
+		return json.Marshal(struct {
+			Kind     ShapeKind        `json:"kind"`
+			Children map[string]Shape `json:"children"`
+		}{s.Kind, s.ObjectShape.Children})
 	case ArrayKind:
-		buf := bytes.NewBufferString(`{"kind": "array", "array": `)
-		bs, err := s.ArrayShape.Children.MarshalJSON()
-		if err != nil {
-			return nil, err
-		}
-
-		_, err = buf.Write(bs)
-		if err != nil {
-			return nil, err
-		}
-
-		err = buf.WriteByte('}')
-		return buf.Bytes(), err
+		return json.Marshal(struct {
+			Kind     ShapeKind `json:"kind"`
+			Children Shape     `json:"children"`
+		}{s.Kind, s.ArrayShape.Children})
 	case VariedKind:
-		buf := bytes.NewBufferString(`{"kind": "varied", "varied": [`)
-		first := true
-		for _, s := range s.VariedShape.Children {
-			if !first {
-				err := buf.WriteByte(',')
-				if err != nil {
-					return nil, err
-				}
-			}
-			first = false
-
-			bs, err := s.MarshalJSON()
-			if err != nil {
-				return nil, err
-			}
-
-			_, err = buf.Write(bs)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		_, err := buf.WriteString("]}")
-		return buf.Bytes(), err
+		return json.Marshal(struct {
+			Kind     ShapeKind `json:"kind"`
+			Children []Shape   `json:"children"`
+		}{s.Kind, s.VariedShape.Children})
 	}
 
 	return nil, fmt.Errorf("Bad kind: %s", s.Kind)
@@ -238,8 +175,97 @@ const (
 	BigintScalar  ScalarName = "bigint"
 )
 
+// Narrows down a scalar's Name. Shapes stored before subtypes
+// existed don't have one, and neither do scalars with mixed subtypes.
+type ScalarSubtype string
+
+const (
+	IntegerSubtype  ScalarSubtype = "integer"
+	DecimalSubtype  ScalarSubtype = "decimal"
+	DateSubtype     ScalarSubtype = "date"
+	DatetimeSubtype ScalarSubtype = "datetime"
+	UUIDSubtype     ScalarSubtype = "uuid"
+)
+
 type ScalarShape struct {
-	Name ScalarName `json:"name"`
+	Name    ScalarName    `json:"name"`
+	Subtype ScalarSubtype `json:"subtype,omitempty"`
+}
+
+// Bigger integers don't make it through a float64 intact
+const maxSafeInteger = 1 << 53
+
+func numberShape(f float64) Shape {
+	subtype := DecimalSubtype
+	if f == math.Trunc(f) && math.Abs(f) <= maxSafeInteger {
+		subtype = IntegerSubtype
+	}
+
+	return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: subtype}}
+}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Fractional seconds are allowed after the seconds in all of these
+var datetimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+}
+
+// Datetimes without a timezone are treated as UTC.
+func parseDatetime(s string) (time.Time, bool) {
+	// Cheap check before trying every layout
+	if len(s) < 19 || s[4] != '-' || (s[10] != 'T' && s[10] != ' ') {
+		return time.Time{}, false
+	}
+
+	for _, layout := range datetimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func stringShape(s string) Shape {
+	subtype := ScalarSubtype("")
+	switch len(s) {
+	case 10:
+		if _, err := time.Parse("2006-01-02", s); err == nil {
+			subtype = DateSubtype
+		}
+	case 36:
+		if uuidRe.MatchString(s) {
+			subtype = UUIDSubtype
+		}
+	}
+
+	if _, ok := parseDatetime(s); ok {
+		subtype = DatetimeSubtype
+	}
+
+	return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar, Subtype: subtype}}
+}
+
+// Scalars with different subtypes of the same name are still the
+// same kind of scalar.
+func mergeSubtypes(a, b ScalarSubtype) ScalarSubtype {
+	if a == b {
+		return a
+	}
+
+	if (a == IntegerSubtype && b == DecimalSubtype) || (a == DecimalSubtype && b == IntegerSubtype) {
+		return DecimalSubtype
+	}
+
+	if (a == DateSubtype && b == DatetimeSubtype) || (a == DatetimeSubtype && b == DateSubtype) {
+		return DatetimeSubtype
+	}
+
+	return ""
 }
 
 type ObjectShape struct {
@@ -306,6 +332,19 @@ func addUniqueVaried(maybeVaried Shape, shape Shape) Shape {
 			return shape
 		}
 
+		// Integers and decimals don't make a number varied
+		if shape.Kind == ScalarKind {
+			for i, child := range varied.VariedShape.Children {
+				if child.Kind != ScalarKind || child.ScalarShape.Name != shape.ScalarShape.Name {
+					continue
+				}
+
+				children := append([]Shape{}, varied.VariedShape.Children...)
+				children[i] = shapeMerge(child, shape)
+				return Shape{Kind: VariedKind, VariedShape: &VariedShape{Children: children}}
+			}
+		}
+
 		found := false
 		walkVaried(varied, func(child Shape) bool {
 			// Don't try to use variedMerge here, it doesn't recurse correctly.
@@ -397,7 +436,16 @@ func shapeMerge(a Shape, b Shape) Shape {
 	}
 
 	if b.Kind == ArrayKind && a.Kind == ArrayKind {
-		return shapeMerge(a.ArrayShape.Children, b.ArrayShape.Children)
+		// Empty arrays don't say anything about the elements
+		if a.ArrayShape.Children.Kind == UnknownKind {
+			return b
+		}
+		if b.ArrayShape.Children.Kind == UnknownKind {
+			return a
+		}
+
+		children := shapeMerge(a.ArrayShape.Children, b.ArrayShape.Children)
+		return Shape{Kind: ArrayKind, ArrayShape: &ArrayShape{Children: children}}
 	}
 
 	// It's possible this case isn't even possible since 'varied' is
@@ -411,10 +459,15 @@ func shapeMerge(a Shape, b Shape) Shape {
 		Logln(`Missing type equality condition for %s merge: [%#v] and [%#v].`, b.Kind, a, b)
 	}
 
-	// Both scalars of same type; nothing to merge here
+	// Both scalars of same type; only subtypes to merge
 	if b.Kind == a.Kind && b.Kind == ScalarKind {
 		if a.ScalarShape.Name == b.ScalarShape.Name {
-			return a
+			subtype := mergeSubtypes(a.ScalarShape.Subtype, b.ScalarShape.Subtype)
+			if subtype == a.ScalarShape.Subtype {
+				return a
+			}
+
+			return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: a.ScalarShape.Name, Subtype: subtype}}
 		}
 	}
 
@@ -452,11 +505,17 @@ func GetShape(id string, value any, sampleSize int) Shape {
 		}
 
 		return Shape{Kind: ObjectKind, ObjectShape: &o}
-	case int, int64, float64, float32, int32, int16, int8, uint, uint64, uint32, uint8, uint16:
-		return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}}
+	case int, int64, int32, int16, int8, uint, uint64, uint32, uint8, uint16:
+		return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}}
+	case float64:
+		return numberShape(t)
+	case float32:
+		return numberShape(float64(t))
 	case bool:
 		return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: BooleanScalar}}
-	case string, []byte:
+	case string:
+		return stringShape(t)
+	case []byte:
 		return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}}
 	default:
 		if reflect.ValueOf(value).Kind() == reflect.Struct {
@@ -524,110 +583,235 @@ func shapeAtPath(s Shape, path string) (*Shape, error) {
 	return &s, nil
 }
 
-const DefaultShapeMaxBytesToRead = 1_000_000 * 10 // 10 MB
+// Array elements whose shapes are kept for merging. Elements after
+// that replace kept ones at random (reservoir sampling) so the sample
+// covers the whole array.
+var shapeReservoirSize = 10_000
+
+type sampledKey struct {
+	shape Shape
+	count int64
+	// Subtypes merged over every value, not just the sampled ones, so
+	// columns are wide enough for all of them
+	subtypes map[ScalarName]ScalarSubtype
+}
 
-// This has a real problem for data structures like:
-//
-//	{
-//	  "a": [... more than 1MB of data ...],
-//	  "b": [...whatever...]
-//	}
-//
-// any keys after the first one will never be seen
-func ShapeFromFile(file, id string, maxBytesToRead int, sampleSize int) (*Shape, error) {
-	fd, err := os.Open(file)
-	if err != nil {
-		return nil, edse(err)
+func (k *sampledKey) addScalar(shape Shape) {
+	if shape.Kind != ScalarKind || shape.ScalarShape.Name == NullScalar {
+		return
 	}
 
-	stat, err := fd.Stat()
-	if err != nil {
-		return nil, edse(err)
+	name := shape.ScalarShape.Name
+	if subtype, ok := k.subtypes[name]; ok {
+		k.subtypes[name] = mergeSubtypes(subtype, shape.ScalarShape.Subtype)
+		return
 	}
 
-	size := stat.Size()
+	k.subtypes[name] = shape.ScalarShape.Subtype
+}
 
-	if size < int64(maxBytesToRead) {
-		var value any
-		decoder := json.NewDecoder(fd)
-		err := decoder.Decode(&value)
-		if err != nil {
-			return nil, edse(err)
+// Swaps in the merged subtypes for the scalars in shape.
+func (k *sampledKey) withSubtypes(shape Shape) Shape {
+	switch shape.Kind {
+	case ScalarKind:
+		if subtype, ok := k.subtypes[shape.ScalarShape.Name]; ok {
+			return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: shape.ScalarShape.Name, Subtype: subtype}}
 		}
+	case VariedKind:
+		v := VariedShape{Children: make([]Shape, len(shape.VariedShape.Children))}
+		for i, child := range shape.VariedShape.Children {
+			v.Children[i] = k.withSubtypes(child)
+		}
+		return Shape{Kind: VariedKind, VariedShape: &v}
+	}
+
+	return shape
+}
+
+// Infers the shape of an array one element at a time with bounded
+// memory. Every object key is recorded even if no element with it
+// ends up in the reservoir.
+type shapeSampler struct {
+	id         string
+	sampleSize int
+	seen       int64
+	reservoir  []Shape
+	keys       map[string]*sampledKey
+}
+
+func newShapeSampler(id string, sampleSize int) *shapeSampler {
+	return &shapeSampler{id: id, sampleSize: sampleSize, keys: map[string]*sampledKey{}}
+}
 
-		s := GetShape(id, value, sampleSize)
-		return &s, nil
+// Returns where in the reservoir the next element goes, or -1 if it
+// isn't kept.
+func (s *shapeSampler) slot() int {
+	s.seen++
+	if len(s.reservoir) < shapeReservoirSize {
+		s.reservoir = append(s.reservoir, UnknownShape)
+		return len(s.reservoir) - 1
 	}
 
-	done := false
-	var f []byte
-	var incomplete []byte
-	inString := false
+	if j := rand.Int63n(s.seen); j < int64(len(s.reservoir)) {
+		return int(j)
+	}
 
-	for !done {
-		buf := make([]byte, 1024)
-		bytesRead, readErr := fd.Read(buf)
+	return -1
+}
 
-		read := buf[:bytesRead]
-	outer:
-		for i, c := range read {
-			if c != '"' && inString {
-				continue
-			}
+func (s *shapeSampler) addKey(key string, shape func() Shape) *sampledKey {
+	if k, ok := s.keys[key]; ok {
+		k.count++
+		return k
+	}
 
-			switch c {
-			case '"':
-				var previous byte = ' '
-				if i > 0 {
-					previous = read[i-1]
-				} else if len(f) > 0 {
-					previous = f[len(f)-1]
-				}
+	k := &sampledKey{shape: shape(), count: 1, subtypes: map[ScalarName]ScalarSubtype{}}
+	s.keys[key] = k
+	return k
+}
 
-				isEscaped := previous == '\\'
-				if !isEscaped {
-					inString = !inString
-				}
-			case '{', '[':
-				incomplete = append(incomplete, c)
-			case ']', '}':
-				if len(f)+len(read) >= maxBytesToRead {
-					// Need to not count additional openings after this
-					done = true
-					read = read[:i]
-					break outer
-				}
+// Shapes are only worked out for values that are kept, that have
+// new keys or that are scalars.
+func (s *shapeSampler) addValue(v any) {
+	if i := s.slot(); i >= 0 {
+		s.reservoir[i] = GetShape(s.id, v, s.sampleSize)
+	}
+
+	if m, ok := v.(map[string]any); ok {
+		for key, child := range m {
+			k := s.addKey(key, func() Shape {
+				return GetShape(s.id, child, s.sampleSize)
+			})
 
-				// Otherwise, pop it
-				incomplete = incomplete[:len(incomplete)-1]
+			switch child.(type) {
+			case map[string]any, []any, nil:
+			default:
+				k.addScalar(GetShape(s.id, child, s.sampleSize))
 			}
 		}
+	}
+}
 
-		f = append(f, read...)
+func (s *shapeSampler) addShape(shape Shape) {
+	if i := s.slot(); i >= 0 {
+		s.reservoir[i] = shape
+	}
 
-		if readErr == io.EOF {
-			break
+	if shape.Kind == ObjectKind {
+		for key, child := range shape.ObjectShape.Children {
+			c := child
+			s.addKey(key, func() Shape { return c }).addScalar(c)
+		}
+	}
+}
+
+func (s *shapeSampler) shape() Shape {
+	if len(s.reservoir) == 0 {
+		return Shape{Kind: ArrayKind, ArrayShape: &ArrayShape{Children: UnknownShape}}
+	}
+
+	merged := s.reservoir[0]
+	for _, shape := range s.reservoir[1:] {
+		if reflect.DeepEqual(merged, shape) {
+			continue
 		}
-		if readErr != nil {
-			return nil, edse(readErr)
+
+		merged = shapeMerge(merged, shape)
+	}
+
+	if merged.Kind == ObjectKind {
+		o := ObjectShape{Children: map[string]Shape{}}
+		for key, child := range merged.ObjectShape.Children {
+			o.Children[key] = child
 		}
+
+		for key, k := range s.keys {
+			child, ok := o.Children[key]
+			if !ok {
+				child = k.shape
+			}
+			child = k.withSubtypes(child)
+
+			// Missing from some elements
+			if k.count < s.seen && !reflect.DeepEqual(child, NullShape) {
+				child = addUniqueVaried(child, NullShape)
+			}
+
+			o.Children[key] = child
+		}
+
+		merged = Shape{Kind: ObjectKind, ObjectShape: &o}
+	}
+
+	return Shape{Kind: ArrayKind, ArrayShape: &ArrayShape{Children: merged}}
+}
+
+// Works out the shape of the next value in dec from its tokens so no
+// value is ever held in memory whole.
+func shapeFromTokens(dec *json.Decoder, id string, sampleSize int) (Shape, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return UnknownShape, err
 	}
 
-	for i := len(incomplete) - 1; i >= 0; i-- {
-		last := incomplete[i]
-		if last == '{' {
-			f = append(f, '}')
-		} else {
-			f = append(f, ']')
+	switch v := t.(type) {
+	case json.Delim:
+		if v == '{' {
+			o := ObjectShape{Children: map[string]Shape{}}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return UnknownShape, err
+				}
+
+				o.Children[key.(string)], err = shapeFromTokens(dec, id, sampleSize)
+				if err != nil {
+					return UnknownShape, err
+				}
+			}
+
+			// Closing }
+			_, err = dec.Token()
+			return Shape{Kind: ObjectKind, ObjectShape: &o}, err
 		}
+
+		sampler := newShapeSampler(id, sampleSize)
+		for dec.More() {
+			child, err := shapeFromTokens(dec, id, sampleSize)
+			if err != nil {
+				return UnknownShape, err
+			}
+
+			sampler.addShape(child)
+		}
+
+		// Closing ]
+		_, err = dec.Token()
+		return sampler.shape(), err
+	case float64:
+		return numberShape(v), nil
+	case bool:
+		return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: BooleanScalar}}, nil
+	case string:
+		return stringShape(v), nil
+	}
+
+	// Same as GetShape for null
+	return UnknownShape, nil
+}
+
+// Reads the whole file in one streaming pass.
+func ShapeFromFile(file, id string, sampleSize int) (*Shape, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, edse(err)
 	}
+	defer fd.Close()
 
-	var value any
-	err = json.Unmarshal(f, &value)
+	s, err := shapeFromTokens(json.NewDecoder(newBufferedReader(fd)), id, sampleSize)
 	if err != nil {
 		return nil, edse(err)
 	}
 
-	s := GetShape(id, value, sampleSize)
 	return &s, nil
 }
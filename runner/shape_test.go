@@ -21,7 +21,7 @@ func TestGetShape(t *testing.T) {
 				Kind: ObjectKind,
 				ObjectShape: &ObjectShape{
 					Children: map[string]Shape{
-						"a": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+						"a": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 					},
 				},
 			},
@@ -39,7 +39,7 @@ func TestGetShape(t *testing.T) {
 									Kind: VariedKind,
 									VariedShape: &VariedShape{
 										Children: []Shape{
-											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}},
 										},
 									},
@@ -63,7 +63,7 @@ func TestGetShape(t *testing.T) {
 									Kind: VariedKind,
 									VariedShape: &VariedShape{
 										Children: []Shape{
-											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 											NullShape,
 										},
 									},
@@ -72,7 +72,7 @@ func TestGetShape(t *testing.T) {
 									Kind: VariedKind,
 									VariedShape: &VariedShape{
 										Children: []Shape{
-											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 											NullShape,
 										},
 									},
@@ -97,7 +97,7 @@ func TestGetShape(t *testing.T) {
 									VariedShape: &VariedShape{
 										Children: []Shape{
 											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}},
-											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 										},
 									},
 								},
@@ -121,12 +121,12 @@ func TestGetShape(t *testing.T) {
 									VariedShape: &VariedShape{
 										Children: []Shape{
 											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}},
-											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+											{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 										},
 									},
 								},
 								"b": {
-									Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar},
+									Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype},
 								},
 							},
 						},
@@ -147,7 +147,7 @@ func TestGetShape(t *testing.T) {
 									Kind: ObjectKind,
 									ObjectShape: &ObjectShape{
 										Children: map[string]Shape{
-											"b": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+											"b": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 										},
 									},
 								},
@@ -171,7 +171,7 @@ func TestGetShape(t *testing.T) {
 									Kind: ObjectKind,
 									ObjectShape: &ObjectShape{
 										Children: map[string]Shape{
-											"b": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+											"b": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 										},
 									},
 								},
@@ -197,15 +197,20 @@ func TestGetShape(t *testing.T) {
 }
 
 func TestShapeFromFile(t *testing.T) {
+	nullable := func(name ScalarName, subtype ScalarSubtype) Shape {
+		return Shape{Kind: VariedKind, VariedShape: &VariedShape{Children: []Shape{
+			{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: name, Subtype: subtype}},
+			NullShape,
+		}}}
+	}
+
 	tests := []struct {
-		json        string
-		bytesToRead int
-		expShape    *Shape
-		expErr      error
+		json     string
+		expShape *Shape
+		expErr   error
 	}{
 		{
 			`[{"a": 1, "b ] ": 2}, {"a": 2, "b ] ": 3}]`,
-			200,
 			&Shape{
 				Kind: ArrayKind,
 				ArrayShape: &ArrayShape{
@@ -213,8 +218,8 @@ func TestShapeFromFile(t *testing.T) {
 						Kind: ObjectKind,
 						ObjectShape: &ObjectShape{
 							Children: map[string]Shape{
-								"a":    {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
-								"b ] ": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+								"a":    {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
+								"b ] ": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 							},
 						},
 					},
@@ -224,7 +229,6 @@ func TestShapeFromFile(t *testing.T) {
 		},
 		{
 			`[{"a": 1, "b \" ": 2}, {"a": 2, "b \" ": 3}]`,
-			200,
 			&Shape{
 				Kind: ArrayKind,
 				ArrayShape: &ArrayShape{
@@ -232,8 +236,8 @@ func TestShapeFromFile(t *testing.T) {
 						Kind: ObjectKind,
 						ObjectShape: &ObjectShape{
 							Children: map[string]Shape{
-								"a":     {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
-								"b \" ": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+								"a":     {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
+								"b \" ": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 							},
 						},
 					},
@@ -243,7 +247,6 @@ func TestShapeFromFile(t *testing.T) {
 		},
 		{
 			`[{"a": 1, "b": 2}, {"a": 2, "b": 3}]`,
-			200,
 			&Shape{
 				Kind: ArrayKind,
 				ArrayShape: &ArrayShape{
@@ -251,8 +254,8 @@ func TestShapeFromFile(t *testing.T) {
 						Kind: ObjectKind,
 						ObjectShape: &ObjectShape{
 							Children: map[string]Shape{
-								"a": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
-								"b": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+								"a": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
+								"b": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 							},
 						},
 					},
@@ -262,7 +265,6 @@ func TestShapeFromFile(t *testing.T) {
 		},
 		{
 			`[{"a": 1, "b": "y"}, {"a": 2, "b": "x"}]`,
-			200,
 			&Shape{
 				Kind: ArrayKind,
 				ArrayShape: &ArrayShape{
@@ -270,7 +272,7 @@ func TestShapeFromFile(t *testing.T) {
 						Kind: ObjectKind,
 						ObjectShape: &ObjectShape{
 							Children: map[string]Shape{
-								"a": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
+								"a": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}},
 								"b": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}},
 							},
 						},
@@ -280,8 +282,8 @@ func TestShapeFromFile(t *testing.T) {
 			nil,
 		},
 		{
+			// Keys after the first element are seen too
 			`[{"a": 1, "b": "y"}, {"c": 2, "d": "x"}]`,
-			8,
 			&Shape{
 				Kind: ArrayKind,
 				ArrayShape: &ArrayShape{
@@ -289,8 +291,10 @@ func TestShapeFromFile(t *testing.T) {
 						Kind: ObjectKind,
 						ObjectShape: &ObjectShape{
 							Children: map[string]Shape{
-								"a": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar}},
-								"b": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}},
+								"a": nullable(NumberScalar, IntegerSubtype),
+								"b": nullable(StringScalar, ""),
+								"c": nullable(NumberScalar, IntegerSubtype),
+								"d": nullable(StringScalar, ""),
 							},
 						},
 					},
@@ -310,7 +314,7 @@ func TestShapeFromFile(t *testing.T) {
 			_, err = tmp.WriteString(test.json)
 			assert.Nil(t, err)
 
-			s, err := ShapeFromFile(tmp.Name(), "x", test.bytesToRead, 50)
+			s, err := ShapeFromFile(tmp.Name(), "x", 50)
 			assert.Equal(t, test.expErr, err)
 			assert.Equal(t, test.expShape, s)
 		}()
@@ -411,11 +415,15 @@ func TestShape_MarshalJSON(t *testing.T) {
 	}{
 		{
 			`[1, "a"]`,
-			`{"kind": "array", "array": {"kind": "varied", "varied": [{"kind": "scalar", "scalar": "number"},{"kind": "scalar", "scalar": "string"}]}}`,
+			`{"kind":"array","children":{"kind":"varied","children":[{"kind":"scalar","name":"number","subtype":"integer"},{"kind":"scalar","name":"string"}]}}`,
 		},
 		{
 			`[{"a": 1}, {"a": 2}]`,
-			`{"kind": "array", "array": {"kind": "object", "object": {"a": {"kind": "scalar", "scalar": "number"}}}}`,
+			`{"kind":"array","children":{"kind":"object","children":{"a":{"kind":"scalar","name":"number","subtype":"integer"}}}}`,
+		},
+		{
+			`[{"a": {"b": [{"c": "2022-01-01", "d": null}]}, "e": []}]`,
+			`{"kind":"array","children":{"kind":"object","children":{"a":{"kind":"object","children":{"b":{"kind":"array","children":{"kind":"object","children":{"c":{"kind":"scalar","name":"string","subtype":"date"},"d":{"kind":"unknown"}}}}}},"e":{"kind":"array","children":{"kind":"unknown"}}}}}`,
 		},
 	}
 	for _, test := range tests {
@@ -427,6 +435,32 @@ func TestShape_MarshalJSON(t *testing.T) {
 		bs, err := s.MarshalJSON()
 		assert.Nil(t, err)
 		assert.Equal(t, test.shapeJson, string(bs))
+
+		// Reads back the same, including inside panel results
+		var read Shape
+		assert.Nil(t, jsonUnmarshal(bs, &read))
+		assert.Equal(t, s, read)
+
+		bs, err = jsonMarshal(&PanelResult{Shape: s})
+		assert.Nil(t, err)
+		var result PanelResult
+		assert.Nil(t, jsonUnmarshal(bs, &result))
+		assert.Equal(t, s, result.Shape)
+	}
+}
+
+func TestShape_MarshalJSON_subtypes(t *testing.T) {
+	for _, subtype := range []ScalarSubtype{IntegerSubtype, DecimalSubtype, DateSubtype, DatetimeSubtype, UUIDSubtype} {
+		s := Shape{Kind: ArrayKind, ArrayShape: &ArrayShape{Children: Shape{Kind: ObjectKind, ObjectShape: &ObjectShape{Children: map[string]Shape{
+			"a": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar, Subtype: subtype}},
+		}}}}}
+
+		bs, err := jsonMarshal(&PanelResult{Shape: s})
+		assert.Nil(t, err)
+
+		var result PanelResult
+		assert.Nil(t, jsonUnmarshal(bs, &result))
+		assert.Equal(t, s, result.Shape, subtype)
 	}
 }
 
@@ -473,3 +507,139 @@ func TestShape_Pretty(t *testing.T) {
 		assert.Equal(t, test.pretty, p)
 	}
 }
+
+func Test_shapeSampler(t *testing.T) {
+	defer func(n int) { shapeReservoirSize = n }(shapeReservoirSize)
+	shapeReservoirSize = 10
+
+	s := newShapeSampler("x", 50)
+	for i := 0; i < 1_000; i++ {
+		row := map[string]any{"a": i}
+		if i == 999 {
+			row["late"] = "x"
+		}
+		s.addValue(row)
+	}
+
+	assert.Equal(t, 10, len(s.reservoir))
+	shape := s.shape()
+	children := shape.ArrayShape.Children.ObjectShape.Children
+	assert.Equal(t, Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}}, children["a"])
+	assert.Equal(t, Shape{Kind: VariedKind, VariedShape: &VariedShape{Children: []Shape{
+		{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}},
+		NullShape,
+	}}}, children["late"])
+}
+
+func Test_shapeSampler_subtypes(t *testing.T) {
+	defer func(n int) { shapeReservoirSize = n }(shapeReservoirSize)
+	shapeReservoirSize = 10
+
+	s := newShapeSampler("x", 50)
+	for i := 0; i < 1_000; i++ {
+		row := map[string]any{"n": float64(i), "d": "2022-01-01"}
+		// Past the reservoir and very unlikely to replace anything
+		// in it
+		if i == 999 {
+			row["n"] = 1.5
+			row["d"] = "N/A"
+		}
+		s.addValue(row)
+	}
+
+	children := s.shape().ArrayShape.Children.ObjectShape.Children
+	assert.Equal(t, Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: NumberScalar, Subtype: DecimalSubtype}}, children["n"])
+	assert.Equal(t, Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}}, children["d"])
+}
+
+func TestShapeFromFile_wholeFile(t *testing.T) {
+	defer func(n int) { shapeReservoirSize = n }(shapeReservoirSize)
+	shapeReservoirSize = 10
+
+	tmp, err := os.CreateTemp("", "")
+	assert.Nil(t, err)
+	defer os.Remove(tmp.Name())
+
+	// A big array before the key that matters
+	tmp.WriteString(`{"a": [`)
+	for i := 0; i < 10_000; i++ {
+		if i > 0 {
+			tmp.WriteString(",")
+		}
+		tmp.WriteString(`{"i": 1}`)
+	}
+	tmp.WriteString(`], "b": true}`)
+	tmp.Close()
+
+	s, err := ShapeFromFile(tmp.Name(), "x", 50)
+	assert.Nil(t, err)
+	assert.Equal(t, ObjectKind, s.Kind)
+	assert.True(t, ShapeIsObjectArray(s.ObjectShape.Children["a"]))
+	assert.Equal(t, Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: BooleanScalar}}, s.ObjectShape.Children["b"])
+}
+
+func Test_shapeMerge_arrays(t *testing.T) {
+	object := Shape{Kind: ObjectKind, ObjectShape: &ObjectShape{Children: map[string]Shape{
+		"a": {Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}},
+	}}}
+	str := Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: StringScalar}}
+	array := func(children Shape) Shape {
+		return Shape{Kind: ArrayKind, ArrayShape: &ArrayShape{Children: children}}
+	}
+
+	// Still arrays after merging, empty ones don't change anything
+	assert.Equal(t, array(object), shapeMerge(array(object), array(UnknownShape)))
+	assert.Equal(t, array(object), shapeMerge(array(UnknownShape), array(object)))
+	merged := shapeMerge(array(object), array(str))
+	assert.Equal(t, ArrayKind, merged.Kind)
+	assert.Equal(t, VariedKind, merged.ArrayShape.Children.Kind)
+}
+
+func TestGetShape_subtypes(t *testing.T) {
+	scalar := func(name ScalarName, subtype ScalarSubtype) Shape {
+		return Shape{Kind: ScalarKind, ScalarShape: &ScalarShape{Name: name, Subtype: subtype}}
+	}
+
+	tests := []struct {
+		value any
+		exp   Shape
+	}{
+		{float64(1), scalar(NumberScalar, IntegerSubtype)},
+		{int64(1), scalar(NumberScalar, IntegerSubtype)},
+		{1.5, scalar(NumberScalar, DecimalSubtype)},
+		{1e20, scalar(NumberScalar, DecimalSubtype)},
+		{"2022-01-05", scalar(StringScalar, DateSubtype)},
+		{"2022-13-05", scalar(StringScalar, "")},
+		{"2022-01-05T10:00:00Z", scalar(StringScalar, DatetimeSubtype)},
+		{"2022-01-05 10:00:00.123", scalar(StringScalar, DatetimeSubtype)},
+		{"2022-01-05T10:00:00+02:00", scalar(StringScalar, DatetimeSubtype)},
+		{"6ba7b810-9dad-11d1-80b4-00c04fd430c8", scalar(StringScalar, UUIDSubtype)},
+		{"hello", scalar(StringScalar, "")},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.exp, GetShape("", test.value, 50), test.value)
+	}
+
+	// Mixed subtypes of the same scalar don't make it varied
+	s := GetShape("", []any{
+		map[string]any{"a": 1.0, "b": "2022-01-05"},
+		map[string]any{"a": 1.5, "b": "2022-01-05T10:00:00Z"},
+		map[string]any{"a": nil, "b": "hello"},
+		map[string]any{"a": 2.0},
+	}, 50)
+	children := s.ArrayShape.Children.ObjectShape.Children
+	assert.Equal(t, Shape{Kind: VariedKind, VariedShape: &VariedShape{Children: []Shape{
+		scalar(NumberScalar, DecimalSubtype),
+		UnknownShape,
+	}}}, children["a"])
+	assert.Equal(t, Shape{Kind: VariedKind, VariedShape: &VariedShape{Children: []Shape{
+		scalar(StringScalar, ""),
+		NullShape,
+	}}}, children["b"])
+
+	// Older shapes without subtypes still read
+	var old Shape
+	assert.Nil(t, json.Unmarshal([]byte(`{"kind": "scalar", "name": "number"}`), &old))
+	assert.Equal(t, scalar(NumberScalar, ""), old)
+}
@@ -3,9 +3,11 @@ package runner
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 )
 
 var JSON_SQL_TYPE_MAP = map[string]string{
@@ -16,6 +18,50 @@ var JSON_SQL_TYPE_MAP = map[string]string{
 	"null":    "TEXT",
 }
 
+// Dialects that don't take the standard names
+var DIALECT_SQL_TYPE_MAPS = map[DatabaseConnectorInfoType]map[string]string{
+	SQLServerDatabase: {
+		"number":  "FLOAT",
+		"string":  "NVARCHAR(MAX)",
+		"boolean": "BIT",
+		"bigint":  "BIGINT",
+		"null":    "NVARCHAR(MAX)",
+	},
+	// Strings longer than 4000 bytes need CLOB but CLOBs can't
+	// be compared or joined on.
+	OracleDatabase: {
+		"number":  "BINARY_DOUBLE",
+		"string":  "VARCHAR2(4000)",
+		"boolean": "NUMBER(1)",
+		"bigint":  "NUMBER(38)",
+		"null":    "CLOB",
+	},
+	// Wrapped in Nullable() when the table is created
+	ClickHouseDatabase: {
+		"number":  "Float64",
+		"string":  "String",
+		"boolean": "Bool",
+		"bigint":  "Int64",
+		"null":    "String",
+	},
+	SnowflakeDatabase: {
+		"number":  "FLOAT",
+		"string":  "VARCHAR",
+		"boolean": "BOOLEAN",
+		"bigint":  "BIGINT",
+		"null":    "VARCHAR",
+	},
+}
+
+// Columns for nested arrays and mixed types get JSON.
+func sqlTextType(dialect DatabaseConnectorInfoType) string {
+	if m, ok := DIALECT_SQL_TYPE_MAPS[dialect]; ok {
+		return m["null"]
+	}
+
+	return "TEXT"
+}
+
 type quoteType struct {
 	identifier string
 	string     string
@@ -35,12 +81,210 @@ func quote(value string, quoteChar string) string {
 	return quoteChar + strings.ReplaceAll(value, quoteChar, quoteChar+quoteChar) + quoteChar
 }
 
+// How values are converted before they're inserted.
+type columnConversion string
+
+const (
+	noConversion       columnConversion = ""
+	integerConversion  columnConversion = "integer"
+	datetimeConversion columnConversion = "datetime"
+	dateConversion     columnConversion = "date"
+	booleanConversion  columnConversion = "boolean"
+)
+
 type column struct {
-	name string
-	kind string
+	name    string
+	kind    string
+	convert columnConversion
+	// What the column's kind was picked for, values that don't
+	// fit it make the column fall back to a wider kind
+	subtype ScalarSubtype
+}
+
+// Subtypes get the closest column type the dialect has. Dialects
+// that can't read ISO datetimes as-is get them parsed first.
+func sqlColumnType(s ScalarShape, dialect DatabaseConnectorInfoType) (string, columnConversion) {
+	switch s.Subtype {
+	case IntegerSubtype:
+		switch dialect {
+		case SQLiteDatabase:
+			return "INTEGER", integerConversion
+		case OracleDatabase:
+			return "NUMBER(19)", integerConversion
+		case ClickHouseDatabase:
+			return "Int64", integerConversion
+		}
+		return "BIGINT", integerConversion
+	case DecimalSubtype:
+		switch dialect {
+		case PostgresDatabase:
+			return "DOUBLE PRECISION", noConversion
+		case MySQLDatabase:
+			return "DOUBLE", noConversion
+		case SQLServerDatabase, SnowflakeDatabase:
+			return "FLOAT", noConversion
+		case OracleDatabase:
+			return "BINARY_DOUBLE", noConversion
+		case ClickHouseDatabase:
+			return "Float64", noConversion
+		}
+		return "REAL", noConversion
+	case DatetimeSubtype:
+		switch dialect {
+		case PostgresDatabase:
+			return "TIMESTAMPTZ", datetimeConversion
+		case MySQLDatabase:
+			return "DATETIME(6)", datetimeConversion
+		case SQLServerDatabase:
+			return "DATETIMEOFFSET", datetimeConversion
+		case OracleDatabase:
+			return "TIMESTAMP WITH TIME ZONE", datetimeConversion
+		case ClickHouseDatabase:
+			return "DateTime64(6, 'UTC')", datetimeConversion
+		case SnowflakeDatabase:
+			return "TIMESTAMP_TZ", noConversion
+		}
+		return "TIMESTAMP", noConversion
+	case DateSubtype:
+		switch dialect {
+		// Neither reads ISO date strings by default
+		case OracleDatabase:
+			return "DATE", dateConversion
+		case ClickHouseDatabase:
+			return "Date32", dateConversion
+		}
+		return "DATE", noConversion
+	}
+
+	if m, ok := DIALECT_SQL_TYPE_MAPS[dialect]; ok {
+		if dialect == OracleDatabase && s.Name == BooleanScalar {
+			return m[string(s.Name)], booleanConversion
+		}
+
+		return m[string(s.Name)], noConversion
+	}
+
+	return JSON_SQL_TYPE_MAP[string(s.Name)], noConversion
+}
+
+// Whether a value can go in a column picked for the subtype.
+func columnValueFits(v any, subtype ScalarSubtype) bool {
+	switch subtype {
+	case IntegerSubtype:
+		switch n := v.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return n == math.Trunc(n) && math.Abs(n) <= maxSafeInteger
+		}
+		return false
+	case DecimalSubtype:
+		switch v.(type) {
+		case int, int64, float64:
+			return true
+		}
+		return false
+	case DatetimeSubtype:
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		_, ok = parseDatetime(s)
+		return ok
+	case DateSubtype:
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	}
+
+	return true
+}
+
+// The next widest column for a value that didn't fit: numbers that
+// aren't integers go in a floating point column, everything else in
+// a text column.
+func widenColumn(c column, v any, dialect DatabaseConnectorInfoType) column {
+	if c.subtype == IntegerSubtype && columnValueFits(v, DecimalSubtype) {
+		c.kind, c.convert = sqlColumnType(ScalarShape{Name: NumberScalar, Subtype: DecimalSubtype}, dialect)
+		c.subtype = DecimalSubtype
+		return c
+	}
+
+	c.kind = sqlTextType(dialect)
+	c.convert = noConversion
+	c.subtype = ""
+	return c
 }
 
-func sqlColumnsAndTypesFromShape(rowShape ObjectShape) []column {
+// Returned while importing when a value doesn't fit its column, the
+// table is created again with the column widened.
+type columnMisfitError struct {
+	column string
+	value  any
+}
+
+func (e *columnMisfitError) Error() string {
+	return fmt.Sprintf("Value %v doesn't fit column %s", e.value, e.column)
+}
+
+// Values are checked with columnValueFits first, nil is only
+// returned for values that slip past that.
+func convertColumnValue(v any, convert columnConversion) any {
+	switch convert {
+	case integerConversion:
+		f, ok := v.(float64)
+		if !ok {
+			return v
+		}
+
+		if f != math.Trunc(f) || math.Abs(f) > maxSafeInteger {
+			return nil
+		}
+
+		return int64(f)
+	case datetimeConversion:
+		s, ok := v.(string)
+		if !ok {
+			return v
+		}
+
+		t, ok := parseDatetime(s)
+		if !ok {
+			return nil
+		}
+
+		return t
+	case dateConversion:
+		s, ok := v.(string)
+		if !ok {
+			return v
+		}
+
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil
+		}
+
+		return t
+	case booleanConversion:
+		b, ok := v.(bool)
+		if !ok {
+			return v
+		}
+
+		if b {
+			return int64(1)
+		}
+		return int64(0)
+	}
+
+	return v
+}
+
+func sqlColumnsAndTypesFromShape(rowShape ObjectShape, dialect DatabaseConnectorInfoType) []column {
 	var columns []column
 
 	var keys []string
@@ -53,11 +297,14 @@ func sqlColumnsAndTypesFromShape(rowShape ObjectShape) []column {
 	for _, key := range keys {
 		childShape := rowShape.Children[key]
 		columnType := ""
+		convert := noConversion
 
 		// Look for simple type: X
+		subtype := ScalarSubtype("")
 		if childShape.Kind == ScalarKind {
 			if childShape.ScalarShape.Name != NullScalar {
-				columnType = JSON_SQL_TYPE_MAP[string(childShape.ScalarShape.Name)]
+				columnType, convert = sqlColumnType(*childShape.ScalarShape, dialect)
+				subtype = childShape.ScalarShape.Subtype
 			}
 		}
 
@@ -74,29 +321,40 @@ func sqlColumnsAndTypesFromShape(rowShape ObjectShape) []column {
 
 				if nullChild.Kind == ScalarKind && nullChild.ScalarShape.Name == NullScalar &&
 					otherChild.Kind == ScalarKind && otherChild.ScalarShape.Name != NullScalar {
-					columnType = JSON_SQL_TYPE_MAP[string(otherChild.ScalarShape.Name)]
+					columnType, convert = sqlColumnType(*otherChild.ScalarShape, dialect)
+					subtype = otherChild.ScalarShape.Subtype
 				}
 			}
 		}
 
 		if columnType == "" {
 			// Otherwise just fall back to being TEXT
-			columnType = "TEXT"
+			columnType = sqlTextType(dialect)
+		}
+
+		// Only subtypes with their own kind of column need
+		// checking
+		if columnType == sqlTextType(dialect) {
+			subtype = ""
 		}
 
 		if childShape.Kind == ObjectKind {
-			childColumns := sqlColumnsAndTypesFromShape(*childShape.ObjectShape)
+			childColumns := sqlColumnsAndTypesFromShape(*childShape.ObjectShape, dialect)
 			for _, c := range childColumns {
 				columns = append(columns, column{
-					name: strings.ReplaceAll(key, ".", "\\.") + "." + c.name,
-					kind: c.kind,
+					name:    strings.ReplaceAll(key, ".", "\\.") + "." + c.name,
+					kind:    c.kind,
+					convert: c.convert,
+					subtype: c.subtype,
 				})
 			}
 		} else {
 			// Ignore nested arrays
 			columns = append(columns, column{
-				name: key,
-				kind: columnType,
+				name:    key,
+				kind:    columnType,
+				convert: convert,
+				subtype: subtype,
 			})
 		}
 	}
@@ -108,9 +366,77 @@ type panelToImport struct {
 	id        string
 	columns   []column
 	tableName string
+	// Only when unnesting, a table for each nested array of objects
+	children []panelToImport
+	// Where a child table's array is in each parent row
+	arrayPath string
 }
 
-var dmGetPanelRe = regexp.MustCompile(`(DM_getPanel\((?P<number>[0-9]+)(((,\s*(?P<numbersinglepath>"(?:[^"\\]|\\.)*\"))?)|(,\s*(?P<numberdoublepath>'(?:[^'\\]|\\.)*\'))?)\))|(DM_getPanel\((?P<singlequote>'(?:[^'\\]|\\.)*\'(,\s*(?P<singlepath>'(?:[^'\\]|\\.)*\'))?)\))|(DM_getPanel\((?P<doublequote>"(?:[^"\\]|\\.)*\"(,\s*(?P<doublepath>"(?:[^"\\]|\\.)*\"))?)\))`)
+// Unnested tables are joined on these
+const (
+	unnestRowIdColumn    = "__row_id"
+	unnestParentIdColumn = "__parent_row_id"
+)
+
+func rowKeyColumn(name string, dialect DatabaseConnectorInfoType) column {
+	kind, convert := sqlColumnType(ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}, dialect)
+	return column{name: name, kind: kind, convert: convert, subtype: IntegerSubtype}
+}
+
+// Arrays that aren't always there are null | array.
+func nonNullShape(s Shape) Shape {
+	if s.Kind != VariedKind || len(s.VariedShape.Children) != 2 {
+		return s
+	}
+
+	for i, c := range s.VariedShape.Children {
+		if c.Kind == ScalarKind && c.ScalarShape.Name == NullScalar {
+			return s.VariedShape.Children[1-i]
+		}
+	}
+
+	return s
+}
+
+// Child tables for every array of objects in a row, including in
+// nested objects. Names are the parent table's name and the keys to
+// the array joined with __.
+func childTablesFromShape(id, tableName string, rowShape ObjectShape, dialect DatabaseConnectorInfoType, pathPrefix, namePrefix string) []panelToImport {
+	var keys []string
+	for key := range rowShape.Children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var children []panelToImport
+	for _, key := range keys {
+		childShape := nonNullShape(rowShape.Children[key])
+		path := pathPrefix + strings.ReplaceAll(key, ".", "\\.")
+
+		if childShape.Kind == ObjectKind {
+			children = append(children, childTablesFromShape(id, tableName, *childShape.ObjectShape, dialect, path+".", namePrefix+key+"__")...)
+			continue
+		}
+
+		if !ShapeIsObjectArray(childShape) {
+			continue
+		}
+
+		elementShape := *childShape.ArrayShape.Children.ObjectShape
+		childTableName := tableName + "__" + namePrefix + key
+		children = append(children, panelToImport{
+			id:        id,
+			columns:   sqlColumnsAndTypesFromShape(elementShape, dialect),
+			tableName: childTableName,
+			children:  childTablesFromShape(id, childTableName, elementShape, dialect, "", ""),
+			arrayPath: path,
+		})
+	}
+
+	return children
+}
+
+var dmGetPanelRe = regexp.MustCompile(`(DM_getPanel\((?P<number>[0-9]+)(((,\s*(?P<numbersinglepath>"(?:[^"\\]|\\.)*\"))?)|(,\s*(?P<numberdoublepath>'(?:[^'\\]|\\.)*\'))?)\))|(DM_getPanel\((?P<singlequote>'(?:[^'\\]|\\.)*\')(,\s*(?P<singlepath>'(?:[^'\\]|\\.)*\'))?\))|(DM_getPanel\((?P<doublequote>"(?:[^"\\]|\\.)*\")(,\s*(?P<doublepath>"(?:[^"\\]|\\.)*\"))?\))`)
 
 func transformDM_getPanelCalls(
 	query string,
@@ -118,7 +444,10 @@ func transformDM_getPanelCalls(
 	idMap map[string]string,
 	getPanelCallsAllowed bool,
 	qt quoteType,
+	dialect DatabaseConnectorInfoType,
 	cachePresent bool,
+	// Import nested arrays of objects as their own tables
+	unnest bool,
 ) ([]panelToImport, string, string, error) {
 	var panelsToImport []panelToImport
 
@@ -154,6 +483,10 @@ func transformDM_getPanelCalls(
 
 		id := idMap[nameOrIndex]
 		tableName := "t_" + nameOrIndex
+		if dialect == SQLServerDatabase {
+			// Makes it a temporary table
+			tableName = "#" + tableName
+		}
 		for _, p := range panelsToImport {
 			if p.id == id {
 				// Don't import the same panel twice.
@@ -189,11 +522,16 @@ func transformDM_getPanelCalls(
 		}
 
 		rowShape := sp.ArrayShape.Children
-		columns := sqlColumnsAndTypesFromShape(*rowShape.ObjectShape)
+		columns := sqlColumnsAndTypesFromShape(*rowShape.ObjectShape, dialect)
+		var children []panelToImport
+		if unnest {
+			children = childTablesFromShape(id, tableName, *rowShape.ObjectShape, dialect, "", "")
+		}
 		panelsToImport = append(panelsToImport, panelToImport{
 			id:        id,
 			columns:   columns,
 			tableName: tableName,
+			children:  children,
 		})
 
 		return quote(tableName, qt.identifier)
@@ -250,47 +588,87 @@ func GetObjectAtPath(obj map[string]any, path string) any {
 	return next
 }
 
-func postgresMangleInsert(stmt string) string {
-	r := regexp.MustCompile(`\?`)
+func numberPlaceholders(stmt, placeholder, prefix string) string {
+	r := regexp.MustCompile(regexp.QuoteMeta(placeholder))
 	counter := 0
 	return r.ReplaceAllStringFunc(stmt, func(m string) string {
 		counter += 1
-		return fmt.Sprintf("$%d", counter)
+		return fmt.Sprintf("%s%d", prefix, counter)
 	})
 }
 
+func numberedMangleInsert(stmt, prefix string) string {
+	return numberPlaceholders(stmt, "?", prefix)
+}
+
+func postgresMangleInsert(stmt string) string {
+	return numberedMangleInsert(stmt, "$")
+}
+
+func sqlServerMangleInsert(stmt string) string {
+	return numberedMangleInsert(stmt, "@p")
+}
+
+func oracleMangleInsert(stmt string) string {
+	return numberedMangleInsert(stmt, ":")
+}
+
 func defaultMangleInsert(stmt string) string {
 	return stmt
 }
 
-func chunk(c chan map[string]any, size int) chan []map[string]any {
-	var chunk []map[string]any
-
-	out := make(chan []map[string]any)
+// Stands in for DM_param values until the connector's placeholder
+// style is known. Can't otherwise show up in a query.
+const macroParamMarker = "\x00DM_param\x00"
+
+// Connectors that can bind DM_param values
+var macroParamsAllowed = map[DatabaseConnectorInfoType]bool{
+	PostgresDatabase:   true,
+	TimescaleDatabase:  true,
+	CockroachDatabase:  true,
+	CrateDatabase:      true,
+	YugabyteDatabase:   true,
+	QuestDatabase:      true,
+	MySQLDatabase:      true,
+	SQLiteDatabase:     true,
+	SQLServerDatabase:  true,
+	OracleDatabase:     true,
+	ClickHouseDatabase: true,
+	SnowflakeDatabase:  true,
+	CassandraDatabase:  true,
+	ScyllaDatabase:     true,
+	Neo4jDatabase:      true,
+}
 
-	go func() {
-		defer close(out)
+// Swaps DM_param markers for the connector's placeholders.
+func bindMacroParams(query string, dialect DatabaseConnectorInfoType) string {
+	switch dialect {
+	case PostgresDatabase, TimescaleDatabase, CockroachDatabase, CrateDatabase, YugabyteDatabase, QuestDatabase:
+		return numberPlaceholders(query, macroParamMarker, "$")
+	case SQLServerDatabase:
+		return numberPlaceholders(query, macroParamMarker, "@p")
+	case OracleDatabase:
+		return numberPlaceholders(query, macroParamMarker, ":")
+	case Neo4jDatabase:
+		return numberPlaceholders(query, macroParamMarker, "$p")
+	}
 
-	outer:
-		for {
-			next, ok := <-c
-			if !ok {
-				break outer
-			}
-			chunk = append(chunk, next)
+	return strings.ReplaceAll(query, macroParamMarker, "?")
+}
 
-			if len(chunk) == size {
-				out <- chunk
-				chunk = nil
-			}
-		}
+// Neo4j takes named parameters, matching the $p1, $p2, ...
+// placeholders from bindMacroParams.
+func neo4jMacroParams(params []any) map[string]any {
+	if len(params) == 0 {
+		return nil
+	}
 
-		if len(chunk) > 0 {
-			out <- chunk
-		}
-	}()
+	named := map[string]any{}
+	for i, p := range params {
+		named[fmt.Sprintf("p%d", i+1)] = p
+	}
 
-	return out
+	return named
 }
 
 func makePreparedStatement(tname string, nColumns, chunkSize int) string {
@@ -319,6 +697,110 @@ func makePreparedStatement(tname string, nColumns, chunkSize int) string {
 	return buf.String()
 }
 
+// Oracle has no multi-row VALUES.
+func makeOraclePreparedStatement(tname string, nColumns, chunkSize int) string {
+	var buf bytes.Buffer
+
+	buf.WriteString("INSERT ALL")
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", nColumns), ", ") + ")"
+	for i := 0; i < chunkSize; i++ {
+		buf.WriteString(" INTO ")
+		buf.WriteString(tname)
+		buf.WriteString(" VALUES ")
+		buf.WriteString(row)
+	}
+	buf.WriteString(" SELECT 1 FROM DUAL")
+
+	return buf.String()
+}
+
+func makeInsertStatement(dialect DatabaseConnectorInfoType, tname string, nColumns, chunkSize int) string {
+	if dialect == OracleDatabase {
+		return makeOraclePreparedStatement(tname, nColumns, chunkSize)
+	}
+
+	return makePreparedStatement(tname, nColumns, chunkSize)
+}
+
+// Bound parameters allowed per statement
+var sqlParameterLimits = map[DatabaseConnectorInfoType]int{
+	SQLServerDatabase: 2100,
+	SQLiteDatabase:    32766,
+	PostgresDatabase:  65535,
+	MySQLDatabase:     65535,
+	OracleDatabase:    65535,
+}
+
+// Rows per insert statement, as many as the parameter limit allows
+// up to a point where statements just get slow to parse.
+func insertChunkSize(dialect DatabaseConnectorInfoType, nColumns int) int {
+	maxRows := 500
+	if dialect == OracleDatabase {
+		// INSERT ALL gets slow fast
+		maxRows = 100
+	}
+
+	limit, ok := sqlParameterLimits[dialect]
+	if !ok || nColumns == 0 {
+		return maxRows
+	}
+
+	// Leave room for anything the driver adds
+	chunkSize := (limit - 100) / nColumns
+	if chunkSize > maxRows {
+		return maxRows
+	}
+	if chunkSize < 1 {
+		return 1
+	}
+
+	return chunkSize
+}
+
+// Marks the Oracle tables imports create so only those get dropped
+const oracleImportTableComment = "Created by DataStation for DM_getPanel"
+
+func createTableStatements(dialect DatabaseConnectorInfoType, tname string, ddlColumns []string, cacheEnabled bool) []string {
+	columns := strings.Join(ddlColumns, ", ")
+	switch dialect {
+	case SQLServerDatabase:
+		// The # in the name is what makes it temporary
+		return []string{fmt.Sprintf("CREATE TABLE %s (%s)", tname, columns)}
+	case OracleDatabase:
+		// Oracle doesn't take trailing semicolons.
+		if cacheEnabled {
+			return []string{fmt.Sprintf("CREATE TABLE %s (%s)", tname, columns)}
+		}
+
+		// Global temporary table definitions outlive the
+		// session, only the rows don't. So one left by an
+		// earlier import is dropped first, but only if it's
+		// marked as ours. Any other table with the name makes
+		// the create fail like it does in other dialects.
+		name := strings.ReplaceAll(strings.Trim(tname, `"`), `""`, `"`)
+		return []string{
+			fmt.Sprintf(`BEGIN FOR t IN (SELECT 1 FROM user_tables ut JOIN user_tab_comments c ON c.table_name = ut.table_name WHERE ut.table_name = %s AND ut.temporary = 'Y' AND c.comments = %s) LOOP EXECUTE IMMEDIATE %s; END LOOP; END;`,
+				quote(name, "'"),
+				quote(oracleImportTableComment, "'"),
+				quote("DROP TABLE "+tname, "'")),
+			fmt.Sprintf("CREATE GLOBAL TEMPORARY TABLE %s (%s) ON COMMIT PRESERVE ROWS", tname, columns),
+			fmt.Sprintf("COMMENT ON TABLE %s IS %s", tname, quote(oracleImportTableComment, "'")),
+		}
+	case ClickHouseDatabase:
+		tableType := "TEMPORARY TABLE"
+		if cacheEnabled {
+			tableType = "TABLE"
+		}
+		return []string{fmt.Sprintf("CREATE %s %s (%s) ENGINE = Memory", tableType, tname, columns)}
+	}
+
+	tableType := "TEMPORARY TABLE"
+	if cacheEnabled {
+		tableType = "TABLE"
+	}
+	return []string{fmt.Sprintf("CREATE %s %s (%s);", tableType, tname, columns)}
+}
+
 func IsScalar(v any) bool {
 	switch v.(type) {
 	case bool, byte, complex64, complex128, error, float32, float64,
@@ -330,91 +812,85 @@ func IsScalar(v any) bool {
 	}
 }
 
-func importPanel(
-	createTable func(string) error,
-	prepare func(string) (func([]any) error, func(), error),
-	makeQuery func(string) ([]map[string]any, error),
-	projectId string,
-	query string,
-	panel panelToImport,
-	qt quoteType,
-	panelResultLoader func(string, string) (chan map[string]any, error),
-	cacheEnabled bool,
-) error {
-	var ddlColumns []string
-	for _, c := range panel.columns {
-		ddlColumns = append(ddlColumns, quote(c.name, qt.identifier)+" "+c.kind)
-	}
+// Fast vendor-specific loading, rows come from next which fills a
+// row of values and returns false once there are no more.
+type bulkLoader func(table string, columns []string, next func([]any) (bool, error)) error
+
+// Converts a row into the values inserted for each column.
+func importRowValues(row map[string]any, columns []column, dialect DatabaseConnectorInfoType, values []any) error {
+	for j, col := range columns {
+		v := GetObjectAtPath(row, col.name)
+		// Non-scalars get JSON encoded. This can
+		// basically only be arrays because nested
+		// objects are supported.
+		if v != nil && !IsScalar(v) {
+			if col.kind == sqlTextType(dialect) {
+				// Strings so drivers don't send them
+				// as binary
+				bs, _ := jsonMarshal(v)
+				v = string(bs)
+			} else {
+				// SQL won't be happy to put a string in a REAL column for example
+				v = nil
+			}
+		}
+		if v != nil {
+			if !columnValueFits(v, col.subtype) {
+				return &columnMisfitError{column: col.name, value: v}
+			}
 
-	tableType := "TEMPORARY TABLE"
-	if cacheEnabled {
-		tableType = "TABLE"
-	}
-	tname := quote(panel.tableName, qt.identifier)
-	Logln("Creating table " + panel.tableName)
-	createQuery := fmt.Sprintf("CREATE %s %s (%s);",
-		tableType,
-		tname,
-		strings.Join(ddlColumns, ", "))
-	err := createTable(createQuery)
-	if err != nil {
-		return err
+			v = convertColumnValue(v, col.convert)
+		}
+		values[j] = v
 	}
 
-	c, err := panelResultLoader(projectId, panel.id)
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
+// Inserts chunkSize rows per statement.
+func insertChunks(
+	prepare func(string) (func([]any) error, func(), error),
+	dialect DatabaseConnectorInfoType,
+	tname string,
+	nColumns int,
+	chunkSize int,
+	next func([]any) (bool, error),
+) error {
 	// Preallocated this makes a 4s difference.
-	chunkSize := 10
-	toinsert := make([]any, chunkSize*len(ddlColumns))
+	toinsert := make([]any, chunkSize*nColumns)
 
-	chunks := chunk(c, chunkSize)
 newprepare:
 	for {
 		nWritten := 0
-		preparedStatement := makePreparedStatement(tname, len(ddlColumns), chunkSize)
+		preparedStatement := makeInsertStatement(dialect, tname, nColumns, chunkSize)
 		inserter, closer, err := prepare(preparedStatement)
 		if err != nil {
 			return err
 		}
 
-		nLeftovers := 0
-		for rows := range chunks {
-			nWritten += len(rows)
-
-			for i, row := range rows {
-				for j, col := range panel.columns {
-					v := GetObjectAtPath(row, col.name)
-					// Non-scalars get JSON
-					// encoded. This can basically
-					// only be arrays because
-					// nested objects are
-					// supported.
-					if v != nil && !IsScalar(v) {
-						if col.kind == "TEXT" {
-							v, _ = jsonMarshal(v)
-						} else {
-							// SQL won't be happy to put a string in a REAL column for example
-							v = nil
-						}
-					}
-					toinsert[i*len(ddlColumns)+j] = v
-				}
+		nBuffered := 0
+		for {
+			ok, err := next(toinsert[nBuffered*nColumns : (nBuffered+1)*nColumns])
+			if err != nil {
+				closer()
+				return err
 			}
-
-			// This likely only happens at the end where the last chunk won't always be chunkSize.
-			if len(rows) < chunkSize {
-				nLeftovers = len(rows)
+			if !ok {
 				break
 			}
 
+			nBuffered++
+			if nBuffered < chunkSize {
+				continue
+			}
+
 			err = inserter(toinsert)
 			if err != nil {
 				closer()
 				return err
 			}
+			nWritten += nBuffered
+			nBuffered = 0
 
 			// Start a new prepared statement every so often
 			if nWritten > 100_000 {
@@ -428,8 +904,8 @@ newprepare:
 		closer()
 
 		// Handle leftovers that are fewer than chunkSize
-		if nLeftovers > 0 {
-			stmt := makePreparedStatement(tname, len(ddlColumns), nLeftovers)
+		if nBuffered > 0 {
+			stmt := makeInsertStatement(dialect, tname, nColumns, nBuffered)
 			inserter, closer, err = prepare(stmt)
 			if err != nil {
 				return err
@@ -437,13 +913,261 @@ newprepare:
 
 			defer closer()
 			// Very important to slice since toinsert is preallocated it may have garbage at the end.
-			return inserter(toinsert[:nLeftovers*len(ddlColumns)])
+			return inserter(toinsert[:nBuffered*nColumns])
 		}
 
 		return nil
 	}
 }
 
+// Temporary tables dropped before being created again
+func dropTableStatements(dialect DatabaseConnectorInfoType, tname string) []string {
+	if dialect == OracleDatabase {
+		// Temporary tables with rows in the session can't be
+		// dropped
+		return []string{"TRUNCATE TABLE " + tname, "DROP TABLE " + tname}
+	}
+
+	return []string{"DROP TABLE " + tname}
+}
+
+// open starts a pass over the rows converted for the columns. When a
+// value doesn't fit its column the table is dropped and loaded again
+// with the column widened.
+func createAndLoadTable(
+	createTable func(string) error,
+	prepare func(string) (func([]any) error, func(), error),
+	qt quoteType,
+	cacheEnabled bool,
+	dialect DatabaseConnectorInfoType,
+	bulkLoad bulkLoader,
+	tableName string,
+	columns []column,
+	open func([]column) (func([]any) (bool, error), func(), error),
+) error {
+	columns = append([]column(nil), columns...)
+	for {
+		next, closeRows, err := open(columns)
+		if err != nil {
+			return err
+		}
+
+		// Bulk loaders don't all hand back the error next gave
+		var misfit *columnMisfitError
+		err = loadTable(createTable, prepare, qt, cacheEnabled, dialect, bulkLoad, tableName, columns, func(values []any) (bool, error) {
+			ok, err := next(values)
+			if m, isMisfit := err.(*columnMisfitError); isMisfit {
+				misfit = m
+			}
+			return ok, err
+		})
+		closeRows()
+		if misfit == nil {
+			return err
+		}
+
+		for i, c := range columns {
+			if c.name == misfit.column {
+				columns[i] = widenColumn(c, misfit.value, dialect)
+				Logln("Column %s of %s has values that don't fit %s, importing it as %s instead", c.name, tableName, c.kind, columns[i].kind)
+			}
+		}
+
+		for _, stmt := range dropTableStatements(dialect, quote(tableName, qt.identifier)) {
+			err := createTable(stmt)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func loadTable(
+	createTable func(string) error,
+	prepare func(string) (func([]any) error, func(), error),
+	qt quoteType,
+	cacheEnabled bool,
+	dialect DatabaseConnectorInfoType,
+	bulkLoad bulkLoader,
+	tableName string,
+	columns []column,
+	next func([]any) (bool, error),
+) error {
+	var ddlColumns []string
+	var columnNames []string
+	for _, c := range columns {
+		kind := c.kind
+		if dialect == ClickHouseDatabase {
+			// ClickHouse columns aren't nullable by default
+			kind = "Nullable(" + kind + ")"
+		}
+		ddlColumns = append(ddlColumns, quote(c.name, qt.identifier)+" "+kind)
+		columnNames = append(columnNames, c.name)
+	}
+
+	tname := quote(tableName, qt.identifier)
+	Logln("Creating table " + tableName)
+	for _, createQuery := range createTableStatements(dialect, tname, ddlColumns, cacheEnabled) {
+		err := createTable(createQuery)
+		if err != nil {
+			return err
+		}
+	}
+
+	if bulkLoad != nil {
+		return bulkLoad(tableName, columnNames, next)
+	}
+
+	chunkSize := insertChunkSize(dialect, len(ddlColumns))
+	return insertChunks(prepare, dialect, tname, len(ddlColumns), chunkSize, next)
+}
+
+// Calls emit for every object in the array at the last path, going
+// through the arrays at the paths before it. Counters number the
+// objects at each level the same way every time.
+func unnestRow(row map[string]any, paths []string, counters []int64, parentId int64, emit func(parentId, rowId int64, row map[string]any)) {
+	array, ok := GetObjectAtPath(row, paths[0]).([]any)
+	if !ok {
+		return
+	}
+
+	for _, element := range array {
+		object, ok := element.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		counters[0]++
+		if len(paths) == 1 {
+			emit(parentId, counters[0], object)
+			continue
+		}
+
+		unnestRow(object, paths[1:], counters[1:], counters[0], emit)
+	}
+}
+
+type unnestedRow struct {
+	parentId int64
+	rowId    int64
+	row      map[string]any
+}
+
+// Each child table is another pass over the panel's rows. Keys are
+// positions so they line up between passes.
+func importChildTables(
+	createTable func(string) error,
+	prepare func(string) (func([]any) error, func(), error),
+	projectId string,
+	panelId string,
+	paths []string,
+	children []panelToImport,
+	qt quoteType,
+	panelResultLoader func(string, string) (*RowIterator, error),
+	cacheEnabled bool,
+	dialect DatabaseConnectorInfoType,
+	bulkLoad bulkLoader,
+) error {
+	for _, child := range children {
+		chain := append(append([]string{}, paths...), child.arrayPath)
+		columns := append([]column{
+			rowKeyColumn(unnestRowIdColumn, dialect),
+			rowKeyColumn(unnestParentIdColumn, dialect),
+		}, child.columns...)
+
+		open := func(columns []column) (func([]any) (bool, error), func(), error) {
+			it, err := panelResultLoader(projectId, panelId)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			counters := make([]int64, len(chain))
+			var topId int64
+			var buffered []unnestedRow
+			return func(values []any) (bool, error) {
+				for len(buffered) == 0 {
+					if !it.Next() {
+						return false, it.Err()
+					}
+
+					topId++
+					unnestRow(it.Row(), chain, counters, topId, func(parentId, rowId int64, row map[string]any) {
+						buffered = append(buffered, unnestedRow{parentId, rowId, row})
+					})
+				}
+
+				r := buffered[0]
+				buffered = buffered[1:]
+				values[0] = r.rowId
+				values[1] = r.parentId
+				return true, importRowValues(r.row, columns[2:], dialect, values[2:])
+			}, it.Close, nil
+		}
+
+		err := createAndLoadTable(createTable, prepare, qt, cacheEnabled, dialect, bulkLoad, child.tableName, columns, open)
+		if err != nil {
+			return err
+		}
+
+		err = importChildTables(createTable, prepare, projectId, panelId, chain, child.children, qt, panelResultLoader, cacheEnabled, dialect, bulkLoad)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func importPanel(
+	createTable func(string) error,
+	prepare func(string) (func([]any) error, func(), error),
+	makeQuery func(string) ([]map[string]any, error),
+	projectId string,
+	query string,
+	panel panelToImport,
+	qt quoteType,
+	panelResultLoader func(string, string) (*RowIterator, error),
+	cacheEnabled bool,
+	dialect DatabaseConnectorInfoType,
+	bulkLoad bulkLoader,
+) error {
+	// Child tables join on the row's position
+	keyed := len(panel.children) > 0
+	columns := panel.columns
+	if keyed {
+		columns = append([]column{rowKeyColumn(unnestRowIdColumn, dialect)}, columns...)
+	}
+
+	open := func(columns []column) (func([]any) (bool, error), func(), error) {
+		it, err := panelResultLoader(projectId, panel.id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var rowId int64
+		return func(values []any) (bool, error) {
+			if !it.Next() {
+				return false, it.Err()
+			}
+
+			if keyed {
+				rowId++
+				values[0] = rowId
+				return true, importRowValues(it.Row(), columns[1:], dialect, values[1:])
+			}
+
+			return true, importRowValues(it.Row(), columns, dialect, values)
+		}, it.Close, nil
+	}
+
+	err := createAndLoadTable(createTable, prepare, qt, cacheEnabled, dialect, bulkLoad, panel.tableName, columns, open)
+	if err != nil {
+		return err
+	}
+
+	return importChildTables(createTable, prepare, projectId, panel.id, nil, panel.children, qt, panelResultLoader, cacheEnabled, dialect, bulkLoad)
+}
+
 func importAndRun(
 	createTable func(string) error,
 	prepare func(string) (func([]any) error, func(), error),
@@ -453,14 +1177,17 @@ func importAndRun(
 	panelsToImport []panelToImport,
 	qt quoteType,
 	// Postgres uses $1, mysql/sqlite use ?
-	panelResultLoader func(string, string) (chan map[string]any, error),
+	panelResultLoader func(string, string) (*RowIterator, error),
 	cacheSettings CacheSettings,
+	dialect DatabaseConnectorInfoType,
+	// Optional, inserts otherwise
+	bulkLoad bulkLoader,
 ) ([]map[string]any, error) {
 	if cacheSettings.CachePresent {
 		return makeQuery(query)
 	}
 	for _, panel := range panelsToImport {
-		err := importPanel(createTable, prepare, makeQuery, projectId, query, panel, qt, panelResultLoader, cacheSettings.Enabled)
+		err := importPanel(createTable, prepare, makeQuery, projectId, query, panel, qt, panelResultLoader, cacheSettings.Enabled, dialect, bulkLoad)
 		if err != nil {
 			return nil, err
 		}
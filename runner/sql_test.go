@@ -1,9 +1,11 @@
 package runner
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,6 +49,8 @@ func Test_transformDM_getPanelCalls(t *testing.T) {
 		quoteType{
 			identifier: "\"",
 		},
+		SQLiteDatabase,
+		false,
 		false,
 	)
 
@@ -82,6 +86,22 @@ func Test_transformDM_getPanelCalls(t *testing.T) {
 		},
 		id: " a great id",
 	})
+
+	// SQL Server temporary tables start with #
+	panels, query, _, err = transformDM_getPanelCalls(
+		"SELECT * FROM DM_getPanel(0)",
+		map[string]Shape{"0": shape},
+		map[string]string{"0": " a great id 2"},
+		true,
+		ansiSQLQuote,
+		SQLServerDatabase,
+		false,
+		false,
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT * FROM "#t_0"`, query)
+	assert.Equal(t, "#t_0", panels[0].tableName)
+	assert.Equal(t, "NVARCHAR(MAX)", panels[0].columns[1].kind)
 }
 
 func Test_transformDM_getPanel_callsWithPaths(t *testing.T) {
@@ -96,8 +116,8 @@ func Test_transformDM_getPanel_callsWithPaths(t *testing.T) {
 			`{"a": [{"b": 2}, {"c": 3}]}`,
 			`SELECT * FROM DM_getPanel(0, "a")`,
 			[]column{
-				{name: "b", kind: "REAL"},
-				{name: "c", kind: "REAL"},
+				{name: "b", kind: "INTEGER", convert: integerConversion, subtype: IntegerSubtype},
+				{name: "c", kind: "INTEGER", convert: integerConversion, subtype: IntegerSubtype},
 			},
 			"a",
 		},
@@ -105,8 +125,8 @@ func Test_transformDM_getPanel_callsWithPaths(t *testing.T) {
 			`{"a": [{"b": 2}, {"c": 3}]}`,
 			"SELECT * FROM DM_getPanel(0, 'a')",
 			[]column{
-				{name: "b", kind: "REAL"},
-				{name: "c", kind: "REAL"},
+				{name: "b", kind: "INTEGER", convert: integerConversion, subtype: IntegerSubtype},
+				{name: "c", kind: "INTEGER", convert: integerConversion, subtype: IntegerSubtype},
 			},
 			"a",
 		},
@@ -114,8 +134,8 @@ func Test_transformDM_getPanel_callsWithPaths(t *testing.T) {
 			`[{"b": 2}, {"c": 3}]`,
 			"SELECT * FROM DM_getPanel(0)",
 			[]column{
-				{name: "b", kind: "REAL"},
-				{name: "c", kind: "REAL"},
+				{name: "b", kind: "INTEGER", convert: integerConversion, subtype: IntegerSubtype},
+				{name: "c", kind: "INTEGER", convert: integerConversion, subtype: IntegerSubtype},
 			},
 			"",
 		},
@@ -134,6 +154,8 @@ func Test_transformDM_getPanel_callsWithPaths(t *testing.T) {
 			map[string]string{"0": " a great id 2"},
 			true,
 			quoteType{identifier: "\""},
+			SQLiteDatabase,
+			false,
 			false,
 		)
 
@@ -149,10 +171,270 @@ func Test_transformDM_getPanel_callsWithPaths(t *testing.T) {
 	}
 }
 
+// The panel name and path are captured separately so a named panel
+// can have a path too.
+func Test_dmGetPanelRe(t *testing.T) {
+	tests := []struct {
+		call    string
+		expName string
+		expPath string
+	}{
+		{`DM_getPanel(0)`, "0", ""},
+		{`DM_getPanel(0, 'a.b')`, "0", "'a.b'"},
+		{`DM_getPanel(0, "a")`, "0", `"a"`},
+		{`DM_getPanel('my panel')`, "'my panel'", ""},
+		{`DM_getPanel('my panel', 'a\.b.c')`, "'my panel'", `'a\.b.c'`},
+		{`DM_getPanel("my panel",  "a")`, `"my panel"`, `"a"`},
+	}
+
+	for _, test := range tests {
+		m := dmGetPanelRe.FindStringSubmatch("SELECT * FROM " + test.call)
+		assert.NotNil(t, m, test.call)
+
+		name, path := "", ""
+		for i, subexp := range dmGetPanelRe.SubexpNames() {
+			switch subexp {
+			case "number", "singlequote", "doublequote":
+				name += m[i]
+			case "numberdoublepath", "numbersinglepath", "singlepath", "doublepath":
+				path += m[i]
+			}
+		}
+		assert.Equal(t, test.expName, name, test.call)
+		assert.Equal(t, test.expPath, path, test.call)
+	}
+}
+
+func Test_transformDM_getPanel_namedWithPath(t *testing.T) {
+	var j any
+	data := `{"a": [{"b": 2}]}`
+	err := json.Unmarshal([]byte(data), &j)
+	assert.Nil(t, err)
+	s := GetShape("", j, len(data))
+
+	panels, query, path, err := transformDM_getPanelCalls(
+		"SELECT * FROM DM_getPanel('my panel', 'a')",
+		map[string]Shape{"my panel": s},
+		map[string]string{"my panel": "id1"},
+		true,
+		quoteType{identifier: "\""},
+		SQLiteDatabase,
+		false,
+		false,
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", path)
+	assert.Equal(t, 1, len(panels))
+	assert.Equal(t, "id1", panels[0].id)
+	assert.Equal(t, `SELECT * FROM "`+panels[0].tableName+`"`, query)
+	assert.Equal(t, []column{{name: "b", kind: "INTEGER", convert: integerConversion, subtype: IntegerSubtype}}, panels[0].columns)
+}
+
+func Test_sqlColumnType(t *testing.T) {
+	tests := []struct {
+		scalar  ScalarShape
+		dialect DatabaseConnectorInfoType
+		kind    string
+		convert columnConversion
+	}{
+		{ScalarShape{Name: NumberScalar}, PostgresDatabase, "REAL", noConversion},
+		{ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}, SQLiteDatabase, "INTEGER", integerConversion},
+		{ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}, PostgresDatabase, "BIGINT", integerConversion},
+		{ScalarShape{Name: NumberScalar, Subtype: DecimalSubtype}, MySQLDatabase, "DOUBLE", noConversion},
+		{ScalarShape{Name: StringScalar, Subtype: DatetimeSubtype}, SQLiteDatabase, "TIMESTAMP", noConversion},
+		{ScalarShape{Name: StringScalar, Subtype: DatetimeSubtype}, PostgresDatabase, "TIMESTAMPTZ", datetimeConversion},
+		{ScalarShape{Name: StringScalar, Subtype: DatetimeSubtype}, MySQLDatabase, "DATETIME(6)", datetimeConversion},
+		{ScalarShape{Name: StringScalar, Subtype: DateSubtype}, MySQLDatabase, "DATE", noConversion},
+		{ScalarShape{Name: StringScalar, Subtype: UUIDSubtype}, PostgresDatabase, "TEXT", noConversion},
+		{ScalarShape{Name: StringScalar}, SQLServerDatabase, "NVARCHAR(MAX)", noConversion},
+		{ScalarShape{Name: StringScalar, Subtype: DatetimeSubtype}, SQLServerDatabase, "DATETIMEOFFSET", datetimeConversion},
+		{ScalarShape{Name: BooleanScalar}, OracleDatabase, "NUMBER(1)", booleanConversion},
+		{ScalarShape{Name: NumberScalar, Subtype: IntegerSubtype}, OracleDatabase, "NUMBER(19)", integerConversion},
+		{ScalarShape{Name: StringScalar, Subtype: DateSubtype}, OracleDatabase, "DATE", dateConversion},
+		{ScalarShape{Name: NumberScalar}, ClickHouseDatabase, "Float64", noConversion},
+		{ScalarShape{Name: StringScalar, Subtype: DateSubtype}, ClickHouseDatabase, "Date32", dateConversion},
+		{ScalarShape{Name: StringScalar, Subtype: UUIDSubtype}, ClickHouseDatabase, "String", noConversion},
+		{ScalarShape{Name: StringScalar, Subtype: DatetimeSubtype}, SnowflakeDatabase, "TIMESTAMP_TZ", noConversion},
+		{ScalarShape{Name: BooleanScalar}, SnowflakeDatabase, "BOOLEAN", noConversion},
+	}
+
+	for _, test := range tests {
+		kind, convert := sqlColumnType(test.scalar, test.dialect)
+		assert.Equal(t, test.kind, kind)
+		assert.Equal(t, test.convert, convert)
+	}
+
+	assert.Equal(t, int64(2), convertColumnValue(2.0, integerConversion))
+	assert.Nil(t, convertColumnValue(2.5, integerConversion))
+	assert.Equal(t, time.Date(2022, 1, 5, 8, 0, 0, 0, time.UTC), convertColumnValue("2022-01-05T10:00:00+02:00", datetimeConversion))
+	assert.Nil(t, convertColumnValue("soon", datetimeConversion))
+	assert.Equal(t, time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC), convertColumnValue("2022-01-05", dateConversion))
+	assert.Equal(t, int64(1), convertColumnValue(true, booleanConversion))
+	assert.Equal(t, int64(0), convertColumnValue(false, booleanConversion))
+}
+
 func Test_postgresMangleInsert(t *testing.T) {
 	assert.Equal(t,
 		postgresMangleInsert("INSERT INTO x VALUES (?, ?, ?)"),
 		"INSERT INTO x VALUES ($1, $2, $3)")
+	assert.Equal(t,
+		sqlServerMangleInsert("INSERT INTO x VALUES (?, ?)"),
+		"INSERT INTO x VALUES (@p1, @p2)")
+	assert.Equal(t,
+		oracleMangleInsert("INSERT INTO x VALUES (?, ?)"),
+		"INSERT INTO x VALUES (:1, :2)")
+}
+
+func Test_bindMacroParams(t *testing.T) {
+	q := "SELECT * FROM x WHERE a = " + macroParamMarker + " AND b = '?' AND c = " + macroParamMarker
+	tests := []struct {
+		dialect DatabaseConnectorInfoType
+		exp     string
+	}{
+		{PostgresDatabase, "SELECT * FROM x WHERE a = $1 AND b = '?' AND c = $2"},
+		{CockroachDatabase, "SELECT * FROM x WHERE a = $1 AND b = '?' AND c = $2"},
+		{SQLServerDatabase, "SELECT * FROM x WHERE a = @p1 AND b = '?' AND c = @p2"},
+		{OracleDatabase, "SELECT * FROM x WHERE a = :1 AND b = '?' AND c = :2"},
+		{Neo4jDatabase, "SELECT * FROM x WHERE a = $p1 AND b = '?' AND c = $p2"},
+		{MySQLDatabase, "SELECT * FROM x WHERE a = ? AND b = '?' AND c = ?"},
+		{CassandraDatabase, "SELECT * FROM x WHERE a = ? AND b = '?' AND c = ?"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.exp, bindMacroParams(q, test.dialect), test.dialect)
+	}
+
+	assert.Equal(t, map[string]any{"p1": "a", "p2": float64(2)}, neo4jMacroParams([]any{"a", float64(2)}))
+	assert.Nil(t, neo4jMacroParams(nil))
+}
+
+func Test_makeInsertStatement(t *testing.T) {
+	assert.Equal(t,
+		`INSERT INTO "t" VALUES (?, ?), (?, ?)`,
+		makeInsertStatement(PostgresDatabase, `"t"`, 2, 2))
+	assert.Equal(t,
+		`INSERT ALL INTO "t" VALUES (?, ?) INTO "t" VALUES (?, ?) SELECT 1 FROM DUAL`,
+		makeInsertStatement(OracleDatabase, `"t"`, 2, 2))
+
+	assert.Equal(t, 100, insertChunkSize(SQLServerDatabase, 20))
+	assert.Equal(t, 4, insertChunkSize(SQLServerDatabase, 500))
+	assert.Equal(t, 1, insertChunkSize(SQLServerDatabase, 3000))
+	assert.Equal(t, 500, insertChunkSize(SQLiteDatabase, 20))
+	assert.Equal(t, 163, insertChunkSize(SQLiteDatabase, 200))
+	assert.Equal(t, 21, insertChunkSize(PostgresDatabase, 3000))
+	assert.Equal(t, 100, insertChunkSize(OracleDatabase, 2))
+	assert.Equal(t, 500, insertChunkSize(ClickHouseDatabase, 3000))
+}
+
+func Test_createTableStatements(t *testing.T) {
+	columns := []string{`"a" BIGINT`}
+	tests := []struct {
+		dialect DatabaseConnectorInfoType
+		cache   bool
+		exp     []string
+	}{
+		{PostgresDatabase, false, []string{`CREATE TEMPORARY TABLE "t" ("a" BIGINT);`}},
+		{PostgresDatabase, true, []string{`CREATE TABLE "t" ("a" BIGINT);`}},
+		{SQLServerDatabase, false, []string{`CREATE TABLE "t" ("a" BIGINT)`}},
+		{OracleDatabase, false, []string{
+			`BEGIN FOR t IN (SELECT 1 FROM user_tables ut JOIN user_tab_comments c ON c.table_name = ut.table_name WHERE ut.table_name = 't' AND ut.temporary = 'Y' AND c.comments = 'Created by DataStation for DM_getPanel') LOOP EXECUTE IMMEDIATE 'DROP TABLE "t"'; END LOOP; END;`,
+			`CREATE GLOBAL TEMPORARY TABLE "t" ("a" BIGINT) ON COMMIT PRESERVE ROWS`,
+			`COMMENT ON TABLE "t" IS 'Created by DataStation for DM_getPanel'`,
+		}},
+		// Fails on an existing table rather than dropping it
+		{OracleDatabase, true, []string{`CREATE TABLE "t" ("a" BIGINT)`}},
+		{ClickHouseDatabase, false, []string{`CREATE TEMPORARY TABLE "t" ("a" BIGINT) ENGINE = Memory`}},
+		{SnowflakeDatabase, false, []string{`CREATE TEMPORARY TABLE "t" ("a" BIGINT);`}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.exp, createTableStatements(test.dialect, `"t"`, columns, test.cache), test.dialect)
+	}
+}
+
+func Test_importPanel_dialects(t *testing.T) {
+	panel := panelToImport{
+		id:        "x",
+		tableName: "t_0",
+		columns: []column{
+			{name: "a", kind: "Float64"},
+			{name: "b", kind: "String"},
+		},
+	}
+	loader := func(string, string) (*RowIterator, error) {
+		return rowIteratorFromSlice([]map[string]any{
+			{"a": 1.0, "b": []any{1.0}},
+			{"a": 2.0, "b": "x"},
+		}), nil
+	}
+
+	var statements []string
+	var inserted []any
+	err := importPanel(
+		func(stmt string) error {
+			statements = append(statements, stmt)
+			return nil
+		},
+		func(stmt string) (func([]any) error, func(), error) {
+			statements = append(statements, stmt)
+			return func(values []any) error {
+				inserted = append(inserted, values...)
+				return nil
+			}, func() {}, nil
+		},
+		nil, "", "", panel, ansiSQLQuote, loader, false, ClickHouseDatabase, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		`CREATE TEMPORARY TABLE "t_0" ("a" Nullable(Float64), "b" Nullable(String)) ENGINE = Memory`,
+		makePreparedStatement(`"t_0"`, 2, 500),
+		`INSERT INTO "t_0" VALUES (?, ?), (?, ?)`,
+	}, statements)
+	// Nested values in text columns are sent as JSON strings
+	assert.Equal(t, []any{1.0, "[1]", 2.0, "x"}, inserted)
+}
+
+func Test_importPanel_widensColumns(t *testing.T) {
+	panel := panelToImport{
+		id:        "x",
+		tableName: "t_0",
+		columns: []column{
+			{name: "a", kind: "BIGINT", convert: integerConversion, subtype: IntegerSubtype},
+			{name: "b", kind: "DATE", subtype: DateSubtype},
+		},
+	}
+	loader := func(string, string) (*RowIterator, error) {
+		return rowIteratorFromSlice([]map[string]any{
+			{"a": 1.0, "b": "2022-01-01"},
+			{"a": 2.5, "b": "2022-01-02"},
+			{"a": 3.0, "b": "N/A"},
+		}), nil
+	}
+
+	var statements []string
+	var inserted []any
+	err := importPanel(
+		func(stmt string) error {
+			statements = append(statements, stmt)
+			return nil
+		},
+		func(stmt string) (func([]any) error, func(), error) {
+			inserted = nil
+			return func(values []any) error {
+				inserted = append(inserted, values...)
+				return nil
+			}, func() {}, nil
+		},
+		nil, "", "", panel, ansiSQLQuote, loader, false, PostgresDatabase, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		`CREATE TEMPORARY TABLE "t_0" ("a" BIGINT, "b" DATE);`,
+		`DROP TABLE "t_0"`,
+		`CREATE TEMPORARY TABLE "t_0" ("a" DOUBLE PRECISION, "b" DATE);`,
+		`DROP TABLE "t_0"`,
+		`CREATE TEMPORARY TABLE "t_0" ("a" DOUBLE PRECISION, "b" TEXT);`,
+	}, statements)
+	// Nothing that didn't fit is dropped
+	assert.Equal(t, []any{1.0, "2022-01-01", 2.5, "2022-01-02", 3.0, "N/A"}, inserted)
 }
 
 func Test_GetObjectAtPath(t *testing.T) {
@@ -292,6 +574,14 @@ func Test_sqlIngest_e2e(t *testing.T) {
 				map[string]any{"id": float64(2), "name": "Corah"},
 			},
 		},
+		{
+			`[{"i": 1, "f": 1.5, "d": "2022-01-05"}, {"i": 2, "f": 2, "d": "2022-02-05"}]`,
+			"SELECT typeof(i) ti, typeof(f) tf, d FROM DM_getPanel(0) WHERE d >= '2022-02-01'",
+			[]any{
+				// The driver reads DATE columns back as times
+				map[string]any{"ti": "integer", "tf": "real", "d": "2022-02-05T00:00:00Z"},
+			},
+		},
 	}
 
 	projectTmp, err := os.CreateTemp("", "dsq-project")
@@ -323,7 +613,7 @@ func Test_sqlIngest_e2e(t *testing.T) {
 		assert.Nil(t, err)
 
 		panelId := newId()
-		s, err := ShapeFromFile(readFile.Name(), panelId, 10_000, 100)
+		s, err := ShapeFromFile(readFile.Name(), panelId, 100)
 		assert.Nil(t, err)
 		project.Pages[0].Panels = append(project.Pages[0].Panels, PanelInfo{
 			ResultMeta: PanelResult{
@@ -345,7 +635,7 @@ func Test_sqlIngest_e2e(t *testing.T) {
 			},
 		}
 
-		err = ec.EvalDatabasePanel(project, 0, panel2, func(projectId, panelId string) (chan map[string]any, error) {
+		err = ec.EvalDatabasePanel(context.Background(), project, 0, panel2, func(projectId, panelId string) (*RowIterator, error) {
 			return loadJSONArrayFileWithPath(readFile.Name(), ec.path)
 		}, *DefaultCacheSettings)
 		if err != nil {
@@ -356,14 +646,98 @@ func Test_sqlIngest_e2e(t *testing.T) {
 		f := ec.GetPanelResultsFile(project.Id, panel2.Id)
 		a, err := loadJSONArrayFile(f)
 		var pieces []any
-		for r := range a {
-			pieces = append(pieces, r)
-		}
 		assert.Nil(t, err)
+		for a.Next() {
+			pieces = append(pieces, a.Row())
+		}
+		assert.Nil(t, a.Err())
 		assert.Equal(t, test.expResult, pieces)
 	}
 }
 
+func Test_sqlIngest_unnest(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	readFile, err := os.CreateTemp("", "infile")
+	assert.Nil(t, err)
+	defer os.Remove(readFile.Name())
+	_, err = readFile.WriteString(`[
+  {"id": 1, "items": [{"sku": "a", "price": 2, "options": [{"o": "x"}]}, {"sku": "b", "price": 3}]},
+  {"id": 2, "items": []},
+  {"id": 3, "meta": {"tags": [{"t": "z"}]}, "items": [{"sku": "c", "price": 5, "options": [{"o": "y"}, {"o": "w"}]}]}
+]`)
+	assert.Nil(t, err)
+
+	panelId := newId()
+	s, err := ShapeFromFile(readFile.Name(), panelId, 100)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		sql       string
+		expResult []any
+	}{
+		{
+			`SELECT o.id, SUM(i.price) total FROM DM_getPanel(0) o JOIN "t_0__items" i ON i.__parent_row_id = o.__row_id GROUP BY o.id ORDER BY o.id`,
+			[]any{
+				map[string]any{"id": float64(1), "total": float64(5)},
+				map[string]any{"id": float64(3), "total": float64(5)},
+			},
+		},
+		{
+			`SELECT i.sku, op.o FROM DM_getPanel(0) o JOIN "t_0__items" i ON i.__parent_row_id = o.__row_id JOIN "t_0__items__options" op ON op.__parent_row_id = i.__row_id ORDER BY op.__row_id`,
+			[]any{
+				map[string]any{"sku": "a", "o": "x"},
+				map[string]any{"sku": "c", "o": "y"},
+				map[string]any{"sku": "c", "o": "w"},
+			},
+		},
+		{
+			`SELECT o.id, g.t FROM DM_getPanel(0) o JOIN "t_0__meta__tags" g ON g.__parent_row_id = o.__row_id`,
+			[]any{map[string]any{"id": float64(3), "t": "z"}},
+		},
+	}
+
+	for _, test := range tests {
+		connector, err := MakeTmpSQLiteConnector()
+		assert.Nil(t, err)
+
+		panel := &PanelInfo{
+			Type:    DatabasePanel,
+			Content: test.sql,
+			Id:      newId(),
+			Name:    newId(),
+			DatabasePanelInfo: &DatabasePanelInfo{
+				Database: DatabasePanelInfoDatabase{
+					ConnectorId: connector.Id,
+					Extra:       map[string]string{"unnest_arrays": "true"},
+				},
+			},
+		}
+		project := &ProjectState{
+			Id:         "unnest-test",
+			Connectors: []ConnectorInfo{*connector},
+			Pages: []ProjectPage{{Panels: []PanelInfo{
+				{ResultMeta: PanelResult{Shape: *s}, Id: panelId, Name: newId()},
+			}}},
+		}
+
+		err = ec.EvalDatabasePanel(context.Background(), project, 0, panel, func(projectId, panelId string) (*RowIterator, error) {
+			return loadJSONArrayFile(readFile.Name())
+		}, *DefaultCacheSettings)
+		assert.Nil(t, err)
+
+		a, err := loadJSONArrayFile(ec.GetPanelResultsFile(project.Id, panel.Id))
+		assert.Nil(t, err)
+		var rows []any
+		for a.Next() {
+			rows = append(rows, a.Row())
+		}
+		assert.Nil(t, a.Err())
+		assert.Equal(t, test.expResult, rows, test.sql)
+	}
+}
+
 // BENCHMARKS
 
 func Test_sqlIngest_BENCHMARK(t *testing.T) {
@@ -393,7 +767,7 @@ func Test_sqlIngest_BENCHMARK(t *testing.T) {
 	readFile := "taxi.json"
 
 	panelId := newId()
-	s, err := ShapeFromFile(readFile, panelId, 10_000, 100)
+	s, err := ShapeFromFile(readFile, panelId, 100)
 	assert.Nil(t, err)
 	project.Pages[0].Panels = append(project.Pages[0].Panels, PanelInfo{
 		ResultMeta: PanelResult{
@@ -416,7 +790,7 @@ func Test_sqlIngest_BENCHMARK(t *testing.T) {
 	}
 
 	ec := EvalContext{}
-	err = ec.EvalDatabasePanel(project, 0, panel2, func(projectId, panelId string) (chan map[string]any, error) {
+	err = ec.EvalDatabasePanel(context.Background(), project, 0, panel2, func(projectId, panelId string) (*RowIterator, error) {
 		return loadJSONArrayFileWithPath(readFile, ec.path)
 	}, *DefaultCacheSettings)
 	assert.Nil(t, err)
@@ -0,0 +1,251 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mysqlDelimiterRe = regexp.MustCompile(`(?i)^\s*DELIMITER\s+(\S+)\s*$`)
+	dollarQuoteRe    = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+	// Bodies of these contain semicolons so they only end at the
+	// dialect's block terminator: / on its own line for Oracle, GO
+	// for SQL Server and the END matching the BEGIN for SQLite.
+	oracleBlockRe    = regexp.MustCompile(`(?is)^(BEGIN|DECLARE|CREATE\s+(OR\s+REPLACE\s+)?((NON)?EDITIONABLE\s+)?(PROCEDURE|FUNCTION|PACKAGE|TRIGGER|TYPE))\b`)
+	sqlServerBlockRe = regexp.MustCompile(`(?is)^(CREATE|ALTER|CREATE\s+OR\s+ALTER)\s+(PROCEDURE|PROC|FUNCTION|TRIGGER|VIEW)\b`)
+	sqliteTriggerRe  = regexp.MustCompile(`(?is)^CREATE\s+(TEMP\s+|TEMPORARY\s+)?TRIGGER\b`)
+
+	mysqlCallRe     = regexp.MustCompile(`(?is)^CALL\b`)
+	sqlServerExecRe = regexp.MustCompile(`(?is)^EXEC(UTE)?\b`)
+)
+
+func isPostgresLike(dialect DatabaseConnectorInfoType) bool {
+	switch dialect {
+	case PostgresDatabase, TimescaleDatabase, CockroachDatabase, CrateDatabase, YugabyteDatabase, QuestDatabase:
+		return true
+	}
+
+	return false
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
+
+// Drops leading whitespace and comments, returns what's left.
+func skipSQLComments(stmt string) string {
+	for {
+		stmt = strings.TrimSpace(stmt)
+		switch {
+		case strings.HasPrefix(stmt, "--"):
+			end := strings.IndexByte(stmt, '\n')
+			if end == -1 {
+				return ""
+			}
+			stmt = stmt[end+1:]
+		case strings.HasPrefix(stmt, "/*"):
+			end := strings.Index(stmt[2:], "*/")
+			if end == -1 {
+				return ""
+			}
+			stmt = stmt[end+4:]
+		default:
+			return stmt
+		}
+	}
+}
+
+// Returns the index just past the end of the quoted string, comment
+// or dollar-quoted body starting at i, or i if there isn't one
+// there.
+func skipSQLQuoted(script string, i int, dialect DatabaseConnectorInfoType) int {
+	rest := script[i:]
+	switch {
+	case strings.HasPrefix(rest, "--") || (dialect == MySQLDatabase && rest[0] == '#'):
+		end := strings.IndexByte(rest, '\n')
+		if end == -1 {
+			return len(script)
+		}
+		return i + end
+	case strings.HasPrefix(rest, "/*"):
+		end := strings.Index(rest[2:], "*/")
+		if end == -1 {
+			return len(script)
+		}
+		return i + 2 + end + 2
+	}
+
+	closer := byte(0)
+	switch rest[0] {
+	case '\'', '"':
+		closer = rest[0]
+	case '`':
+		if dialect == MySQLDatabase {
+			closer = '`'
+		}
+	case '[':
+		if dialect == SQLServerDatabase {
+			closer = ']'
+		}
+	case '$':
+		if !isPostgresLike(dialect) && dialect != SnowflakeDatabase {
+			return i
+		}
+		// $1 and identifiers containing $ aren't quotes
+		if i > 0 && isIdentifierByte(script[i-1]) {
+			return i
+		}
+
+		tag := dollarQuoteRe.FindString(rest)
+		if tag == "" {
+			return i
+		}
+
+		end := strings.Index(rest[len(tag):], tag)
+		if end == -1 {
+			return len(script)
+		}
+		return i + len(tag) + end + len(tag)
+	}
+
+	if closer == 0 {
+		return i
+	}
+
+	for j := 1; j < len(rest); j++ {
+		if rest[j] == '\\' && dialect == MySQLDatabase && closer != '`' {
+			j++
+			continue
+		}
+
+		if rest[j] == closer {
+			// Doubled quotes are escaped quotes
+			if j+1 < len(rest) && rest[j+1] == closer {
+				j++
+				continue
+			}
+
+			return i + j + 1
+		}
+	}
+
+	return len(script)
+}
+
+// Procedure calls can return any number of result sets so they're
+// namespaced even when they're the only statement.
+func isMultiResultStatement(stmt string, dialect DatabaseConnectorInfoType) bool {
+	stmt = skipSQLComments(stmt)
+	switch dialect {
+	case MySQLDatabase:
+		return mysqlCallRe.MatchString(stmt)
+	case SQLServerDatabase:
+		return sqlServerExecRe.MatchString(stmt)
+	}
+
+	return false
+}
+
+// Splits a script into the statements that need to be run one at a
+// time. Delimiters are skipped inside strings, quoted identifiers,
+// comments and routine bodies. MySQL's DELIMITER command and SQL
+// Server's GO are handled like their clients handle them.
+func splitSQLStatements(script string, dialect DatabaseConnectorInfoType) []string {
+	var statements []string
+	var current strings.Builder
+	delimiter := ";"
+	// BEGIN and CASE minus END, for SQLite triggers
+	depth := 0
+
+	flush := func() {
+		stmt := strings.TrimSpace(current.String())
+		if skipSQLComments(stmt) != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+		depth = 0
+	}
+
+	inBlock := func() bool {
+		stmt := skipSQLComments(current.String())
+		switch dialect {
+		case OracleDatabase:
+			return oracleBlockRe.MatchString(stmt)
+		case SQLServerDatabase:
+			return sqlServerBlockRe.MatchString(stmt)
+		case SQLiteDatabase:
+			return depth > 0 && sqliteTriggerRe.MatchString(stmt)
+		}
+
+		return false
+	}
+
+	for i := 0; i < len(script); {
+		if i == 0 || script[i-1] == '\n' {
+			end := strings.IndexByte(script[i:], '\n')
+			if end == -1 {
+				end = len(script) - i
+			}
+			line := strings.TrimSpace(script[i : i+end])
+
+			command := false
+			switch dialect {
+			case MySQLDatabase:
+				if m := mysqlDelimiterRe.FindStringSubmatch(line); m != nil {
+					delimiter = m[1]
+					command = true
+				}
+			case SQLServerDatabase:
+				command = strings.EqualFold(line, "GO")
+			case OracleDatabase:
+				command = line == "/"
+			}
+
+			if command {
+				flush()
+				i += end
+				continue
+			}
+		}
+
+		if j := skipSQLQuoted(script, i, dialect); j > i {
+			current.WriteString(script[i:j])
+			i = j
+			continue
+		}
+
+		if strings.HasPrefix(script[i:], delimiter) && !inBlock() {
+			flush()
+			i += len(delimiter)
+			continue
+		}
+
+		if isIdentifierByte(script[i]) && (i == 0 || !isIdentifierByte(script[i-1])) {
+			j := i
+			for j < len(script) && isIdentifierByte(script[j]) {
+				j++
+			}
+
+			switch strings.ToUpper(script[i:j]) {
+			case "BEGIN", "CASE":
+				depth++
+			case "END":
+				depth--
+			}
+
+			current.WriteString(script[i:j])
+			i = j
+			continue
+		}
+
+		current.WriteByte(script[i])
+		i++
+	}
+	flush()
+
+	return statements
+}
@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_splitSQLStatements(t *testing.T) {
+	tests := []struct {
+		dialect DatabaseConnectorInfoType
+		script  string
+		exp     []string
+	}{
+		{SQLiteDatabase, "SELECT 1", []string{"SELECT 1"}},
+		{SQLiteDatabase, "SELECT 1;", []string{"SELECT 1"}},
+		{
+			SQLiteDatabase,
+			"CREATE TEMP TABLE t (a TEXT);\nINSERT INTO t VALUES ('a;b'), (\"c;\");\n-- done;\nSELECT * FROM t /* ; */;\n-- trailing",
+			[]string{
+				"CREATE TEMP TABLE t (a TEXT)",
+				"INSERT INTO t VALUES ('a;b'), (\"c;\")",
+				"-- done;\nSELECT * FROM t /* ; */",
+			},
+		},
+		{SQLiteDatabase, "SELECT 'it''s; fine'; SELECT 2", []string{"SELECT 'it''s; fine'", "SELECT 2"}},
+		{
+			SQLiteDatabase,
+			"CREATE TRIGGER tr AFTER INSERT ON t BEGIN INSERT INTO u VALUES (CASE WHEN 1 THEN 2 END); DELETE FROM v; END; SELECT 1",
+			[]string{
+				"CREATE TRIGGER tr AFTER INSERT ON t BEGIN INSERT INTO u VALUES (CASE WHEN 1 THEN 2 END); DELETE FROM v; END",
+				"SELECT 1",
+			},
+		},
+		{SQLiteDatabase, "BEGIN; SELECT 1; COMMIT", []string{"BEGIN", "SELECT 1", "COMMIT"}},
+		{
+			PostgresDatabase,
+			"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql; SELECT $$a;b$$, $1",
+			[]string{
+				"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql",
+				"SELECT $$a;b$$, $1",
+			},
+		},
+		{
+			MySQLDatabase,
+			"SELECT 'a\\';b' # c;\n;\nDELIMITER //\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT `x;y`; END//\nDELIMITER ;\nCALL p()",
+			[]string{
+				"SELECT 'a\\';b' # c;",
+				"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT `x;y`; END",
+				"CALL p()",
+			},
+		},
+		{
+			SQLServerDatabase,
+			"SELECT [a;b] FROM t; SELECT 2\ngo\nCREATE PROCEDURE p AS BEGIN SELECT 1; SELECT 2; END\nGO\nEXEC p",
+			[]string{
+				"SELECT [a;b] FROM t",
+				"SELECT 2",
+				"CREATE PROCEDURE p AS BEGIN SELECT 1; SELECT 2; END",
+				"EXEC p",
+			},
+		},
+		{
+			OracleDatabase,
+			"SELECT 1 FROM dual;\nBEGIN\n  INSERT INTO t VALUES (1);\nEND;\n/\nSELECT 2 FROM dual",
+			[]string{
+				"SELECT 1 FROM dual",
+				"BEGIN\n  INSERT INTO t VALUES (1);\nEND;",
+				"SELECT 2 FROM dual",
+			},
+		},
+		{SQLiteDatabase, "-- nothing here", nil},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.exp, splitSQLStatements(test.script, test.dialect), test.script)
+	}
+}
+
+func Test_isMultiResultStatement(t *testing.T) {
+	assert.True(t, isMultiResultStatement("CALL p()", MySQLDatabase))
+	assert.True(t, isMultiResultStatement("-- report\ncall p(1)", MySQLDatabase))
+	assert.True(t, isMultiResultStatement("EXEC dbo.report", SQLServerDatabase))
+	assert.True(t, isMultiResultStatement("execute dbo.report @a = 1", SQLServerDatabase))
+	assert.False(t, isMultiResultStatement("EXECUTE stmt", PostgresDatabase))
+	assert.False(t, isMultiResultStatement("SELECT * FROM calls", MySQLDatabase))
+	assert.False(t, isMultiResultStatement("EXECUTOR", SQLServerDatabase))
+}
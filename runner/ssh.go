@@ -3,6 +3,7 @@ package runner
 import (
 	"bufio"
 	"compress/gzip"
+	"context"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -92,6 +93,14 @@ var defaultKeyFiles = []string{
 }
 
 func (ec EvalContext) getSSHClient(si ServerInfo) (*ssh.Client, error) {
+	if ec.conns != nil {
+		return ec.conns.getSSHClient(si, ec.dialSSHClient)
+	}
+
+	return ec.dialSSHClient(si)
+}
+
+func (ec EvalContext) dialSSHClient(si ServerInfo) (*ssh.Client, error) {
 	config := &ssh.ClientConfig{
 		User: si.Username,
 		// TODO: figure out if we want to validate host keys
@@ -144,7 +153,7 @@ func (ec EvalContext) getSSHClient(si ServerInfo) (*ssh.Client, error) {
 	return conn, nil
 }
 
-func (ec EvalContext) remoteFileReader(si ServerInfo, remoteFileName string, callback func(r *bufio.Reader) error) error {
+func (ec EvalContext) remoteFileReader(ctx context.Context, si ServerInfo, remoteFileName string, callback func(r *bufio.Reader) error) error {
 	client, err := ec.getSSHClient(si)
 	if err != nil {
 		return err
@@ -155,6 +164,8 @@ func (ec EvalContext) remoteFileReader(si ServerInfo, remoteFileName string, cal
 		return err
 	}
 	defer session.Close()
+	// Closing the session unblocks any reads on stdout.
+	defer closeOnDone(ctx, func() { session.Close() })()
 
 	r, err := session.StdoutPipe()
 	if err != nil {
@@ -257,11 +268,13 @@ func pipe(conn1 net.Conn, conn2 net.Conn) error {
 	}
 }
 
-func (ec EvalContext) withRemoteConnection(si *ServerInfo, host, port string, cb func(host, port string) error) error {
+func (ec EvalContext) withRemoteConnection(ctx context.Context, si *ServerInfo, host, port string, cb func(host, port string) error) error {
 	if si == nil {
 		return cb(host, port)
 	}
 
+	connected := ec.stats.time(connectPhase)
+
 	// Pick any open port
 	localConn, err := net.Listen("tcp", "localhost:0")
 	if err != nil {
@@ -279,6 +292,11 @@ func (ec EvalContext) withRemoteConnection(si *ServerInfo, host, port string, cb
 		return err
 	}
 	defer remoteConn.Close()
+	defer closeOnDone(ctx, func() {
+		localConn.Close()
+		remoteConn.Close()
+	})()
+	connected()
 
 	errC := make(chan error)
 
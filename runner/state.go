@@ -5,15 +5,81 @@ import (
 	"time"
 )
 
+// Fields the runner doesn't know are left out so the desktop can
+// fill them in. Results with a preview don't need the desktop to read
+// the results file.
 type PanelResult struct {
-	Exception   any      `json:"exception" db:"exception"`
-	Preview     string   `json:"preview" db:"preview"`
-	Stdout      string   `json:"stdout" db:"stdout"`
-	Shape       Shape    `json:"shape" db:"shape"`
-	ArrayCount  *float64 `json:"arrayCount" db:"arrayCount"`
-	Size        *float64 `json:"size" db:"size"`
-	ContentType string   `json:"contentType" db:"contentType"`
-	Elapsed     *float64 `json:"elapsed" db:"elapsed"`
+	Exception   any           `json:"exception" db:"exception"`
+	Preview     string        `json:"preview,omitempty" db:"preview"`
+	Stdout      string        `json:"stdout" db:"stdout"`
+	Shape       Shape         `json:"shape" db:"shape"`
+	ArrayCount  *float64      `json:"arrayCount,omitempty" db:"arrayCount"`
+	Size        *float64      `json:"size,omitempty" db:"size"`
+	ContentType string        `json:"contentType" db:"contentType"`
+	Elapsed     *float64      `json:"elapsed,omitempty" db:"elapsed"`
+	Timings     *PanelTimings `json:"timings,omitempty" db:"timings"`
+	// What the runner did while evaluating
+	Logs []LogRecord `json:"logs,omitempty" db:"logs"`
+	// Reused from an earlier eval rather than evaluated again
+	Cached bool `json:"cached,omitempty" db:"cached"`
+	// Cut off at a row or byte limit
+	Truncated  bool              `json:"truncated,omitempty" db:"truncated"`
+	Truncation *ResultTruncation `json:"truncation,omitempty" db:"truncation"`
+	// Per top-level column of array-of-object results
+	ColumnStats map[string]ColumnStats `json:"columnStats,omitempty" db:"columnStats"`
+}
+
+type ValueCount struct {
+	Value any   `json:"value"`
+	Count int64 `json:"count"`
+}
+
+// Gathered from every row as it's written. Null counts include rows
+// missing the column.
+type ColumnStats struct {
+	NullCount int64 `json:"nullCount"`
+	// Numbers, or else dates and datetimes as they were written
+	Min any `json:"min,omitempty"`
+	Max any `json:"max,omitempty"`
+	// Approximate
+	DistinctCount int64 `json:"distinctCount"`
+	// Most common values, counts are approximate
+	TopValues []ValueCount `json:"topValues,omitempty"`
+	// Of strings, in characters
+	MinLength *int64 `json:"minLength,omitempty"`
+	MaxLength *int64 `json:"maxLength,omitempty"`
+}
+
+type ResultTruncation struct {
+	// rows or bytes
+	Limit string `json:"limit"`
+	Max   int64  `json:"max"`
+	// Rows produced up to and including the one that hit the limit.
+	// Unknown for results that aren't written a row at a time.
+	RowsSeen int64 `json:"rowsSeen"`
+}
+
+type ResultLimitAction string
+
+const (
+	TruncateOnLimit ResultLimitAction = "truncate"
+	ErrorOnLimit    ResultLimitAction = "error"
+)
+
+// Zero values fall back to the settings, zero there means no limit.
+type ResultLimits struct {
+	MaxRows  int64             `json:"maxRows"`
+	MaxBytes int64             `json:"maxBytes"`
+	OnLimit  ResultLimitAction `json:"onLimit"`
+}
+
+// Milliseconds spent in each phase of evaluating a panel. Query is
+// everything that isn't connecting, importing or writing results.
+type PanelTimings struct {
+	Connect float64 `json:"connect"`
+	Import  float64 `json:"import"`
+	Query   float64 `json:"query"`
+	Write   float64 `json:"write"`
 }
 
 type Encrypt struct {
@@ -70,6 +136,10 @@ type PanelInfo struct {
 	ServerId   string        `json:"serverId" db:"serverId"`
 	ResultMeta PanelResult   `json:"resultMeta" db:"resultMeta"`
 	PageId     string        `json:"pageId" db:"pageId"`
+	// Seconds to reuse results for, 0 uses the settings default and
+	// negative never reuses them
+	CacheTTL     int          `json:"cacheTtl" db:"cacheTtl"`
+	ResultLimits ResultLimits `json:"resultLimits" db:"resultLimits"`
 	*ProgramPanelInfo
 	*FilePanelInfo
 	*LiteralPanelInfo
@@ -97,6 +167,8 @@ type ProgramPanelInfo struct {
 	Program struct {
 		Type      SupportedLanguages `json:"type" db:"type"`
 		CustomExe string             `json:"customExe" db:"customExe"`
+		// Only used by SQL programs, empty uses the settings default
+		Engine SQLEngine `json:"engine" db:"engine"`
 	} `json:"program" db:"program"`
 }
 
@@ -228,9 +300,12 @@ type DatabasePanelInfo struct {
 }
 
 type GraphPanelInfoGraph struct {
-	PanelSource string        `json:"panelSource" db:"panelSource"`
-	Ys          []TableColumn `json:"ys" db:"ys"`
-	X           string        `json:"x" db:"x"`
+	PanelSource string `json:"panelSource" db:"panelSource"`
+	// Path to the array within the source's results, same as the
+	// path argument of DM_getPanel
+	PanelSourcePath string        `json:"panelSourcePath" db:"panelSourcePath"`
+	Ys              []TableColumn `json:"ys" db:"ys"`
+	X               string        `json:"x" db:"x"`
 }
 
 type GraphPanelInfo struct {
@@ -242,10 +317,43 @@ type TableColumn struct {
 	Label string `json:"label"`
 }
 
+type TableSortKey struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+type TableFilterOperator string
+
+const (
+	EqualFilter          TableFilterOperator = "="
+	NotEqualFilter       TableFilterOperator = "!="
+	LessFilter           TableFilterOperator = "<"
+	LessOrEqualFilter    TableFilterOperator = "<="
+	GreaterFilter        TableFilterOperator = ">"
+	GreaterOrEqualFilter TableFilterOperator = ">="
+	ContainsFilter       TableFilterOperator = "contains"
+	IsNullFilter         TableFilterOperator = "isNull"
+	IsNotNullFilter      TableFilterOperator = "isNotNull"
+)
+
+type TableFilter struct {
+	Field    string              `json:"field"`
+	Operator TableFilterOperator `json:"operator"`
+	// Unused by isNull and isNotNull
+	Value any `json:"value"`
+}
+
 type TablePanelInfoTable struct {
 	Columns     []TableColumn `json:"columns" db:"columns"`
 	PanelSource string        `json:"panelSource" db:"panelSource"`
-	RowNumbers  bool          `json:"rowNumbers"`
+	// Path to the array within the source's results, same as the
+	// path argument of DM_getPanel
+	PanelSourcePath string `json:"panelSourcePath" db:"panelSourcePath"`
+	RowNumbers      bool   `json:"rowNumbers"`
+	// Applied in order, earlier keys win
+	Sort []TableSortKey `json:"sort"`
+	// Rows must match every filter
+	Filters []TableFilter `json:"filters"`
 }
 
 type TablePanelInfo struct {
@@ -332,7 +440,8 @@ type HttpConnectorInfo struct {
 
 type ProjectPage struct {
 	Panels        []PanelInfo `json:"panels" db:"panels"`
-	Schedules     []any       `json:"schedules" db:"schedules"`
+	Schedules     []Schedule  `json:"schedules" db:"schedules"`
+	Variables     []Variable  `json:"variables" db:"variables"`
 	Name          string      `json:"name" db:"name"`
 	Id            string      `json:"id" db:"id"`
 	RefreshPeriod int         `json:"refreshPeriod" db:"refreshPeriod"`
@@ -342,6 +451,7 @@ type ProjectState struct {
 	Pages      []ProjectPage   `json:"pages" db:"pages"`
 	Connectors []ConnectorInfo `json:"connectors" db:"connectors"`
 	Servers    []ServerInfo    `json:"servers" db:"servers"`
+	Variables  []Variable      `json:"variables" db:"variables"`
 	Id         string          `json:"projectName" db:"projectName"`
 	// Basically never use uuid
 	Uuid            string `json:"id" db:"id"`
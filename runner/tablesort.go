@@ -0,0 +1,513 @@
+package runner
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rows sorted in memory at once. Bigger results are sorted in runs
+// of this size that are spilled to disk and merged.
+var tableSortMemoryRows = 100_000
+
+func validateTableFilters(filters []TableFilter) error {
+	for _, f := range filters {
+		switch f.Operator {
+		case EqualFilter, NotEqualFilter, LessFilter, LessOrEqualFilter, GreaterFilter,
+			GreaterOrEqualFilter, ContainsFilter, IsNullFilter, IsNotNullFilter:
+		default:
+			return edsef("Unknown table filter operator: %s", f.Operator)
+		}
+	}
+
+	return nil
+}
+
+// nulls < booleans < numbers < strings < everything else
+func compareRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64, int64:
+		return 2
+	case string:
+		return 3
+	}
+
+	return 4
+}
+
+func compareValues(a, b any) int {
+	if a, _, ok := columnarValue(a); ok {
+		if b, _, ok := columnarValue(b); ok {
+			return compareScalars(a, b)
+		}
+	}
+
+	return compareScalars(a, b)
+}
+
+// Integers only compare exactly with other integers, anything else
+// is compared as float64.
+func compareNumbers(a, b any) int {
+	ai, aok := a.(int64)
+	bi, bok := b.(int64)
+	if aok && bok {
+		if ai < bi {
+			return -1
+		}
+		if ai > bi {
+			return 1
+		}
+		return 0
+	}
+
+	af, bf := numberAsFloat(a), numberAsFloat(b)
+	if af < bf {
+		return -1
+	}
+	if af > bf {
+		return 1
+	}
+	return 0
+}
+
+func numberAsFloat(v any) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+
+	return v.(float64)
+}
+
+func compareScalars(a, b any) int {
+	ra, rb := compareRank(a), compareRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	switch t := a.(type) {
+	case nil:
+		return 0
+	case bool:
+		if t == b.(bool) {
+			return 0
+		}
+		if !t {
+			return -1
+		}
+		return 1
+	case float64, int64:
+		return compareNumbers(t, b)
+	case string:
+		return strings.Compare(t, b.(string))
+	}
+
+	// Objects and arrays at least sort consistently
+	as, _ := jsonMarshal(a)
+	bs, _ := jsonMarshal(b)
+	return strings.Compare(string(as), string(bs))
+}
+
+// Filter values typed into the UI are strings. Compare them as the
+// type of the value in the row when they parse as one.
+func coerceFilterValue(want, have any) any {
+	s, ok := want.(string)
+	if !ok {
+		return want
+	}
+
+	switch have.(type) {
+	case int64:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+
+	return s
+}
+
+func filterMatches(f TableFilter, v any) bool {
+	switch f.Operator {
+	case IsNullFilter:
+		return v == nil
+	case IsNotNullFilter:
+		return v != nil
+	}
+
+	// Like SQL, null doesn't compare to anything
+	if v == nil {
+		return false
+	}
+
+	if f.Operator == ContainsFilter {
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprintf("%v", v)
+		}
+		return strings.Contains(s, fmt.Sprintf("%v", f.Value))
+	}
+
+	c := compareValues(v, coerceFilterValue(f.Value, v))
+	switch f.Operator {
+	case EqualFilter:
+		return c == 0
+	case NotEqualFilter:
+		return c != 0
+	case LessFilter:
+		return c < 0
+	case LessOrEqualFilter:
+		return c <= 0
+	case GreaterFilter:
+		return c > 0
+	case GreaterOrEqualFilter:
+		return c >= 0
+	}
+
+	return false
+}
+
+func rowMatchesFilters(row map[string]any, filters []TableFilter) bool {
+	for _, f := range filters {
+		if !filterMatches(f, row[f.Field]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func compareRows(a, b map[string]any, keys []TableSortKey) int {
+	for _, k := range keys {
+		c := compareValues(a[k.Field], b[k.Field])
+		if k.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// A sorted run of rows spilled to disk, one JSON object per line.
+type sortRun struct {
+	file string
+	fd   *os.File
+	r    *bufio.Reader
+	// The next row of the run, nil once the run is done
+	row map[string]any
+	// Earlier runs win ties so the merge is stable
+	index int
+}
+
+func spillSortRun(rows []map[string]any, index int) (*sortRun, error) {
+	fd, err := os.CreateTemp("", "datastation-table-sort")
+	if err != nil {
+		return nil, err
+	}
+
+	run := &sortRun{file: fd.Name(), index: index}
+	w := newBufferedWriter(fd)
+	for _, row := range rows {
+		bs, err := jsonMarshal(row)
+		if err != nil {
+			fd.Close()
+			run.remove()
+			return nil, err
+		}
+
+		w.Write(bs)
+		err = w.WriteByte('\n')
+		if err != nil {
+			fd.Close()
+			run.remove()
+			return nil, err
+		}
+	}
+
+	err = w.Flush()
+	fd.Close()
+	if err != nil {
+		run.remove()
+		return nil, err
+	}
+
+	return run, nil
+}
+
+func (run *sortRun) open() error {
+	var err error
+	run.fd, err = os.Open(run.file)
+	if err != nil {
+		return err
+	}
+
+	run.r = newBufferedReader(run.fd)
+	return run.next()
+}
+
+func (run *sortRun) next() error {
+	line, err := run.r.ReadBytes('\n')
+	if err == io.EOF && len(line) == 0 {
+		run.row = nil
+		return nil
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	run.row = nil
+	return jsonUnmarshal(line, &run.row)
+}
+
+func (run *sortRun) remove() {
+	if run.fd != nil {
+		run.fd.Close()
+	}
+	os.Remove(run.file)
+}
+
+type sortRunHeap struct {
+	runs []*sortRun
+	keys []TableSortKey
+}
+
+func (h *sortRunHeap) Len() int { return len(h.runs) }
+func (h *sortRunHeap) Less(i, j int) bool {
+	c := compareRows(h.runs[i].row, h.runs[j].row, h.keys)
+	if c == 0 {
+		return h.runs[i].index < h.runs[j].index
+	}
+	return c < 0
+}
+func (h *sortRunHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *sortRunHeap) Push(x any)    { h.runs = append(h.runs, x.(*sortRun)) }
+func (h *sortRunHeap) Pop() any {
+	run := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return run
+}
+
+// Calls cb with rows in sorted order until it returns false. Sorting
+// is stable.
+func sortRows(rows *RowIterator, keys []TableSortKey, cb func(map[string]any) (bool, error)) error {
+	var runs []*sortRun
+	defer func() {
+		for _, run := range runs {
+			run.remove()
+		}
+	}()
+
+	var buffer []map[string]any
+	sortBuffer := func() {
+		sort.SliceStable(buffer, func(i, j int) bool {
+			return compareRows(buffer[i], buffer[j], keys) < 0
+		})
+	}
+
+	for rows.Next() {
+		buffer = append(buffer, rows.Row())
+		if len(buffer) < tableSortMemoryRows {
+			continue
+		}
+
+		sortBuffer()
+		run, err := spillSortRun(buffer, len(runs))
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+		buffer = nil
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sortBuffer()
+	if len(runs) == 0 {
+		for _, row := range buffer {
+			more, err := cb(row)
+			if err != nil || !more {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if len(buffer) > 0 {
+		run, err := spillSortRun(buffer, len(runs))
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+		buffer = nil
+	}
+
+	h := &sortRunHeap{keys: keys}
+	for _, run := range runs {
+		err := run.open()
+		if err != nil {
+			return err
+		}
+
+		if run.row != nil {
+			h.runs = append(h.runs, run)
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		run := h.runs[0]
+		more, err := cb(run.row)
+		if err != nil || !more {
+			return err
+		}
+
+		err = run.next()
+		if err != nil {
+			return err
+		}
+
+		if run.row == nil {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+
+	return nil
+}
+
+// Like getResultColumns but filters and sorts the entire source
+// before paging.
+func (ec EvalContext) getSortedResultColumns(ctx context.Context, project *ProjectState, thisId string, table TablePanelInfoTable, columns []string, page, pageSize int) error {
+	if _, err := getResultSource(project, table.PanelSource, table.PanelSourcePath); err != nil {
+		return err
+	}
+
+	if err := validateTableFilters(table.Filters); err != nil {
+		return err
+	}
+
+	// Everything sorting, filtering and the page itself need
+	var fields []string
+	seen := map[string]bool{}
+	addField := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+	for _, c := range columns {
+		addField(c)
+	}
+	for _, k := range table.Sort {
+		addField(k.Field)
+	}
+	for _, f := range table.Filters {
+		addField(f.Field)
+	}
+
+	ok := false
+	var raw *RowIterator
+	if table.PanelSourcePath == "" {
+		raw, ok = ec.loadColumnarPanel(project.Id, table.PanelSource, fields, 0, -1)
+	}
+	if !ok {
+		var err error
+		raw, err = loadJSONArrayFileWithPath(ec.GetPanelResultsFile(project.Id, table.PanelSource), table.PanelSourcePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	defer raw.Close()
+
+	rw, err := ec.GetResultWriter(ctx, project.Id, thisId)
+	if err != nil {
+		return err
+	}
+	defer rw.Close()
+
+	// Filtered rows with only the fields needed
+	rows := newRowIterator(func(emit func(map[string]any) bool) error {
+		for raw.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			row := make(map[string]any, len(fields))
+			for _, f := range fields {
+				row[f] = GetObjectAtPath(raw.Row(), f)
+			}
+
+			if rowMatchesFilters(row, table.Filters) && !emit(row) {
+				return nil
+			}
+		}
+
+		return raw.Err()
+	})
+	defer rows.Close()
+
+	start, end := page*pageSize, (page+1)*pageSize
+	i := 0
+	rowRequestedColumnsOnly := map[string]any{}
+	write := func(row map[string]any) (bool, error) {
+		if i >= end {
+			return false, nil
+		}
+
+		if i >= start {
+			for _, c := range columns {
+				rowRequestedColumnsOnly[c] = row[c]
+			}
+
+			err := rw.WriteRow(rowRequestedColumnsOnly)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		i++
+		return i < end, nil
+	}
+
+	if len(table.Sort) == 0 {
+		for rows.Next() {
+			more, err := write(rows.Row())
+			if err != nil || !more {
+				return err
+			}
+		}
+
+		return rows.Err()
+	}
+
+	return sortRows(rows, table.Sort, write)
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_compareValues(t *testing.T) {
+	tests := []struct {
+		a, b any
+		exp  int
+	}{
+		{nil, nil, 0},
+		{nil, false, -1},
+		{true, false, 1},
+		{1, 2.5, -1},
+		{int64(3), 3.0, 0},
+		{int64(1<<53 + 1), int64(1 << 53), 1},
+		{"b", "a", 1},
+		{10.0, "1", -1},
+		{map[string]any{"a": 1}, "z", 1},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.exp, compareValues(test.a, test.b), "%v %v", test.a, test.b)
+	}
+}
+
+func Test_filterMatches(t *testing.T) {
+	tests := []struct {
+		op    TableFilterOperator
+		want  any
+		value any
+		exp   bool
+	}{
+		{EqualFilter, "10", 10.0, true},
+		{EqualFilter, "10", "10", true},
+		{EqualFilter, "true", true, true},
+		{NotEqualFilter, 1.0, 2.0, true},
+		{LessFilter, "9", 10.0, false},
+		{LessFilter, "9", "10", true},
+		{GreaterOrEqualFilter, 2, 2.0, true},
+		{GreaterFilter, "9007199254740992", int64(1<<53 + 1), true},
+		{GreaterFilter, 2, nil, false},
+		{ContainsFilter, "ell", "hello", true},
+		{ContainsFilter, "3", 1234.0, true},
+		{IsNullFilter, nil, nil, true},
+		{IsNotNullFilter, nil, nil, false},
+	}
+
+	for _, test := range tests {
+		f := TableFilter{Field: "a", Operator: test.op, Value: test.want}
+		assert.Equal(t, test.exp, filterMatches(f, test.value), "%v %s %v", test.value, test.op, test.want)
+	}
+
+	assert.NotNil(t, validateTableFilters([]TableFilter{{Operator: "like"}}))
+}
+
+func Test_sortRows_spill(t *testing.T) {
+	defer func(n int) { tableSortMemoryRows = n }(tableSortMemoryRows)
+	tableSortMemoryRows = 3
+
+	in := []map[string]any{
+		{"k": 2.0, "i": 0.0},
+		{"k": 1.0, "i": 1.0},
+		{"k": nil, "i": 2.0},
+		{"k": 2.0, "i": 3.0},
+		{"k": 1.0, "i": 4.0},
+		{"k": 3.0, "i": 5.0},
+		{"k": 2.0, "i": 6.0},
+	}
+
+	for _, test := range []struct {
+		keys []TableSortKey
+		exp  []float64
+	}{
+		{[]TableSortKey{{Field: "k"}}, []float64{2, 1, 4, 0, 3, 6, 5}},
+		{[]TableSortKey{{Field: "k", Desc: true}, {Field: "i", Desc: true}}, []float64{5, 6, 3, 0, 4, 1, 2}},
+	} {
+		rows := rowIteratorFromSlice(in)
+
+		var got []float64
+		err := sortRows(rows, test.keys, func(row map[string]any) (bool, error) {
+			got = append(got, row["i"].(float64))
+			return true, nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, test.exp, got)
+	}
+}
@@ -1,6 +1,8 @@
 package runner
 
 import (
+	"context"
+
 	nanoid "github.com/matoous/go-nanoid/v2"
 )
 
@@ -13,3 +15,19 @@ func newId() string {
 
 	return id
 }
+
+// Calls f if ctx is done before the returned function is called. For
+// libraries that can be interrupted by closing something but don't
+// accept a context.
+func closeOnDone(ctx context.Context, f func()) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			f()
+		case <-stop:
+		}
+	}()
+
+	return func() { close(stop) }
+}
@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type VariableType string
+
+const (
+	StringVariable  VariableType = "string"
+	NumberVariable  VariableType = "number"
+	BooleanVariable VariableType = "boolean"
+	// Dates are time.Time in templates, format them with the date
+	// filter: {{ day|date:"2006-01-02" }}
+	DateVariable VariableType = "date"
+)
+
+type Variable struct {
+	Name    string       `json:"name"`
+	Type    VariableType `json:"type"`
+	Default string       `json:"default"`
+}
+
+var variableNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func makeErrBadVariable(name, msg string) *DSError {
+	return makeErrUser("Invalid variable " + name + ": " + msg)
+}
+
+func parseVariableDate(value string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(value) {
+	case "now":
+		return now, nil
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func (v Variable) parse(value string, now time.Time) (any, error) {
+	switch v.Type {
+	case "", StringVariable:
+		return value, nil
+	case NumberVariable:
+		n := convertNumber(strings.TrimSpace(value))
+		if _, ok := n.(string); ok {
+			return nil, makeErrBadVariable(v.Name, "expected a number, got "+strconv.Quote(value))
+		}
+		return n, nil
+	case BooleanVariable:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, makeErrBadVariable(v.Name, "expected true or false, got "+strconv.Quote(value))
+		}
+		return b, nil
+	case DateVariable:
+		t, err := parseVariableDate(strings.TrimSpace(value), now)
+		if err != nil {
+			return nil, makeErrBadVariable(v.Name, "expected YYYY-MM-DD, an RFC3339 timestamp, now, today, yesterday or tomorrow, got "+strconv.Quote(value))
+		}
+		return t, nil
+	}
+
+	return nil, makeErrBadVariable(v.Name, "unknown type "+string(v.Type))
+}
+
+// Page variables shadow project variables and overrides (from --var
+// for example) replace the defaults of either. Overrides that aren't
+// declared anywhere are passed through as strings.
+func resolveVariables(project *ProjectState, pageIndex int, overrides map[string]string) (map[string]any, error) {
+	declared := map[string]Variable{}
+	var order []string
+	declare := func(vars []Variable) error {
+		for _, v := range vars {
+			if !variableNameRe.MatchString(v.Name) || strings.HasPrefix(v.Name, "DM_") {
+				return makeErrBadVariable(v.Name, "names must be letters, numbers and underscores and not start with DM_")
+			}
+
+			if _, ok := declared[v.Name]; !ok {
+				order = append(order, v.Name)
+			}
+			declared[v.Name] = v
+		}
+
+		return nil
+	}
+
+	if err := declare(project.Variables); err != nil {
+		return nil, err
+	}
+	if err := declare(project.Pages[pageIndex].Variables); err != nil {
+		return nil, err
+	}
+
+	for name := range overrides {
+		if _, ok := declared[name]; !ok {
+			order = append(order, name)
+			declared[name] = Variable{Name: name, Type: StringVariable}
+		}
+	}
+
+	now := time.Now()
+	vars := map[string]any{}
+	for _, name := range order {
+		v := declared[name]
+		value, ok := overrides[name]
+		if !ok {
+			value = v.Default
+		}
+
+		parsed, err := v.parse(value, now)
+		if err != nil {
+			return nil, err
+		}
+
+		vars[name] = parsed
+	}
+
+	return vars, nil
+}
+
+// Parses name=value.
+func ParseVariableOverride(s string) (string, string, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || !variableNameRe.MatchString(name) {
+		return "", "", edsef("Expected name=value, got: %s", s)
+	}
+
+	return name, value, nil
+}
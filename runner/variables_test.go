@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_resolveVariables(t *testing.T) {
+	project := &ProjectState{
+		Variables: []Variable{
+			{Name: "region", Default: "us"},
+			{Name: "limit", Type: NumberVariable, Default: "10"},
+		},
+		Pages: []ProjectPage{
+			{
+				Variables: []Variable{
+					{Name: "limit", Type: NumberVariable, Default: "20"},
+					{Name: "debug", Type: BooleanVariable, Default: "false"},
+					{Name: "day", Type: DateVariable, Default: "2022-03-01"},
+				},
+			},
+		},
+	}
+
+	vars, err := resolveVariables(project, 0, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "us", vars["region"])
+	assert.Equal(t, 20, vars["limit"])
+	assert.Equal(t, false, vars["debug"])
+	assert.Equal(t, "2022-03-01", vars["day"].(time.Time).Format("2006-01-02"))
+
+	vars, err = resolveVariables(project, 0, map[string]string{"limit": "2.5", "debug": "true", "extra": "x"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2.5, vars["limit"])
+	assert.Equal(t, true, vars["debug"])
+	assert.Equal(t, "x", vars["extra"])
+
+	for _, overrides := range []map[string]string{
+		{"limit": "ten"},
+		{"debug": "maybe"},
+		{"day": "last tuesday"},
+	} {
+		_, err = resolveVariables(project, 0, overrides)
+		assert.NotNil(t, err, overrides)
+	}
+
+	project.Variables = append(project.Variables, Variable{Name: "DM_mine"})
+	_, err = resolveVariables(project, 0, nil)
+	assert.NotNil(t, err)
+}
+
+func Test_parseVariableDate(t *testing.T) {
+	now := time.Date(2022, 3, 1, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		in  string
+		exp time.Time
+	}{
+		{"now", now},
+		{"today", time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"Yesterday", time.Date(2022, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{"tomorrow", time.Date(2022, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"2021-12-25", time.Date(2021, 12, 25, 0, 0, 0, 0, time.UTC)},
+		{"2021-12-25T10:00:00Z", time.Date(2021, 12, 25, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		got, err := parseVariableDate(test.in, now)
+		assert.Nil(t, err, test.in)
+		assert.True(t, test.exp.Equal(got), "%s: %s != %s", test.in, test.exp, got)
+	}
+}
+
+func Test_ParseVariableOverride(t *testing.T) {
+	name, value, err := ParseVariableOverride("region=eu=west")
+	assert.Nil(t, err)
+	assert.Equal(t, "region", name)
+	assert.Equal(t, "eu=west", value)
+
+	for _, bad := range []string{"region", "=x", "1a=x", "a b=x"} {
+		_, _, err = ParseVariableOverride(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func Test_evalMacros_variables(t *testing.T) {
+	ec, cleanup := makeTestEvalContext()
+	defer cleanup()
+
+	project := &ProjectState{
+		Pages: []ProjectPage{
+			{
+				Variables: []Variable{
+					{Name: "region", Default: "us"},
+					{Name: "day", Type: DateVariable, Default: "2022-03-01"},
+				},
+			},
+		},
+	}
+
+	out, err := ec.evalMacros(`{{ region }} {{ day|date:"Jan 2" }}`, project, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "us Mar 1", out)
+
+	out, err = ec.WithVariables(map[string]string{"region": "eu"}).evalMacros(`{{ region }}`, project, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "eu", out)
+
+	// The original context is untouched
+	out, err = ec.evalMacros(`{{ region }}`, project, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "us", out)
+}
@@ -41,6 +41,12 @@ BUILTIN_VARIABLES = {
         'darwin': '',
         'windows': '.exe',
     }[platform.system().lower()],
+    # go-duckdb has no Windows library so the runner uses the duckdb CLI there
+    'go_tags': {
+        'linux': 'duckdb',
+        'darwin': 'duckdb',
+        'windows': '',
+    }[platform.system().lower()],
 }
 for i, arg in enumerate(sys.argv[2:]):
     BUILTIN_VARIABLES['arg'+str(i)] = arg
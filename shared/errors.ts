@@ -59,6 +59,18 @@ export class Cancelled extends Error {
   }
 }
 
+export class TimeoutError extends Error {
+  constructor(msg: string) {
+    super();
+    this.name = 'TimeoutError';
+    this.message = msg;
+  }
+
+  static fromJSON(j: any) {
+    return new TimeoutError(j.message);
+  }
+}
+
 export class UserError extends Error {
   constructor(msg: string) {
     super();
@@ -104,4 +116,5 @@ export const EVAL_ERRORS = [
   BadTemplateError,
   NoConnectorError,
   Cancelled,
+  TimeoutError,
 ];
@@ -7,6 +7,7 @@ import {
   ProjectPage,
   ProjectState,
   ServerInfo,
+  Variable,
 } from './state';
 
 export type GetProjectRequest = { projectId: string };
@@ -53,6 +54,9 @@ export type UpdatePageRequest = {
 };
 export type UpdatePageResponse = void;
 
+export type UpdateProjectVariablesRequest = { variables: Array<Variable> };
+export type UpdateProjectVariablesResponse = void;
+
 export type DeletePageRequest = { id: string };
 export type DeletePageResponse = void;
 
@@ -104,6 +108,7 @@ export type StoreEndpoint =
   | 'updatePanel'
   | 'updatePage'
   | 'updateProject'
+  | 'updateProjectVariables'
   | 'updateConnector'
   | 'updateServer'
   | 'updatePanelResult'
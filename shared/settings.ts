@@ -1,5 +1,6 @@
 import { LANGUAGES, SupportedLanguages } from './languages';
 import { newId } from './object';
+import { ResultLimits } from './state';
 
 class LanguageSettings {
   path: string;
@@ -15,6 +16,20 @@ export class Settings {
   languages: Record<SupportedLanguages, LanguageSettings>;
   file: string;
   stdoutMaxSize: number;
+  // In seconds, 0 means no timeout
+  panelTimeout: number;
+  // In seconds, 0 means results aren't reused unless a panel sets
+  // its own TTL
+  resultCacheTtl: number;
+  // Also store results as Parquet so downstream panels can read only
+  // the columns they need
+  columnarResults: boolean;
+  // Defaults for panels that don't set their own
+  resultLimits: ResultLimits;
+  // Engine for SQL program panels and filagg panels
+  sqlEngine: 'sqlite' | 'duckdb';
+  // Looked up on PATH when empty
+  duckdbPath: string;
   autocompleteDisabled: boolean;
   theme: 'light' | 'dark';
   caCerts: Array<{ file: string; id: string }>;
@@ -40,6 +55,12 @@ export class Settings {
         {}
       );
     this.stdoutMaxSize = stdoutMaxSize || 5000;
+    this.panelTimeout = 0;
+    this.resultCacheTtl = 0;
+    this.columnarResults = false;
+    this.resultLimits = { maxRows: 0, maxBytes: 0, onLimit: 'truncate' };
+    this.sqlEngine = 'sqlite';
+    this.duckdbPath = '';
     this.file = file;
     this.caCerts = [];
 
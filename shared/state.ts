@@ -3,6 +3,55 @@ import { VERSION } from './constants';
 import { SupportedLanguages } from './languages';
 import { mergeDeep, newId, setPath } from './object';
 
+// Milliseconds, only filled in by the Go runner
+export interface PanelTimings {
+  connect: number;
+  import: number;
+  query: number;
+  write: number;
+}
+
+export interface LogRecord {
+  time: string;
+  level: 'DEBUG' | 'INFO' | 'WARN' | 'ERROR' | 'FATAL';
+  msg: string;
+  fields?: Record<string, any>;
+}
+
+export interface ResultTruncation {
+  limit: 'rows' | 'bytes';
+  max: number;
+  // Rows produced up to and including the one that hit the limit
+  rowsSeen: number;
+}
+
+// Zero values fall back to the settings, zero there means no limit
+export interface ResultLimits {
+  maxRows: number;
+  maxBytes: number;
+  onLimit: '' | 'truncate' | 'error';
+}
+
+export interface ValueCount {
+  value: any;
+  count: number;
+}
+
+// Per top-level column, gathered from every row the Go runner wrote
+export interface ColumnStats {
+  // Includes rows missing the column
+  nullCount: number;
+  // Numbers, or else dates and datetimes as they were written
+  min?: number | string;
+  max?: number | string;
+  // Approximate
+  distinctCount: number;
+  topValues?: Array<ValueCount>;
+  // Of strings, in characters
+  minLength?: number;
+  maxLength?: number;
+}
+
 export class PanelResult {
   exception?: any;
   value?: Array<any>;
@@ -13,6 +62,15 @@ export class PanelResult {
   size: number;
   contentType: string;
   elapsed?: number;
+  timings?: PanelTimings;
+  // What the Go runner did while evaluating
+  logs?: Array<LogRecord>;
+  // Reused from an earlier eval by the Go runner
+  cached?: boolean;
+  // Cut off at a row or byte limit
+  truncated?: boolean;
+  truncation?: ResultTruncation;
+  columnStats?: Record<string, ColumnStats>;
   lastRun?: Date;
   loading: boolean;
 
@@ -257,6 +315,10 @@ export class PanelInfo {
   pageId: string;
   page: number;
   pageSize: number;
+  // Seconds to reuse results for, 0 uses the settings default and
+  // negative never reuses them
+  cacheTtl: number;
+  resultLimits: ResultLimits;
 
   constructor(
     type: PanelInfoType,
@@ -272,6 +334,8 @@ export class PanelInfo {
     this.resultMeta = new PanelResult();
     this.lastEdited = new Date();
     this.pageId = pageId;
+    this.cacheTtl = 0;
+    this.resultLimits = { maxRows: 0, maxBytes: 0, onLimit: '' };
   }
 
   static fromJSON(raw: any): PanelInfo {
@@ -335,6 +399,8 @@ export class ProgramPanelInfo extends PanelInfo {
   program: {
     type: SupportedLanguages | 'custom';
     customExe: string;
+    // Only used by SQL programs, empty uses the settings default
+    engine: '' | 'sqlite' | 'duckdb';
   };
 
   constructor(
@@ -344,6 +410,7 @@ export class ProgramPanelInfo extends PanelInfo {
       type,
       content,
       customExe,
+      engine,
     }: Partial<
       ProgramPanelInfo['program'] & { content: string; name: string }
     > = {}
@@ -352,6 +419,7 @@ export class ProgramPanelInfo extends PanelInfo {
     this.program = {
       type: type || 'python',
       customExe: customExe || '',
+      engine: engine || '',
     };
   }
 }
@@ -363,6 +431,8 @@ export type PanelInfoWidth = 'small' | 'medium' | 'large';
 export class GraphPanelInfo extends PanelInfo {
   graph: {
     panelSource: string;
+    // Path to the array within the source's results
+    panelSourcePath: string;
     ys: Array<TableColumn>;
     x: string;
     uniqueBy: string;
@@ -382,6 +452,7 @@ export class GraphPanelInfo extends PanelInfo {
     super('graph', pageId, defaults.name, defaults.content);
     this.graph = {
       panelSource: defaults.panelSource || '',
+      panelSourcePath: defaults.panelSourcePath || '',
       x: defaults.x || '',
       uniqueBy: defaults.uniqueBy || '',
       ys: defaults.ys || [],
@@ -500,12 +571,40 @@ export interface TableColumn {
   field: string;
 }
 
+export interface TableSortKey {
+  field: string;
+  desc: boolean;
+}
+
+export type TableFilterOperator =
+  | '='
+  | '!='
+  | '<'
+  | '<='
+  | '>'
+  | '>='
+  | 'contains'
+  | 'isNull'
+  | 'isNotNull';
+
+export interface TableFilter {
+  field: string;
+  operator: TableFilterOperator;
+  // Unused by isNull and isNotNull
+  value: string | number | boolean | null;
+}
+
 export class TablePanelInfo extends PanelInfo {
   table: {
     columns: Array<TableColumn>;
     panelSource: string;
+    // Path to the array within the source's results
+    panelSourcePath: string;
     width: PanelInfoWidth;
     rowNumbers: boolean;
+    // Applied by the runner over the whole source before paging
+    sort: Array<TableSortKey>;
+    filters: Array<TableFilter>;
   };
 
   constructor(
@@ -518,8 +617,11 @@ export class TablePanelInfo extends PanelInfo {
     this.table = {
       columns: defaults.columns || [],
       panelSource: defaults.panelSource || '',
+      panelSourcePath: defaults.panelSourcePath || '',
       width: defaults.width || 'small',
       rowNumbers: defaults.rowNumbers || true,
+      sort: defaults.sort || [],
+      filters: defaults.filters || [],
     };
 
     this.page = 0;
@@ -661,15 +763,56 @@ export class Export {
   }
 }
 
+// Evaluated by the Go runner's schedule action
+export class Schedule {
+  id: string;
+  name: string;
+  disabled: boolean;
+  // Five field cron expression or an alias like @daily
+  cron: string;
+  // In seconds, only used when there is no cron expression
+  interval: number;
+  // IANA name like America/New_York, defaults to local time
+  timeZone: string;
+
+  constructor(defaults: Partial<Schedule> = {}) {
+    this.id = defaults.id || newId();
+    this.name = defaults.name || '';
+    this.disabled = defaults.disabled || false;
+    this.cron = defaults.cron || '';
+    this.interval = defaults.interval || 0;
+    this.timeZone = defaults.timeZone || '';
+  }
+}
+
+export type VariableType = 'string' | 'number' | 'boolean' | 'date';
+
+// Available by name in every templated field
+export class Variable {
+  name: string;
+  type: VariableType;
+  default: string;
+
+  constructor(defaults: Partial<Variable> = {}) {
+    this.name = defaults.name || '';
+    this.type = defaults.type || 'string';
+    this.default = defaults.default || '';
+  }
+}
+
 export class ProjectPage {
   defaultModified: boolean;
   panels: Array<PanelInfo>;
+  schedules: Array<Schedule>;
+  variables: Array<Variable>;
   name: string;
   id: string;
 
   constructor(name?: string, panels?: Array<PanelInfo>) {
     this.name = name || '';
     this.panels = panels || [];
+    this.schedules = [];
+    this.variables = [];
     this.id = newId();
   }
 
@@ -677,6 +820,12 @@ export class ProjectPage {
     raw = raw || {};
     const pp = new ProjectPage();
     pp.panels = (raw.panels || []).map(PanelInfo.fromJSON);
+    pp.schedules = (raw.schedules || []).map(
+      (s: Partial<Schedule>) => new Schedule(s)
+    );
+    pp.variables = (raw.variables || []).map(
+      (v: Partial<Variable>) => new Variable(v)
+    );
     pp.name = raw.name;
     pp.id = raw.id || newId();
     return pp;
@@ -718,6 +867,7 @@ export class ProjectState {
   projectName: string;
   connectors: Array<ConnectorInfo>;
   servers: Array<ServerInfo>;
+  variables: Array<Variable>;
   id: string;
   originalVersion: string;
   lastVersion: string;
@@ -734,6 +884,7 @@ export class ProjectState {
     this.projectName = projectName || '';
     this.connectors = connectors || [];
     this.servers = servers || [];
+    this.variables = [];
     this.originalVersion = originalVersion || VERSION;
     this.lastVersion = lastVersion || VERSION;
     this.id = newId();
@@ -746,6 +897,9 @@ export class ProjectState {
     ps.pages = (raw.pages || []).map(ProjectPage.fromJSON);
     ps.connectors = (raw.connectors || []).map(ConnectorInfo.fromJSON);
     ps.servers = (raw.servers || []).map(ServerInfo.fromJSON);
+    ps.variables = (raw.variables || []).map(
+      (v: Partial<Variable>) => new Variable(v)
+    );
     ps.id = raw.id || newId();
     ps.originalVersion = raw.originalVersion || VERSION;
     ps.lastVersion = raw.lastVersion || VERSION;
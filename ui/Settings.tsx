@@ -16,6 +16,7 @@ import { Button } from './components/Button';
 import { FileInput } from './components/FileInput';
 import { FormGroup } from './components/FormGroup';
 import { Input } from './components/Input';
+import { Select } from './components/Select';
 import { Toggle } from './components/Toggle';
 import { Footer } from './Footer';
 
@@ -192,6 +193,46 @@ export function Settings() {
                 </Alert>
               </FormGroup>
 
+              <FormGroup major label="SQL Engine">
+                <div className="form-row">
+                  <Select
+                    label="Engine"
+                    value={settings.sqlEngine || 'sqlite'}
+                    onChange={function handleSQLEngineChange(value: string) {
+                      settings.sqlEngine = value as SettingsT['sqlEngine'];
+                      setSettings(settings);
+                    }}
+                  >
+                    <option value="sqlite">SQLite</option>
+                    <option value="duckdb">DuckDB</option>
+                  </Select>
+                </div>
+                {settings.sqlEngine === 'duckdb' && (
+                  <div className="form-row">
+                    <Input
+                      onChange={function handleDuckDBPathChange(
+                        newValue: string
+                      ) {
+                        settings.duckdbPath = newValue;
+                        setSettings(settings);
+                      }}
+                      label="DuckDB Path"
+                      placeholder="duckdb"
+                      value={settings.duckdbPath}
+                    />
+                  </div>
+                )}
+                <Alert type="info">
+                  <div>
+                    SQL program panels and filter/aggregate panels run on this
+                    engine unless a program panel picks its own. DuckDB runs
+                    the&nbsp;<code>duckdb</code> program on your&nbsp;
+                    <code>$PATH</code> unless you set a path here or the runner
+                    was built with DuckDB embedded.
+                  </div>
+                </Alert>
+              </FormGroup>
+
               <FormGroup major label="Custom CA Certificates">
                 {settings.caCerts.map((cert, i) => {
                   return (
@@ -31,7 +31,9 @@ export function Auth(props: {
           onChange={setAuthMethod}
           value={authMethod}
         >
-          <option value="apikey">Base64 Encoded API Key</option>
+          <option value="apikey">
+            {apiKeyLabel || 'Base64 Encoded API Key'}
+          </option>
           <option value="basic">Basic Authentication</option>
         </Select>
       </div>
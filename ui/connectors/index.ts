@@ -10,6 +10,7 @@ import { GoogleSheetsDetails } from './GoogleSheetsDetails';
 import { Neo4jDetails } from './Neo4jDetails';
 import { ODBCDetails } from './ODBCDetails';
 import { SnowflakeDetails } from './SnowflakeDetails';
+import { SplunkDetails } from './SplunkDetails';
 import { SQLiteDetails } from './SQLiteDetails';
 
 export const VENDORS: {
@@ -87,7 +88,7 @@ export const VENDORS: {
   splunk: {
     name: 'Splunk',
     id: 'splunk',
-    details: GenericDetails,
+    details: SplunkDetails,
   },
   prometheus: {
     name: 'Prometheus',
@@ -57,7 +57,7 @@ export function DatabasePanelDetails({
     }
 
     if (
-      ['prometheus'].includes(connector.database.type) &&
+      ['prometheus', 'splunk'].includes(connector.database.type) &&
       !panel.database.range.field
     ) {
       panel.database.range.field = 'time';
@@ -188,12 +188,14 @@ export function DatabasePanelDetails({
               />
             </div>
           )}
-          {['elasticsearch', 'prometheus'].includes(
+          {['elasticsearch', 'prometheus', 'splunk'].includes(
             connector.database.type
           ) && (
             <TimeSeriesRange
               range={panel.database.range}
-              hideField={['prometheus'].includes(connector.database.type)}
+              hideField={['prometheus', 'splunk'].includes(
+                connector.database.type
+              )}
               updateRange={(r: TimeSeriesRangeT) => {
                 panel.database.range = r;
                 updatePanel(panel);
@@ -214,7 +216,7 @@ export function DatabasePanelDetails({
             </div>
           )}
 
-          {connector.database.type === 'elasticsearch' && (
+          {['elasticsearch', 'splunk'].includes(connector.database.type) && (
             <div className="form-row">
               <Toggle
                 label="Insecure HTTPS"
@@ -241,6 +243,38 @@ export function DatabasePanelDetails({
             </div>
           )}
 
+          {[
+            'mysql',
+            'postgres',
+            'sqlite',
+            'sqlserver',
+            'oracle',
+            'clickhouse',
+            'snowflake',
+          ].includes(connector.database.type) && (
+            <div className="form-row">
+              <Toggle
+                label="Nested arrays in DM_getPanel"
+                rhsLabel={
+                  panel.database.extra.unnest_arrays === 'true'
+                    ? 'Child tables'
+                    : 'Ignored'
+                }
+                value={panel.database.extra.unnest_arrays === 'true'}
+                onChange={function handleUnnestArraysToggle() {
+                  panel.database.extra.unnest_arrays = String(
+                    panel.database.extra.unnest_arrays !== 'true'
+                  );
+                  updatePanel(panel);
+                }}
+              />
+              <p>
+                Arrays of objects become tables like t_0__items, joined on
+                __parent_row_id = __row_id.
+              </p>
+            </div>
+          )}
+
           {!connector.serverId && (
             <ServerPicker
               servers={servers}
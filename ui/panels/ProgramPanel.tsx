@@ -92,6 +92,23 @@ export function ProgramPanelDetails({
               ) */}
         </Select>
       </div>
+      {panel.program.type === 'sql' && MODE !== 'browser' && (
+        <div className="form-row">
+          <Select
+            label="Engine"
+            value={panel.program.engine}
+            onChange={(value: string) => {
+              panel.program.engine =
+                value as ProgramPanelInfo['program']['engine'];
+              updatePanel(panel);
+            }}
+          >
+            <option value="">Default (from settings)</option>
+            <option value="sqlite">SQLite</option>
+            <option value="duckdb">DuckDB</option>
+          </Select>
+        </div>
+      )}
       {panel.program.type === 'custom' && (
         <div className="form-row">
           <Input